@@ -0,0 +1,84 @@
+// Package archive moves the images of a mirror plan across an air gap as split tar archives.
+//
+// Each archive holds content-addressed blobs, `blobs/<algorithm>/<encoded>`, deduplicated across images.
+// The last archive also holds the index listing the images and the archive of each blob.
+// Manifests are stored as they are in their source registry, so that image digests are preserved.
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+
+	"github.com/opencontainers/go-digest"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+var logger = slog.Default().WithGroup("archive")
+
+const (
+	// IndexFile is the name of the archive index, in the last archive and next to the archives.
+	IndexFile = "mirror-index.json"
+	// archivePattern names the archives by their sequence number.
+	archivePattern = "mirror_%06d.tar"
+	// defaultMaxSize is the maximum archive size, unless configured.
+	defaultMaxSize int64 = 4 << 30
+)
+
+// Index lists the content of a set of archives.
+type Index struct {
+	Target   string   `json:"target"`
+	Archives []string `json:"archives"`
+	Images   []Image  `json:"images"`
+	// Blobs are all the blobs needed by the images, including manifests, by digest.
+	Blobs map[digest.Digest]Blob `json:"blobs"`
+}
+
+// Image is a packed plan entry.
+type Image struct {
+	plan.Entry
+	// Manifest is the digest of the top-level manifest, and MediaType its media type.
+	Manifest  digest.Digest `json:"manifest"`
+	MediaType string        `json:"mediaType"`
+	Size      int64         `json:"size"`
+	// Blobs are all the manifests, configs and layers of the image.
+	Blobs []digest.Digest `json:"blobs"`
+}
+
+// Blob is a content-addressed file in the archives.
+type Blob struct {
+	Size int64 `json:"size"`
+	// Archive is the name of the archive holding the blob, possibly from a previous set of archives.
+	Archive string `json:"archive,omitempty"`
+}
+
+// ReadIndex reads the index written next to the archives, e.g. to pack incremental archives.
+func ReadIndex(file string) (*Index, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	return decodeIndex(data)
+}
+
+func decodeIndex(data []byte) (*Index, error) {
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w", libErrs.ErrUnpackArchive, err))
+	}
+	return &idx, nil
+}
+
+// blobPath is the path of a blob in archives and OCI layouts.
+func blobPath(dgst digest.Digest) string {
+	return path.Join("blobs", dgst.Algorithm().String(), dgst.Encoded())
+}
+
+func newArchiveErr(sentinel error, err error) *libErrs.Error {
+	return common.WrapImageError(libErrs.ArchiveErrorKind, sentinel, err)
+}
@@ -0,0 +1,169 @@
+package archive
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/copy"
+	"go.podman.io/image/v5/signature"
+	"go.podman.io/image/v5/types"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/internal/testregistry"
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+var testSysCtx = &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+
+// archiveFiles returns the names of the files in the archive.
+func archiveFiles(t *testing.T, archive string) []string {
+	file, err := os.Open(archive)
+	assert.NilError(t, err)
+	defer func() { _ = file.Close() }()
+	names := []string{}
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		assert.NilError(t, err)
+		names = append(names, header.Name)
+	}
+}
+
+// mirror copies the unpacked plan to its destination and returns the destination digests.
+func mirror(t *testing.T, p *plan.Plan) map[string]digest.Digest {
+	results, err := plan.Execute(context.Background(), p, plan.ExecuteOptions{
+		Mode:           plan.MirrorToMirror,
+		DestinationCtx: testSysCtx,
+		Policy:         &signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}},
+		ImageSelection: copy.CopyAllImages,
+	})
+	assert.NilError(t, err)
+	digests := map[string]digest.Digest{}
+	for res := range results {
+		assert.NilError(t, res.Err)
+		digests[res.Entry.Destination] = res.Digest
+	}
+	return digests
+}
+
+func TestPackUnpack(t *testing.T) {
+	ctx := context.Background()
+	src, _ := testregistry.New(t)
+	dest, _ := testregistry.New(t)
+	appDigest := testregistry.PushImage(t, src+"/team/app:v1", src+"/team/copy:v1")
+	multiDigest := testregistry.PushIndex(t, src+"/team/multi:v1")
+	p := &plan.Plan{Target: dest + "/mirror", Entries: []plan.Entry{
+		{Source: src + "/team/app:v1", Destination: dest + "/mirror/team/app:v1", Origin: plan.AdditionalOrigin},
+		{Source: src + "/team/copy:v1", Destination: dest + "/mirror/team/copy:v1", Origin: plan.AdditionalOrigin},
+		{Source: src + "/team/multi@" + multiDigest.String(), Destination: dest + "/mirror/team/multi@" + multiDigest.String(), Digest: multiDigest, Origin: plan.AdditionalOrigin},
+	}}
+	archiveDir, layoutDir := t.TempDir(), t.TempDir()
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("packing and unpacking split archives", func(t *testing.T) {
+			idx, err := Pack(ctx, p, PackOptions{Dir: archiveDir, MaxSize: 2048, SystemCtx: testSysCtx})
+			assert.NilError(t, err)
+			assert.Assert(t, len(idx.Archives) > 1)
+			assert.Equal(t, len(idx.Images), 3)
+			assert.Equal(t, idx.Images[0].Manifest, appDigest)
+			// the copied image shares all its blobs: manifest, config and 2 layers
+			assert.DeepEqual(t, idx.Images[0].Blobs, idx.Images[1].Blobs)
+			assert.Equal(t, len(idx.Images[0].Blobs), 4)
+			// the index, its 2 instances with their config and layer
+			assert.Equal(t, len(idx.Images[2].Blobs), 7)
+			assert.Equal(t, len(idx.Blobs), 11)
+
+			read, err := ReadIndex(filepath.Join(archiveDir, IndexFile))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, read, idx)
+			// archives are split at the maximum size, unless they hold a single larger file, as the index here
+			for _, archive := range idx.Archives {
+				info, err := os.Stat(filepath.Join(archiveDir, archive))
+				assert.NilError(t, err)
+				assert.Assert(t, info.Size() <= 2048 || len(archiveFiles(t, filepath.Join(archiveDir, archive))) == 1, archive)
+			}
+			last := filepath.Join(archiveDir, idx.Archives[len(idx.Archives)-1])
+			assert.DeepEqual(t, archiveFiles(t, last), []string{IndexFile})
+
+			unpacked, err := Unpack(ctx, archiveDir, layoutDir)
+			assert.NilError(t, err)
+			assert.Equal(t, unpacked.Target, p.Target)
+			assert.Equal(t, unpacked.Entries[0].Source, "oci:"+layoutDir+":"+dest+"/mirror/team/app:v1")
+
+			digests := mirror(t, unpacked)
+			assert.Equal(t, digests[dest+"/mirror/team/app:v1"], appDigest)
+			assert.Equal(t, digests[dest+"/mirror/team/multi@"+multiDigest.String()], multiDigest)
+		})
+
+		t.Run("packing incremental archives", func(t *testing.T) {
+			previous, err := ReadIndex(filepath.Join(archiveDir, IndexFile))
+			assert.NilError(t, err)
+			newDigest := testregistry.PushImage(t, src+"/team/new:v1")
+			incremental := &plan.Plan{Target: p.Target, Entries: append(p.Entries[:1:1],
+				plan.Entry{Source: src + "/team/new:v1", Destination: dest + "/mirror/team/new:v1", Origin: plan.AdditionalOrigin})}
+
+			incrementalDir := t.TempDir()
+			idx, err := Pack(ctx, incremental, PackOptions{Dir: incrementalDir, Previous: previous, SystemCtx: testSysCtx})
+			assert.NilError(t, err)
+			// the archives are numbered after the previous ones
+			assert.DeepEqual(t, idx.Archives, []string{fmt.Sprintf(archivePattern, len(previous.Archives)+1)})
+			packed := 0
+			for dgst, blob := range idx.Blobs {
+				if blob.Archive == idx.Archives[0] {
+					packed++
+				} else {
+					assert.DeepEqual(t, blob, previous.Blobs[dgst])
+				}
+			}
+			assert.Equal(t, packed, 4)
+
+			unpacked, err := Unpack(ctx, incrementalDir, layoutDir)
+			assert.NilError(t, err)
+			assert.Equal(t, mirror(t, unpacked)[dest+"/mirror/team/new:v1"], newDigest)
+
+			// the blobs of the previous archives are missing from a new layout
+			_, err = Unpack(ctx, incrementalDir, t.TempDir())
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("a blob is corrupted", func(t *testing.T) {
+			dir := t.TempDir()
+			file, err := os.Create(filepath.Join(dir, "mirror_000001.tar"))
+			assert.NilError(t, err)
+			tw := tar.NewWriter(file)
+			content := []byte("corrupted")
+			assert.NilError(t, tw.WriteHeader(&tar.Header{Name: blobPath(appDigest), Size: int64(len(content)), Mode: 0o644}))
+			_, err = tw.Write(content)
+			assert.NilError(t, err)
+			assert.NilError(t, tw.Close())
+			assert.NilError(t, file.Close())
+
+			_, err = Unpack(ctx, dir, t.TempDir())
+			assert.ErrorIs(t, err, libErrs.ErrCorruptedBlob)
+		})
+
+		t.Run("there are no archives", func(t *testing.T) {
+			_, err := Unpack(ctx, t.TempDir(), t.TempDir())
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("a source image is missing", func(t *testing.T) {
+			missing := &plan.Plan{Entries: []plan.Entry{{Source: src + "/team/missing:v1", Destination: dest + "/mirror/team/missing:v1"}}}
+			_, err := Pack(ctx, missing, PackOptions{Dir: t.TempDir(), SystemCtx: testSysCtx})
+			assert.ErrorIs(t, err, libErrs.ErrPackArchive)
+			assert.ErrorIs(t, err, libErrs.ErrManifestUnknown)
+		})
+	})
+}
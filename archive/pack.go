@@ -0,0 +1,293 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/types"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+// PackOptions is used to configure the archives.
+type PackOptions struct {
+	// Dir is where the archives and their index are written.
+	Dir string
+	// MaxSize is the maximum size of each archive, in bytes. Defaults to 4GiB.
+	// Blobs larger than MaxSize are written to their own archive.
+	MaxSize int64
+	// Previous is the index of previously transferred archives: their blobs are not packed again,
+	// and the new archives are numbered after them.
+	Previous  *Index
+	SystemCtx *types.SystemContext
+}
+
+// Pack writes the images of the plan to split tar archives and returns their index.
+// All the instances of multi-arch images are packed.
+func Pack(ctx context.Context, p *plan.Plan, opts PackOptions) (*Index, error) {
+	if opts.Dir == "" {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: missing archive directory", libErrs.ErrPackArchive))
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultMaxSize
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, newArchiveErr(libErrs.ErrPackArchive, err)
+	}
+
+	idx := &Index{Target: p.Target, Archives: []string{}, Images: []Image{}, Blobs: map[digest.Digest]Blob{}}
+	w := &archiveWriter{dir: opts.Dir, maxSize: opts.MaxSize, index: idx}
+	if opts.Previous != nil {
+		maps.Copy(idx.Blobs, opts.Previous.Blobs)
+		for _, name := range opts.Previous.Archives {
+			var seq int
+			if _, err := fmt.Sscanf(name, archivePattern, &seq); err == nil {
+				w.first = max(w.first, seq)
+			}
+		}
+	}
+	defer w.abort()
+
+	for _, e := range p.Entries {
+		img, err := packImage(ctx, w, e, opts.SystemCtx)
+		if err != nil {
+			return nil, err
+		}
+		idx.Images = append(idx.Images, *img)
+		logger.Debug("packed image", slog.String("source", e.Source), slog.String("manifest", img.Manifest.String()))
+	}
+	if err := w.close(); err != nil {
+		return nil, newArchiveErr(libErrs.ErrPackArchive, err)
+	}
+	logger.Info("packed images", slog.Int("images", len(idx.Images)), slog.Int("archives", len(idx.Archives)))
+	return idx, nil
+}
+
+// packImage writes the manifests and blobs of the entry image.
+func packImage(ctx context.Context, w *archiveWriter, e plan.Entry, sysCtx *types.SystemContext) (*Image, error) {
+	ref, err := plan.SourceReference(e)
+	if err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrPackArchive, libErrs.ErrInvalidRef, err))
+	}
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return nil, newArchiveErr(libErrs.ErrPackArchive, err)
+	}
+	defer func() { _ = src.Close() }()
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, newArchiveErr(libErrs.ErrPackArchive, err)
+	}
+	img := &Image{Entry: e, MediaType: mimeType, Size: int64(len(rawManifest))}
+	if img.Manifest, err = manifest.Digest(rawManifest); err != nil {
+		return nil, newArchiveErr(libErrs.ErrPackArchive, err)
+	}
+	if img.Blobs, err = packManifest(ctx, w, src, rawManifest, mimeType); err != nil {
+		return nil, newArchiveErr(libErrs.ErrPackArchive, err)
+	}
+	return img, nil
+}
+
+// packManifest writes the manifest with its instances, config and layers, and returns their digests.
+func packManifest(ctx context.Context, w *archiveWriter, src types.ImageSource, rawManifest []byte, mimeType string) ([]digest.Digest, error) {
+	dgst, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.addBytes(dgst, rawManifest); err != nil {
+		return nil, err
+	}
+	blobs := []digest.Digest{dgst}
+
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(rawManifest, mimeType)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range list.Instances() {
+			raw, instanceType, err := src.GetManifest(ctx, &instance)
+			if err != nil {
+				return nil, err
+			}
+			instanceBlobs, err := packManifest(ctx, w, src, raw, instanceType)
+			if err != nil {
+				return nil, err
+			}
+			blobs = append(blobs, instanceBlobs...)
+		}
+		return blobs, nil
+	}
+
+	m, err := manifest.FromBlob(rawManifest, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	infos := []types.BlobInfo{m.ConfigInfo()}
+	for _, layer := range m.LayerInfos() {
+		infos = append(infos, layer.BlobInfo)
+	}
+	for _, info := range infos {
+		if info.Digest == "" || slices.Contains(blobs, info.Digest) {
+			continue
+		}
+		blobs = append(blobs, info.Digest)
+		if w.has(info.Digest) {
+			continue
+		}
+		reader, size, err := src.GetBlob(ctx, info, none.NoCache)
+		if err != nil {
+			return nil, err
+		}
+		err = w.add(info.Digest, size, reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blobs, nil
+}
+
+// archiveWriter writes blobs to archives, starting a new archive when the current one is full.
+type archiveWriter struct {
+	dir     string
+	maxSize int64
+	index   *Index
+	// first is the sequence number of the last previous archive.
+	first int
+
+	file *os.File
+	tw   *tar.Writer
+	size int64
+}
+
+func (w *archiveWriter) has(dgst digest.Digest) bool {
+	_, ok := w.index.Blobs[dgst]
+	return ok
+}
+
+func (w *archiveWriter) addBytes(dgst digest.Digest, data []byte) error {
+	if w.has(dgst) {
+		return nil
+	}
+	return w.add(dgst, int64(len(data)), bytes.NewReader(data))
+}
+
+// add writes the blob, verifying its digest. Blobs of unknown size are buffered to a temporary file.
+func (w *archiveWriter) add(dgst digest.Digest, size int64, r io.Reader) error {
+	if w.has(dgst) {
+		return nil
+	}
+	if size < 0 {
+		tmp, err := os.CreateTemp(w.dir, "blob-*")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tmp.Close(); _ = os.Remove(tmp.Name()) }()
+		if size, err = io.Copy(tmp, r); err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = tmp
+	}
+
+	// NOTE: tar headers and padding take up to 1536 bytes per entry.
+	if w.tw == nil || (w.size > 0 && w.size+size+1536 > w.maxSize) {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+	if err := w.tw.WriteHeader(&tar.Header{Name: blobPath(dgst), Mode: 0o644, Size: size, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	verifier := dgst.Verifier()
+	if _, err := io.Copy(w.tw, io.TeeReader(io.LimitReader(r, size), verifier)); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %s", libErrs.ErrCorruptedBlob, dgst))
+	}
+	w.size += size + 1536
+	w.index.Blobs[dgst] = Blob{Size: size, Archive: w.index.Archives[len(w.index.Archives)-1]}
+	return nil
+}
+
+// next closes the current archive and starts a new one.
+func (w *archiveWriter) next() error {
+	if err := w.closeArchive(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf(archivePattern, w.first+len(w.index.Archives)+1)
+	file, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return err
+	}
+	w.file, w.tw, w.size = file, tar.NewWriter(file), 0
+	w.index.Archives = append(w.index.Archives, name)
+	return nil
+}
+
+func (w *archiveWriter) closeArchive() error {
+	if w.tw == nil {
+		return nil
+	}
+	tw, file := w.tw, w.file
+	w.tw, w.file = nil, nil
+	if err := tw.Close(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// close writes the index to the last archive, or to a new one if it doesn't fit, and next to the archives.
+func (w *archiveWriter) close() error {
+	if w.tw == nil {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(w.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if w.size > 0 && w.size+int64(len(data))+1536 > w.maxSize {
+		if err := w.next(); err != nil {
+			return err
+		}
+		// NOTE: the index lists the new archive.
+		if data, err = json.MarshalIndent(w.index, "", "  "); err != nil {
+			return err
+		}
+	}
+	if err := w.tw.WriteHeader(&tar.Header{Name: IndexFile, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	if _, err := w.tw.Write(data); err != nil {
+		return err
+	}
+	if err := w.closeArchive(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(w.dir, IndexFile), data, 0o644)
+}
+
+// abort closes the current archive after a failure. It is a no-op once the writer is closed.
+func (w *archiveWriter) abort() {
+	_ = w.closeArchive()
+}
@@ -0,0 +1,234 @@
+package archive
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+// Unpack extracts the archives of `archiveDir` to the OCI layout `layoutDir`, verifying every blob,
+// and returns the plan to mirror the unpacked images to their destination.
+// Each image is named in the layout by its destination. The layout is shared by incremental archives,
+// which must be unpacked in order to the same layout.
+// Copy the plan with `plan.MirrorToMirror` and `copy.CopyAllImages` to preserve the image digests.
+func Unpack(ctx context.Context, archiveDir, layoutDir string) (*plan.Plan, error) {
+	archives, err := filepath.Glob(filepath.Join(archiveDir, "mirror_*.tar"))
+	if err != nil {
+		return nil, newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	if len(archives) == 0 {
+		return nil, libErrs.NewErr(libErrs.ArchiveErrorKind, fmt.Errorf("%w: archives in %s %w", libErrs.ErrUnpackArchive, archiveDir, libErrs.ErrNotFound))
+	}
+	slices.Sort(archives)
+
+	var idx *Index
+	for _, archive := range archives {
+		if ctx.Err() != nil {
+			return nil, newArchiveErr(libErrs.ErrUnpackArchive, ctx.Err())
+		}
+		found, err := unpackArchive(archive, layoutDir)
+		if err != nil {
+			return nil, err
+		}
+		if found != nil {
+			idx = found
+		}
+		logger.Debug("unpacked archive", slog.String("archive", archive))
+	}
+	if idx == nil {
+		return nil, libErrs.NewErr(libErrs.ArchiveErrorKind, fmt.Errorf("%w: %s %w", libErrs.ErrUnpackArchive, IndexFile, libErrs.ErrNotFound))
+	}
+
+	if err := Verify(idx, layoutDir); err != nil {
+		return nil, err
+	}
+	if err := writeLayoutIndex(idx, layoutDir); err != nil {
+		return nil, newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+
+	p := &plan.Plan{Target: idx.Target}
+	for _, img := range idx.Images {
+		e := img.Entry
+		e.Source = fmt.Sprintf("oci:%s:%s", layoutDir, img.Destination)
+		e.Digest = img.Manifest
+		p.Entries = append(p.Entries, e)
+	}
+	logger.Info("unpacked images", slog.Int("images", len(p.Entries)), slog.String("layout", layoutDir))
+	return p, nil
+}
+
+// Verify checks that every blob of the indexed images is in the OCI layout with its expected size.
+// Blob digests are verified when unpacked.
+func Verify(idx *Index, layoutDir string) error {
+	missing := []string{}
+	for _, img := range idx.Images {
+		for _, dgst := range img.Blobs {
+			blob, ok := idx.Blobs[dgst]
+			if !ok {
+				return libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: blob %s of %s is not indexed", libErrs.ErrUnpackArchive, dgst, img.Destination))
+			}
+			info, err := os.Stat(filepath.Join(layoutDir, filepath.FromSlash(blobPath(dgst))))
+			if errors.Is(err, os.ErrNotExist) {
+				missing = append(missing, dgst.String())
+				continue
+			}
+			if err != nil {
+				return newArchiveErr(libErrs.ErrUnpackArchive, err)
+			}
+			if info.Size() != blob.Size {
+				return libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %s size %d, expected %d", libErrs.ErrUnpackArchive, libErrs.ErrCorruptedBlob, dgst, info.Size(), blob.Size))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return libErrs.NewErr(libErrs.ArchiveErrorKind, fmt.Errorf("%w: %d blobs %w, unpack the previous archives first: %v",
+			libErrs.ErrUnpackArchive, len(missing), libErrs.ErrNotFound, slices.Compact(missing)))
+	}
+	return nil
+}
+
+// unpackArchive extracts the blobs of the archive and returns its index, if any.
+func unpackArchive(archive, layoutDir string) (*Index, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var idx *Index
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newArchiveErr(libErrs.ErrUnpackArchive, fmt.Errorf("read %s: %w", archive, err))
+		}
+		if header.Name == IndexFile {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, newArchiveErr(libErrs.ErrUnpackArchive, err)
+			}
+			if idx, err = decodeIndex(data); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		dgst, err := blobDigest(header.Name)
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %s: %w", libErrs.ErrUnpackArchive, archive, err))
+		}
+		if err := writeBlob(layoutDir, dgst, tr); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
+
+// blobDigest returns the digest of a `blobs/<algorithm>/<encoded>` archive entry.
+func blobDigest(name string) (digest.Digest, error) {
+	dir, encoded := path.Split(path.Clean(name))
+	algorithm, ok := cutBlobsDir(dir)
+	if !ok {
+		return "", fmt.Errorf("unexpected archive entry %q", name)
+	}
+	dgst := digest.NewDigestFromEncoded(digest.Algorithm(algorithm), encoded)
+	if err := dgst.Validate(); err != nil {
+		return "", fmt.Errorf("unexpected archive entry %q: %w", name, err)
+	}
+	return dgst, nil
+}
+
+func cutBlobsDir(dir string) (string, bool) {
+	matched, err := path.Match("blobs/*/", dir)
+	if err != nil || !matched {
+		return "", false
+	}
+	return path.Base(dir), true
+}
+
+// writeBlob writes the blob to the layout through a temporary file, renamed once its digest is verified.
+func writeBlob(layoutDir string, dgst digest.Digest, r io.Reader) error {
+	dest := filepath.Join(layoutDir, filepath.FromSlash(blobPath(dgst)))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), dgst.Encoded()+".*")
+	if err != nil {
+		return newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	verifier := dgst.Verifier()
+	_, err = io.Copy(tmp, io.TeeReader(r, verifier))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	if !verifier.Verified() {
+		return libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %s", libErrs.ErrUnpackArchive, libErrs.ErrCorruptedBlob, dgst))
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return newArchiveErr(libErrs.ErrUnpackArchive, err)
+	}
+	return nil
+}
+
+// writeLayoutIndex adds the images to the layout index, named by their destination.
+func writeLayoutIndex(idx *Index, layoutDir string) error {
+	layoutFile := filepath.Join(layoutDir, imgspecv1.ImageLayoutFile)
+	layout, err := json.Marshal(imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(layoutFile, layout, 0o644); err != nil {
+		return err
+	}
+
+	indexFile := filepath.Join(layoutDir, imgspecv1.ImageIndexFile)
+	index := imgspecv1.Index{Versioned: imgspecs.Versioned{SchemaVersion: 2}, MediaType: imgspecv1.MediaTypeImageIndex}
+	if data, err := os.ReadFile(indexFile); err == nil {
+		if err := json.Unmarshal(data, &index); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	for _, img := range idx.Images {
+		index.Manifests = slices.DeleteFunc(index.Manifests, func(desc imgspecv1.Descriptor) bool {
+			return desc.Annotations[imgspecv1.AnnotationRefName] == img.Destination
+		})
+		index.Manifests = append(index.Manifests, imgspecv1.Descriptor{
+			MediaType:   img.MediaType,
+			Digest:      img.Manifest,
+			Size:        img.Size,
+			Annotations: map[string]string{imgspecv1.AnnotationRefName: img.Destination},
+		})
+	}
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(indexFile, data, 0o644)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/r4f4/oc-mirror-libs/common"
 	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/render"
 )
 
 const validCatalog = "../testdata/catalogs/valid-catalog/"
@@ -44,6 +45,8 @@ func TestCalogClientFails(t *testing.T) {
 		assert.ErrorIs(t, err, libErrs.ErrNotFound)
 		_, err = catalog.GetBundlesForChannel("rhbk-operator", "invalid-channel")
 		assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		_, err = catalog.GetChannelGraph("rhbk-operator", "invalid-channel")
+		assert.ErrorIs(t, err, libErrs.ErrNotFound)
 	})
 
 	t.Run("with invalid bundle", func(t *testing.T) {
@@ -149,6 +152,23 @@ func TestCatalogClientSucceeds(t *testing.T) {
 		assert.DeepEqual(t, ri, expected)
 	})
 
+	t.Run("getting channel graph", func(t *testing.T) {
+		graph, err := catalog.GetChannelGraph("rhbk-operator", "stable-v26")
+		assert.NilError(t, err)
+		expected := &render.Graph{
+			Name: "rhbk-operator/stable-v26",
+			Nodes: []render.Node{
+				{ID: "rhbk-operator.v26.0.5-opr.1"},
+				{ID: "rhbk-operator.v26.2.11-opr.1", Style: render.HeadNode},
+			},
+			Edges: []render.Edge{
+				{From: "rhbk-operator.v26.0.5-opr.1", To: "rhbk-operator.v26.2.11-opr.1"},
+				{From: "rhbk-operator.v26.0.5-opr.1", To: "rhbk-operator.v26.2.11-opr.1", Style: render.DashedEdge, Label: "skips"},
+			},
+		}
+		assert.DeepEqual(t, graph, expected)
+	})
+
 	t.Run("getting dependencies for bundle", func(t *testing.T) {
 		deps, err := catalog.GetDependenciesForBundle("devspaces", "devspacesoperator.v3.10.0")
 		assert.NilError(t, err)
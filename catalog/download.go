@@ -48,7 +48,7 @@ func DownloadImageIndex(ctx context.Context, imageRef string, opts DownloadOptio
 	imageRef = strings.TrimPrefix(imageRef, "docker://")
 	ref, err := docker.ParseReference("//" + imageRef)
 	if err != nil {
-		return nil, newDownloadErr(err)
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrDownload, libErrs.ErrInvalidRef, err))
 	}
 
 	if opts.SystemCtx == nil {
@@ -212,7 +212,7 @@ func extractLayer(ociPath, destPath string, layer imgspecv1.Descriptor) error {
 }
 
 func newDownloadErr(err error) *libErrs.Error {
-	return libErrs.NewCatalogErr(fmt.Errorf("%w: %w", libErrs.ErrDownload, err))
+	return common.WrapImageError(libErrs.CatalogErrorKind, libErrs.ErrDownload, err)
 }
 
 func newExtractErr(err error) *libErrs.Error {
@@ -2,11 +2,14 @@ package catalog
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
 )
 
 func TestDownload(t *testing.T) {
@@ -44,4 +47,14 @@ func TestDownload(t *testing.T) {
 		assert.NilError(t, err)
 		assert.Assert(t, info.IsDir())
 	})
+
+	t.Run("should classify download errors", func(t *testing.T) {
+		_, err := DownloadImageIndex(context.Background(), "quay.io/Invalid/Catalog:v4.19", DownloadOptions{DestDir: t.TempDir()})
+		var libErr *libErrs.Error
+		assert.Assert(t, errors.As(err, &libErr))
+		assert.Equal(t, libErr.Kind(), libErrs.ValidationErrorKind)
+		assert.ErrorIs(t, err, libErrs.ErrDownload)
+		assert.ErrorIs(t, err, libErrs.ErrInvalidRef)
+		assert.Assert(t, !libErrs.IsTemporary(err))
+	})
 }
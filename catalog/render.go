@@ -0,0 +1,48 @@
+package catalog
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/r4f4/oc-mirror-libs/render"
+)
+
+// GetChannelGraph returns the upgrade graph of an operator's channel for rendering.
+// `replaces` edges are solid and `skips` edges are dashed. The channel head is styled as a head node.
+func (l *LoadedCatalog) GetChannelGraph(operatorName string, channelName string) (*render.Graph, error) {
+	ch, err := l.getChannel(operatorName, channelName)
+	if err != nil {
+		return nil, err
+	}
+	graph := &render.Graph{Name: fmt.Sprintf("%s/%s", operatorName, channelName)}
+	nodes := sets.New[string]()
+	replaced := sets.New[string]()
+	addNode := func(name string) {
+		if !nodes.Has(name) {
+			nodes.Insert(name)
+			graph.Nodes = append(graph.Nodes, render.Node{ID: name})
+		}
+	}
+	for _, entry := range ch.Entries {
+		addNode(entry.Name)
+	}
+	for _, entry := range ch.Entries {
+		if entry.Replaces != "" {
+			addNode(entry.Replaces)
+			replaced.Insert(entry.Replaces)
+			graph.Edges = append(graph.Edges, render.Edge{From: entry.Replaces, To: entry.Name})
+		}
+		for _, skip := range entry.Skips {
+			addNode(skip)
+			replaced.Insert(skip)
+			graph.Edges = append(graph.Edges, render.Edge{From: skip, To: entry.Name, Style: render.DashedEdge, Label: "skips"})
+		}
+	}
+	for i, n := range graph.Nodes {
+		if !replaced.Has(n.ID) {
+			graph.Nodes[i].Style = render.HeadNode
+		}
+	}
+	return graph, nil
+}
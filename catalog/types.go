@@ -1,8 +1,14 @@
 package catalog
 
 import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
 	"github.com/operator-framework/operator-registry/alpha/property"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
 )
 
 // Wrappers around alpha/declcfg types.
@@ -12,6 +18,8 @@ type (
 	Bundle          declcfg.Bundle
 	RelatedImage    declcfg.RelatedImage
 	PackageRequired property.PackageRequired
+	GVK             property.GVK
+	GVKRequired     property.GVKRequired
 )
 
 // Implement the `nameable` interface
@@ -31,3 +39,41 @@ func (b Bundle) GetName() string {
 func (r RelatedImage) GetName() string {
 	return r.Name
 }
+
+// Version returns the bundle version from its `olm.package` property.
+func (b Bundle) Version() (*semver.Version, error) {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil, libErrs.NewCatalogErr(fmt.Errorf("%w: %w", libErrs.ErrParseProperty, err))
+	}
+	if len(props.Packages) == 0 {
+		return nil, libErrs.NewCatalogErr(fmt.Errorf("bundle %q package property %w", b.Name, libErrs.ErrNotFound))
+	}
+	ver, err := semver.NewVersion(props.Packages[0].Version)
+	if err != nil {
+		return nil, libErrs.NewCatalogErr(fmt.Errorf("%w: %w", libErrs.ErrParseProperty, err))
+	}
+	return ver, nil
+}
+
+// GVKs returns the APIs provided by the bundle from its `olm.gvk` properties.
+func (b Bundle) GVKs() ([]GVK, error) {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil, libErrs.NewCatalogErr(fmt.Errorf("%w: %w", libErrs.ErrParseProperty, err))
+	}
+	return common.Map(props.GVKs, func(g property.GVK) GVK { return GVK(g) }), nil
+}
+
+// RequiredGVKs returns the APIs required by the bundle from its `olm.gvk.required` properties.
+func (b Bundle) RequiredGVKs() ([]GVKRequired, error) {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil, libErrs.NewCatalogErr(fmt.Errorf("%w: %w", libErrs.ErrParseProperty, err))
+	}
+	return common.Map(props.GVKsRequired, func(g property.GVKRequired) GVKRequired { return GVKRequired(g) }), nil
+}
+
+func (g GVKRequired) String() string {
+	return fmt.Sprintf("%s/%s %s", g.Group, g.Version, g.Kind)
+}
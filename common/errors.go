@@ -0,0 +1,119 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/signature"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// invalidRefErrs are the errors returned when parsing malformed image references.
+var invalidRefErrs = []error{
+	reference.ErrReferenceInvalidFormat,
+	reference.ErrTagInvalidFormat,
+	reference.ErrDigestInvalidFormat,
+	reference.ErrNameContainsUppercase,
+	reference.ErrNameEmpty,
+	reference.ErrNameTooLong,
+	reference.ErrNameNotCanonical,
+}
+
+// ClassifyImageError returns the kind and cause of errors returned by containers/image.
+// The cause is one of the registry error sentinels. `ok` is false if the error is not recognized.
+func ClassifyImageError(err error) (kind libErrs.ErrorKind, cause error, ok bool) {
+	if isTLSError(err) {
+		return libErrs.RegistryErrorKind, libErrs.ErrTLS, true
+	}
+
+	var unauthorized docker.ErrUnauthorizedForCredentials
+	if errors.As(err, &unauthorized) {
+		return libErrs.AuthErrorKind, libErrs.ErrUnauthorized, true
+	}
+	if errors.Is(err, docker.ErrTooManyRequests) {
+		return libErrs.RegistryErrorKind, libErrs.ErrRateLimited, true
+	}
+
+	var coder errcode.ErrorCoder
+	if errors.As(err, &coder) {
+		switch coder.ErrorCode() {
+		case errcode.ErrorCodeUnauthorized, errcode.ErrorCodeDenied:
+			return libErrs.AuthErrorKind, libErrs.ErrUnauthorized, true
+		case errcode.ErrorCodeTooManyRequests:
+			return libErrs.RegistryErrorKind, libErrs.ErrRateLimited, true
+		case v2.ErrorCodeManifestUnknown, v2.ErrorCodeNameUnknown:
+			return libErrs.RegistryErrorKind, libErrs.ErrManifestUnknown, true
+		}
+	}
+
+	var statusErr docker.UnexpectedHTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return libErrs.AuthErrorKind, libErrs.ErrUnauthorized, true
+		case code == http.StatusTooManyRequests:
+			return libErrs.RegistryErrorKind, libErrs.ErrRateLimited, true
+		case code == http.StatusNotFound:
+			return libErrs.RegistryErrorKind, libErrs.ErrManifestUnknown, true
+		case code >= http.StatusInternalServerError:
+			return libErrs.RegistryErrorKind, libErrs.ErrNetwork, true
+		}
+	}
+
+	var policyErr signature.PolicyRequirementError
+	if errors.As(err, &policyErr) {
+		return libErrs.PolicyErrorKind, libErrs.ErrPolicy, true
+	}
+	for _, refErr := range invalidRefErrs {
+		if errors.Is(err, refErr) {
+			return libErrs.ValidationErrorKind, libErrs.ErrInvalidRef, true
+		}
+	}
+
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) {
+		return libErrs.StorageErrorKind, libErrs.ErrStorage, true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return libErrs.RegistryErrorKind, libErrs.ErrNetwork, true
+	}
+	return 0, nil, false
+}
+
+// WrapImageError wraps an error returned by containers/image with the sentinel of the failed operation.
+// Recognized errors get their classified kind and cause, so that callers can tell retryable errors
+// from fatal ones. Other errors get the `fallback` kind.
+func WrapImageError(fallback libErrs.ErrorKind, sentinel error, err error) *libErrs.Error {
+	if kind, cause, ok := ClassifyImageError(err); ok {
+		return libErrs.NewErr(kind, fmt.Errorf("%w: %w: %w", sentinel, cause, err))
+	}
+	return libErrs.NewErr(fallback, fmt.Errorf("%w: %w", sentinel, err))
+}
+
+func isTLSError(err error) bool {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		verification     *tls.CertificateVerificationError
+		recordHeader     tls.RecordHeaderError
+	)
+	return errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostname) ||
+		errors.As(err, &invalidCert) ||
+		errors.As(err, &verification) ||
+		errors.As(err, &recordHeader)
+}
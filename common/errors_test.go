@@ -0,0 +1,124 @@
+package common
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"testing"
+
+	"github.com/docker/distribution/registry/api/errcode"
+	v2 "github.com/docker/distribution/registry/api/v2"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/signature"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestClassifyImageError(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		kind  libErrs.ErrorKind
+		cause error
+	}{
+		{
+			name:  "invalid credentials",
+			err:   docker.ErrUnauthorizedForCredentials{Err: errors.New("bad token")},
+			kind:  libErrs.AuthErrorKind,
+			cause: libErrs.ErrUnauthorized,
+		},
+		{
+			name:  "access denied",
+			err:   fmt.Errorf("reading manifest: %w", errcode.ErrorCodeDenied.WithMessage("denied")),
+			kind:  libErrs.AuthErrorKind,
+			cause: libErrs.ErrUnauthorized,
+		},
+		{
+			name:  "manifest unknown",
+			err:   fmt.Errorf("reading manifest v4.99: %w", v2.ErrorCodeManifestUnknown.WithMessage("manifest unknown")),
+			kind:  libErrs.RegistryErrorKind,
+			cause: libErrs.ErrManifestUnknown,
+		},
+		{
+			name:  "repository unknown",
+			err:   fmt.Errorf("reading manifest latest: %w", v2.ErrorCodeNameUnknown.WithMessage("repository name not known to registry")),
+			kind:  libErrs.RegistryErrorKind,
+			cause: libErrs.ErrManifestUnknown,
+		},
+		{
+			name:  "rate limited",
+			err:   fmt.Errorf("pinging registry: %w", docker.ErrTooManyRequests),
+			kind:  libErrs.RegistryErrorKind,
+			cause: libErrs.ErrRateLimited,
+		},
+		{
+			name:  "unknown certificate authority",
+			err:   fmt.Errorf("pinging registry: %w", x509.UnknownAuthorityError{}),
+			kind:  libErrs.RegistryErrorKind,
+			cause: libErrs.ErrTLS,
+		},
+		{
+			name:  "connection refused",
+			err:   fmt.Errorf("pinging registry: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}),
+			kind:  libErrs.RegistryErrorKind,
+			cause: libErrs.ErrNetwork,
+		},
+		{
+			name:  "policy rejection",
+			err:   fmt.Errorf("source image rejected: %w", signature.PolicyRequirementError("unsigned")),
+			kind:  libErrs.PolicyErrorKind,
+			cause: libErrs.ErrPolicy,
+		},
+		{
+			name:  "invalid reference",
+			err:   reference.ErrNameContainsUppercase,
+			kind:  libErrs.ValidationErrorKind,
+			cause: libErrs.ErrInvalidRef,
+		},
+		{
+			name:  "filesystem failure",
+			err:   &fs.PathError{Op: "mkdir", Path: "/oci", Err: fs.ErrPermission},
+			kind:  libErrs.StorageErrorKind,
+			cause: libErrs.ErrStorage,
+		},
+	} {
+		t.Run("should classify "+tc.name, func(t *testing.T) {
+			kind, cause, ok := ClassifyImageError(tc.err)
+			assert.Assert(t, ok)
+			assert.Equal(t, kind, tc.kind)
+			assert.Equal(t, cause, tc.cause)
+		})
+	}
+
+	t.Run("should not classify unknown errors", func(t *testing.T) {
+		_, cause, ok := ClassifyImageError(errors.New("unknown"))
+		assert.Assert(t, !ok)
+		assert.NilError(t, cause)
+	})
+}
+
+func TestWrapImageError(t *testing.T) {
+	sentinel := errors.New("cannot do it")
+
+	t.Run("should wrap classified errors with their kind and cause", func(t *testing.T) {
+		err := WrapImageError(libErrs.PlanErrorKind, sentinel, fmt.Errorf("pinging registry: %w", docker.ErrTooManyRequests))
+		assert.Equal(t, err.Kind(), libErrs.RegistryErrorKind)
+		assert.ErrorIs(t, err, sentinel)
+		assert.ErrorIs(t, err, libErrs.ErrRateLimited)
+		assert.ErrorIs(t, err, docker.ErrTooManyRequests)
+	})
+
+	t.Run("should fall back to the given kind", func(t *testing.T) {
+		unknown := errors.New("unknown")
+		for _, kind := range []libErrs.ErrorKind{libErrs.CatalogErrorKind, libErrs.ArchiveErrorKind} {
+			err := WrapImageError(kind, sentinel, unknown)
+			assert.Equal(t, err.Kind(), kind)
+			assert.ErrorIs(t, err, sentinel)
+			assert.ErrorIs(t, err, unknown)
+		}
+	})
+}
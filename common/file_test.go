@@ -0,0 +1,33 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("replacing a file", func(t *testing.T) {
+			assert.NilError(t, WriteFileAtomic(path, []byte("old")))
+			assert.NilError(t, WriteFileAtomic(path, []byte("new")))
+			data, err := os.ReadFile(path)
+			assert.NilError(t, err)
+			assert.Equal(t, string(data), "new")
+			entries, err := os.ReadDir(dir)
+			assert.NilError(t, err)
+			assert.Equal(t, len(entries), 1, "temporary files should be removed")
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the directory is missing", func(t *testing.T) {
+			assert.Assert(t, WriteFileAtomic(filepath.Join(dir, "missing", "data.json"), nil) != nil)
+		})
+	})
+}
@@ -2,13 +2,18 @@
 package common
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/types"
 )
 
 // GetOCIManifest returns the manifest for the given OCI image.
@@ -43,3 +48,16 @@ func GetOCIManifest(ociPath string) (*imgspecv1.Manifest, error) {
 
 	return &imageManifest, nil
 }
+
+// PutBlob writes `data` to the image destination and returns its descriptor.
+// `isConfig` is set for the image config blob, which some destinations store apart from the layers.
+func PutBlob(ctx context.Context, dest types.ImageDestination, mediaType string, data []byte, isConfig bool) (imgspecv1.Descriptor, error) {
+	blob, err := dest.PutBlob(ctx, bytes.NewReader(data), types.BlobInfo{
+		Digest: digest.FromBytes(data),
+		Size:   int64(len(data)),
+	}, none.NoCache, isConfig)
+	if err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	return imgspecv1.Descriptor{MediaType: mediaType, Digest: blob.Digest, Size: blob.Size}, nil
+}
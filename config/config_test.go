@@ -0,0 +1,232 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/catalog"
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/release"
+)
+
+const (
+	validConfig   = "../testdata/config/imageset-config.yaml"
+	invalidConfig = "../testdata/config/invalid-imageset-config.yaml"
+	testCatalog   = "../testdata/config/catalog/configs"
+)
+
+func validationPaths(err error) []string {
+	paths := []string{}
+	var visit func(error)
+	visit = func(err error) {
+		switch e := err.(type) {
+		case *ValidationError:
+			paths = append(paths, e.Path)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				visit(inner)
+			}
+		case interface{ Unwrap() error }:
+			visit(e.Unwrap())
+		}
+	}
+	visit(err)
+	return paths
+}
+
+func bundleNames(bundles []catalog.Bundle) []string {
+	return common.Map(bundles, func(b catalog.Bundle) string { return b.Name })
+}
+
+func TestParse(t *testing.T) {
+	t.Run("should parse a valid configuration", func(t *testing.T) {
+		isc, err := Load(validConfig)
+		assert.NilError(t, err)
+		assert.Equal(t, len(isc.Mirror.Platform.Channels), 3)
+		assert.Equal(t, isc.Mirror.Platform.Channels[0].MinVersion, "4.19.9")
+		assert.Equal(t, len(isc.Mirror.Operators), 2)
+		assert.Equal(t, isc.Mirror.Operators[0].Packages[0].Channels[0].Name, "stable-v26")
+		assert.Equal(t, len(isc.Mirror.AdditionalImages), 2)
+		assert.Equal(t, isc.Mirror.BlockedImages[0].Name, "registry.redhat.io/ubi9/ubi-minimal")
+		assert.Equal(t, isc.Mirror.Helm.Repositories[0].Charts[0].Version, "6.5.0")
+		assert.Equal(t, isc.Mirror.Helm.Local[0].Path, "/tmp/charts/podinfo-6.5.0.tgz")
+	})
+
+	t.Run("should report the path of invalid values", func(t *testing.T) {
+		_, err := Load(invalidConfig)
+		assert.ErrorIs(t, err, libErrs.ErrInvalidConfig)
+		var libErr *libErrs.Error
+		assert.Assert(t, errors.As(err, &libErr))
+		assert.Equal(t, libErr.Kind(), libErrs.ValidationErrorKind)
+		assert.DeepEqual(t, validationPaths(err), []string{
+			"mirror.platform.architectures[0]",
+			"mirror.platform.channels[0].minVersion",
+			"mirror.platform.channels[1].type",
+			"mirror.platform.channels[1].shortestPath",
+			"mirror.platform.channels[2].name",
+			"mirror.operators[0].catalog",
+			"mirror.operators[0].packages[0].bundles",
+			"mirror.operators[0].packages[1].name",
+			"mirror.additionalImages[0].name",
+			"mirror.helm.repositories[0].url",
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		cases := []struct {
+			name string
+			data string
+		}{
+			{
+				name: "there are unknown fields",
+				data: "kind: ImageSetConfiguration\napiVersion: mirror.openshift.io/v2alpha1\nmirror:\n  platfrom: {}\n",
+			},
+			{
+				name: "the kind is wrong",
+				data: "kind: DeleteImageSetConfiguration\napiVersion: mirror.openshift.io/v2alpha1\nmirror: {}\n",
+			},
+			{
+				name: "a value has the wrong type",
+				data: "kind: ImageSetConfiguration\napiVersion: mirror.openshift.io/v2alpha1\nmirror:\n  platform:\n    graph: maybe\n",
+			},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := Parse([]byte(tc.data))
+				assert.ErrorIs(t, err, libErrs.ErrInvalidConfig)
+			})
+		}
+	})
+}
+
+func TestMapping(t *testing.T) {
+	isc, err := Load(validConfig)
+	assert.NilError(t, err)
+
+	t.Run("should map platform channels to release queries", func(t *testing.T) {
+		opts := isc.Mirror.Platform.ReleaseDownloadOptions()
+		assert.Equal(t, len(opts), 6)
+		assert.DeepEqual(t, opts[0], release.DownloadOptions{Endpoint: release.OCPEndpoint, Channel: "stable-4.19", Arch: release.AMD64})
+		assert.DeepEqual(t, opts[5], release.DownloadOptions{Endpoint: release.OKDEndpoint, Channel: "stable-4", Arch: release.ARM64})
+
+		channels := isc.Mirror.Platform.Channels
+		r, err := channels[0].MirrorRange()
+		assert.NilError(t, err)
+		assert.Assert(t, r.From.Equal(semver.MustParse("4.19.9")))
+		assert.Assert(t, r.To.Equal(semver.MustParse("4.19.11")))
+		assert.Equal(t, r.Channel, "stable-4.19")
+		assert.Equal(t, channels[0].MirrorSetOptions().Mode, release.ShortestPathMode)
+		assert.Equal(t, channels[1].MirrorSetOptions().Mode, release.HeadsOnlyMode)
+		assert.Equal(t, channels[2].MirrorSetOptions().Mode, release.FullRangeMode)
+	})
+
+	t.Run("should map operators to catalog downloads", func(t *testing.T) {
+		downloads := isc.CatalogDownloads(catalog.DownloadOptions{DestDir: "/tmp/cache"})
+		assert.Equal(t, len(downloads), 1)
+		assert.Equal(t, downloads[0].Ref, "registry.redhat.io/redhat/redhat-operator-index:v4.19")
+		assert.Equal(t, downloads[0].Options.DestDir, "/tmp/cache")
+		assert.Assert(t, isc.Mirror.Operators[1].IsLocal())
+		assert.Equal(t, isc.Mirror.Operators[1].LocalPath(), "/tmp/catalogs/redhat-operator-index")
+	})
+
+	t.Run("should select operator bundles", func(t *testing.T) {
+		ci, err := catalog.LoadCatalog(context.Background(), testCatalog)
+		assert.NilError(t, err)
+
+		bundles, err := isc.Mirror.Operators[0].SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bundleNames(bundles), []string{
+			"rhbk-operator.v26.2.11-opr.1",
+			"devspacesoperator.v3.10.0",
+			"devworkspace-operator.v0.12.1",
+		})
+
+		bundles, err = Operator{Full: true}.SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.Equal(t, len(bundles), 6)
+
+		bundles, err = Operator{Packages: []Package{{
+			Name:    "rhbk-operator",
+			Bundles: []SelectedBundle{{Name: "rhbk-operator.v26.0.5-opr.1"}},
+		}}}.SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bundleNames(bundles), []string{"rhbk-operator.v26.0.5-opr.1"})
+
+		bundles, err = Operator{Packages: []Package{{Name: "rhbk-operator"}}}.SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bundleNames(bundles), []string{"rhbk-operator.v26.2.11-opr.1"})
+	})
+
+	t.Run("should select operator bundle dependencies", func(t *testing.T) {
+		ci, err := catalog.LoadCatalog(context.Background(), testCatalog)
+		assert.NilError(t, err)
+
+		bundles, err := Operator{Packages: []Package{{Name: "web-terminal"}}}.SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bundleNames(bundles), []string{"web-terminal.v1.9.0", "devworkspace-operator.v0.12.1"})
+
+		bundles, err = Operator{Packages: []Package{{Name: "web-terminal"}, {Name: "devworkspace-operator", MaxVersion: "0.11.0"}}}.SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bundleNames(bundles), []string{"web-terminal.v1.9.0", "devworkspace-operator.v0.11.0"})
+
+		bundles, err = Operator{SkipDependencies: true, Packages: []Package{{Name: "web-terminal"}, {Name: "devspaces"}}}.SelectBundles(ci)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, bundleNames(bundles), []string{"web-terminal.v1.9.0", "devspacesoperator.v3.10.0"})
+	})
+
+	t.Run("should fail to select missing operator content", func(t *testing.T) {
+		ci, err := catalog.LoadCatalog(context.Background(), testCatalog)
+		assert.NilError(t, err)
+
+		for _, op := range []Operator{
+			{Packages: []Package{{Name: "missing-operator"}}},
+			{Packages: []Package{{Name: "rhbk-operator", DefaultChannel: "stable-v27"}}},
+			{Packages: []Package{{Name: "rhbk-operator", Bundles: []SelectedBundle{{Name: "missing"}}}}},
+		} {
+			_, err := op.SelectBundles(ci)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		}
+
+		dir := t.TempDir()
+		assert.NilError(t, os.WriteFile(filepath.Join(dir, "catalog.yaml"), []byte(missingDependencyCatalog), 0o600))
+		ci, err = catalog.LoadCatalog(context.Background(), dir)
+		assert.NilError(t, err)
+		_, err = Operator{}.SelectBundles(ci)
+		assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		_, err = Operator{SkipDependencies: true}.SelectBundles(ci)
+		assert.NilError(t, err)
+	})
+}
+
+const missingDependencyCatalog = `
+schema: olm.package
+name: foo
+defaultChannel: stable
+---
+schema: olm.channel
+name: stable
+package: foo
+entries:
+  - name: foo.v1.0.0
+---
+schema: olm.bundle
+name: foo.v1.0.0
+package: foo
+image: quay.io/example/foo-bundle:v1.0.0
+properties:
+  - type: olm.package
+    value:
+      packageName: foo
+      version: 1.0.0
+  - type: olm.package.required
+    value:
+      packageName: bar
+      versionRange: ">=1.0.0"
+`
@@ -0,0 +1,441 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	blang "github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/r4f4/oc-mirror-libs/catalog"
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/release"
+)
+
+var logger = slog.Default().WithGroup("config")
+
+// GetArchitectures returns the platform architectures, defaulting to amd64.
+func (p Platform) GetArchitectures() []release.Architecture {
+	if len(p.Architectures) == 0 {
+		return []release.Architecture{release.AMD64}
+	}
+	archs := common.Map(p.Architectures, func(a string) release.Architecture { return release.Architecture(a) })
+	slices.Sort(archs)
+	return slices.Compact(archs)
+}
+
+// ReleaseDownloadOptions returns the graph data downloads for the platform channels,
+// one for each channel and architecture.
+func (p Platform) ReleaseDownloadOptions() []release.DownloadOptions {
+	opts := make([]release.DownloadOptions, 0, len(p.Channels)*len(p.GetArchitectures()))
+	for _, ch := range p.Channels {
+		for _, arch := range p.GetArchitectures() {
+			opts = append(opts, release.DownloadOptions{Endpoint: ch.Endpoint(), Channel: ch.Name, Arch: arch})
+		}
+	}
+	return opts
+}
+
+// GetType returns the channel platform type, defaulting to OCPPlatform.
+func (ch ReleaseChannel) GetType() PlatformType {
+	if ch.Type == "" {
+		return OCPPlatform
+	}
+	return ch.Type
+}
+
+// Endpoint returns the Cincinnati endpoint serving the channel.
+func (ch ReleaseChannel) Endpoint() string {
+	if ch.GetType() == OKDPlatform {
+		return release.OKDEndpoint
+	}
+	return release.OCPEndpoint
+}
+
+// MirrorRange returns the release range selected by the channel.
+func (ch ReleaseChannel) MirrorRange() (release.MirrorRange, error) {
+	from, to, err := parseRange(ch.MinVersion, ch.MaxVersion)
+	if err != nil {
+		return release.MirrorRange{}, err
+	}
+	return release.MirrorRange{From: from, To: to, Channel: ch.Name}, nil
+}
+
+// MirrorSetOptions returns how releases are selected in the channel range:
+// the shortest update path, the full range, or, without versions, the latest release of each minor.
+func (ch ReleaseChannel) MirrorSetOptions() release.MirrorSetOptions {
+	switch {
+	case ch.ShortestPath:
+		return release.MirrorSetOptions{Mode: release.ShortestPathMode}
+	case ch.Full, ch.MinVersion != "" || ch.MaxVersion != "":
+		return release.MirrorSetOptions{Mode: release.FullRangeMode}
+	default:
+		return release.MirrorSetOptions{Mode: release.HeadsOnlyMode}
+	}
+}
+
+// IsLocal returns whether the catalog is a local OCI layout.
+func (o Operator) IsLocal() bool {
+	return strings.HasPrefix(o.Catalog, ociPrefix)
+}
+
+// LocalPath returns the path of a local OCI catalog.
+func (o Operator) LocalPath() string {
+	return strings.TrimPrefix(o.Catalog, ociPrefix)
+}
+
+// CatalogDownload is a catalog image to download.
+type CatalogDownload struct {
+	Ref     string
+	Options catalog.DownloadOptions
+}
+
+// CatalogDownloads returns the catalog images to download with `base` options.
+// Local OCI catalogs are skipped.
+func (c *ImageSetConfiguration) CatalogDownloads(base catalog.DownloadOptions) []CatalogDownload {
+	downloads := []CatalogDownload{}
+	seen := sets.New[string]()
+	for _, op := range c.Mirror.Operators {
+		ref := strings.TrimPrefix(op.Catalog, dockerPrefix)
+		if op.IsLocal() || seen.Has(ref) {
+			continue
+		}
+		seen.Insert(ref)
+		downloads = append(downloads, CatalogDownload{Ref: ref, Options: base})
+	}
+	return downloads
+}
+
+// SelectBundles returns the bundles of the catalog selected by the operator, grouped by package and sorted by version.
+// Without packages, every package in the catalog is selected.
+// Unless `SkipDependencies` is set, the bundles the selected ones depend on follow them, see `resolveDependencies`.
+func (o Operator) SelectBundles(ci catalog.CatalogIntrospector) ([]catalog.Bundle, error) {
+	pkgs, err := ci.GetOperators()
+	if err != nil {
+		return nil, err
+	}
+	filters := o.Packages
+	if len(filters) == 0 {
+		filters = common.Map(pkgs, func(p catalog.Package) Package { return Package{Name: p.Name} })
+	}
+
+	bundles := []catalog.Bundle{}
+	for _, filter := range filters {
+		idx := slices.IndexFunc(pkgs, func(p catalog.Package) bool { return p.Name == filter.Name })
+		if idx == -1 {
+			return nil, libErrs.NewCatalogErr(fmt.Errorf("operator %q %w", filter.Name, libErrs.ErrNotFound))
+		}
+		pkgBundles, err := filter.selectBundles(ci, pkgs[idx], o.Full)
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, pkgBundles...)
+	}
+	if o.SkipDependencies {
+		return bundles, nil
+	}
+	deps, err := resolveDependencies(ci, pkgs, bundles)
+	if err != nil {
+		return nil, err
+	}
+	return append(bundles, deps...), nil
+}
+
+// resolveDependencies returns the bundles required by `selected`, and by the returned bundles themselves,
+// grouped by package and sorted by version.
+//
+// A `olm.package.required` dependency is met by a bundle of the package in the version range,
+// otherwise the latest such bundle of any channel is added.
+// A `olm.gvk.required` dependency is met by a bundle providing the API,
+// otherwise the latest bundle providing it is added, taken from the first package by name.
+func resolveDependencies(ci catalog.CatalogIntrospector, pkgs []catalog.Package, selected []catalog.Bundle) ([]catalog.Bundle, error) {
+	r := &dependencyResolver{ci: ci, pkgs: pkgs, bundles: map[string][]versionedBundle{}}
+	have := slices.Clone(selected)
+	queue := slices.Clone(selected)
+	added := []versionedBundle{}
+	for len(queue) > 0 {
+		bdl := queue[0]
+		queue = queue[1:]
+
+		pkgReqs, err := ci.GetDependenciesForBundle(bdl.Package, bdl.Name)
+		if err != nil {
+			return nil, err
+		}
+		gvkReqs, err := bdl.RequiredGVKs()
+		if err != nil {
+			return nil, err
+		}
+		deps := []*versionedBundle{}
+		for _, req := range pkgReqs {
+			dep, err := r.requiredPackage(have, req)
+			if err != nil {
+				return nil, libErrs.NewCatalogErr(fmt.Errorf("bundle %q dependency: %w", bdl.Name, err))
+			}
+			deps = append(deps, dep)
+			if dep != nil {
+				have = append(have, dep.Bundle)
+			}
+		}
+		for _, req := range gvkReqs {
+			dep, err := r.requiredGVK(have, req)
+			if err != nil {
+				return nil, libErrs.NewCatalogErr(fmt.Errorf("bundle %q dependency: %w", bdl.Name, err))
+			}
+			deps = append(deps, dep)
+			if dep != nil {
+				have = append(have, dep.Bundle)
+			}
+		}
+		for _, dep := range deps {
+			if dep == nil {
+				continue
+			}
+			logger.Debug("adding bundle dependency", slog.String("bundle", bdl.Name), slog.String("dependency", dep.Name))
+			added = append(added, *dep)
+			queue = append(queue, dep.Bundle)
+		}
+	}
+
+	slices.SortFunc(added, func(a, b versionedBundle) int {
+		if c := strings.Compare(a.Package, b.Package); c != 0 {
+			return c
+		}
+		return a.version.Compare(b.version)
+	})
+	return common.Map(added, func(b versionedBundle) catalog.Bundle { return b.Bundle }), nil
+}
+
+// dependencyResolver looks up the bundles meeting dependencies, caching the catalog queries.
+type dependencyResolver struct {
+	ci   catalog.CatalogIntrospector
+	pkgs []catalog.Package
+	// bundles are the bundles of all the channels of a package, sorted by version.
+	bundles map[string][]versionedBundle
+	// providers are the bundles providing an API, built on first use.
+	providers map[catalog.GVK][]versionedBundle
+}
+
+// requiredPackage returns the bundle to add for a required package, or nil if a bundle in `have` meets it.
+func (r *dependencyResolver) requiredPackage(have []catalog.Bundle, req catalog.PackageRequired) (*versionedBundle, error) {
+	// NOTE: OLM version ranges, unlike semver constraints, match pre-release versions, e.g. `1.2.0-opr.1`.
+	inRange, err := blang.ParseRange(req.VersionRange)
+	if err != nil {
+		return nil, fmt.Errorf("package %q: %w: %w", req.PackageName, libErrs.ErrParseProperty, err)
+	}
+	matches := func(ver *semver.Version) bool {
+		v, err := blang.Parse(ver.String())
+		return err == nil && inRange(v)
+	}
+	for _, b := range have {
+		if b.Package != req.PackageName {
+			continue
+		}
+		if ver, err := b.Version(); err == nil && matches(ver) {
+			return nil, nil
+		}
+	}
+	bundles, err := r.packageBundles(req.PackageName)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range slices.Backward(bundles) {
+		if matches(b.version) {
+			return &b, nil
+		}
+	}
+	return nil, fmt.Errorf("package %q in range %q %w", req.PackageName, req.VersionRange, libErrs.ErrNotFound)
+}
+
+// requiredGVK returns the bundle to add for a required API, or nil if a bundle in `have` provides it.
+func (r *dependencyResolver) requiredGVK(have []catalog.Bundle, req catalog.GVKRequired) (*versionedBundle, error) {
+	gvk := catalog.GVK{Group: req.Group, Kind: req.Kind, Version: req.Version}
+	for _, b := range have {
+		gvks, err := b.GVKs()
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(gvks, gvk) {
+			return nil, nil
+		}
+	}
+	if r.providers == nil {
+		if err := r.indexProviders(); err != nil {
+			return nil, err
+		}
+	}
+	providers := r.providers[gvk]
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("provider of %s %w", req, libErrs.ErrNotFound)
+	}
+	// Providers are sorted by package and version, so take the latest bundle of the first package.
+	last := 0
+	for last+1 < len(providers) && providers[last+1].Package == providers[0].Package {
+		last++
+	}
+	return &providers[last], nil
+}
+
+// indexProviders indexes the bundles of the catalog by the APIs they provide.
+func (r *dependencyResolver) indexProviders() error {
+	r.providers = map[catalog.GVK][]versionedBundle{}
+	names := common.Map(r.pkgs, func(p catalog.Package) string { return p.Name })
+	slices.Sort(names)
+	for _, name := range names {
+		bundles, err := r.packageBundles(name)
+		if err != nil {
+			return err
+		}
+		for _, b := range bundles {
+			gvks, err := b.GVKs()
+			if err != nil {
+				return err
+			}
+			for _, gvk := range gvks {
+				r.providers[gvk] = append(r.providers[gvk], b)
+			}
+		}
+	}
+	return nil
+}
+
+// packageBundles returns the bundles of all the channels of a package, sorted by version.
+func (r *dependencyResolver) packageBundles(name string) ([]versionedBundle, error) {
+	if bundles, ok := r.bundles[name]; ok {
+		return bundles, nil
+	}
+	if !slices.ContainsFunc(r.pkgs, func(p catalog.Package) bool { return p.Name == name }) {
+		return nil, fmt.Errorf("package %q %w", name, libErrs.ErrNotFound)
+	}
+	channels, err := r.ci.GetChannelsForOperator(name)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]versionedBundle{}
+	for _, ch := range channels {
+		chBundles, err := channelBundles(r.ci, name, ch.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range chBundles {
+			byName[b.Name] = b
+		}
+	}
+	bundles := slices.SortedFunc(maps.Values(byName), func(a, b versionedBundle) int { return a.version.Compare(b.version) })
+	r.bundles[name] = bundles
+	return bundles, nil
+}
+
+// selectBundles returns the bundles of the package selected by the filter, sorted by version:
+//   - selected bundles by name, or
+//   - bundles in the version range of each channel, or
+//   - all bundles of each channel with `full`, or
+//   - the latest bundle of each channel.
+//
+// Channels default to the package default channel, or all channels with `full` or a package version range.
+func (p Package) selectBundles(ci catalog.CatalogIntrospector, pkg catalog.Package, full bool) ([]catalog.Bundle, error) {
+	allChannels, err := ci.GetChannelsForOperator(p.Name)
+	if err != nil {
+		return nil, err
+	}
+	channels := p.Channels
+	switch {
+	case len(channels) > 0:
+	case full || p.MinVersion != "" || p.MaxVersion != "" || len(p.Bundles) > 0:
+		channels = common.Map(allChannels, func(ch catalog.Channel) PackageChannel { return PackageChannel{Name: ch.Name} })
+	default:
+		name := p.DefaultChannel
+		if name == "" {
+			name = pkg.DefaultChannel
+		}
+		channels = []PackageChannel{{Name: name}}
+	}
+
+	selected := map[string]versionedBundle{}
+	for _, ch := range channels {
+		chBundles, err := channelBundles(ci, p.Name, ch.Name)
+		if err != nil {
+			return nil, err
+		}
+		minVer, maxVer := ch.MinVersion, ch.MaxVersion
+		if minVer == "" && maxVer == "" {
+			minVer, maxVer = p.MinVersion, p.MaxVersion
+		}
+		from, to, err := parseRange(minVer, maxVer)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(p.Bundles) > 0:
+			for _, b := range chBundles {
+				if slices.ContainsFunc(p.Bundles, func(s SelectedBundle) bool { return s.Name == b.Name }) {
+					selected[b.Name] = b
+				}
+			}
+		case from != nil || to != nil:
+			for _, b := range chBundles {
+				if (from == nil || !b.version.LessThan(from)) && (to == nil || !b.version.GreaterThan(to)) {
+					selected[b.Name] = b
+				}
+			}
+		case full:
+			for _, b := range chBundles {
+				selected[b.Name] = b
+			}
+		case len(chBundles) > 0:
+			head := chBundles[len(chBundles)-1]
+			selected[head.Name] = head
+		}
+	}
+	for _, s := range p.Bundles {
+		if _, ok := selected[s.Name]; !ok {
+			return nil, libErrs.NewCatalogErr(fmt.Errorf("bundle %q %w", s.Name, libErrs.ErrNotFound))
+		}
+	}
+
+	sorted := slices.SortedFunc(maps.Values(selected), func(a, b versionedBundle) int { return a.version.Compare(b.version) })
+	return common.Map(sorted, func(b versionedBundle) catalog.Bundle { return b.Bundle }), nil
+}
+
+type versionedBundle struct {
+	catalog.Bundle
+	version *semver.Version
+}
+
+// channelBundles returns the bundles of an operator channel sorted by version.
+func channelBundles(ci catalog.CatalogIntrospector, operator, channel string) ([]versionedBundle, error) {
+	bundles, err := ci.GetBundlesForChannel(operator, channel)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]versionedBundle, 0, len(bundles))
+	for _, b := range bundles {
+		ver, err := b.Version()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, versionedBundle{Bundle: b, version: ver})
+	}
+	slices.SortFunc(res, func(a, b versionedBundle) int { return a.version.Compare(b.version) })
+	return res, nil
+}
+
+// parseRange parses the optional bounds of a version range.
+func parseRange(minVer, maxVer string) (*semver.Version, *semver.Version, error) {
+	var bounds [2]*semver.Version
+	for i, ver := range []string{minVer, maxVer} {
+		if ver == "" {
+			continue
+		}
+		var err error
+		if bounds[i], err = semver.NewVersion(ver); err != nil {
+			return nil, nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w", libErrs.ErrInvalidConfig, err))
+		}
+	}
+	return bounds[0], bounds[1], nil
+}
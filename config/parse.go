@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"go.podman.io/image/v5/docker/reference"
+	"sigs.k8s.io/yaml"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/release"
+)
+
+const (
+	dockerPrefix = "docker://"
+	ociPrefix    = "oci://"
+)
+
+var validArchs = []release.Architecture{release.AMD64, release.ARM64, release.S390X, release.PPC64LE, release.MULTI}
+
+// ValidationError is a problem found in an image set configuration.
+type ValidationError struct {
+	// Path is the location of the problem in the YAML document, e.g. `mirror.operators[0].catalog`.
+	Path string
+	// Value is the offending value, if any.
+	Value string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("%s: %q: %s", e.Path, e.Value, e.Err)
+}
+
+func (e *ValidationError) Is(other error) bool {
+	return other == libErrs.ErrInvalidConfig
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+var errRequired = errors.New("required")
+
+// Load reads, parses and validates the image set configuration at `path`.
+func Load(path string) (*ImageSetConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, libErrs.NewErr(libErrs.StorageErrorKind, err)
+	}
+	return Parse(data)
+}
+
+// Parse parses and validates an image set configuration.
+// Unknown fields are rejected. All the problems found are returned joined,
+// and can be inspected with `errors.As` as `*ValidationError`.
+func Parse(data []byte) (*ImageSetConfiguration, error) {
+	var isc ImageSetConfiguration
+	if err := yaml.UnmarshalStrict(data, &isc); err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w", libErrs.ErrInvalidConfig, err))
+	}
+	if err := isc.Validate(); err != nil {
+		return nil, err
+	}
+	return &isc, nil
+}
+
+// Validate checks the image set configuration for problems.
+func (c *ImageSetConfiguration) Validate() error {
+	v := &validator{}
+	if c.Kind != Kind {
+		v.add("kind", c.Kind, fmt.Errorf("expected %q", Kind))
+	}
+	if c.APIVersion != APIVersion {
+		v.add("apiVersion", c.APIVersion, fmt.Errorf("expected %q", APIVersion))
+	}
+	if c.ArchiveSize < 0 {
+		v.add("archiveSize", fmt.Sprint(c.ArchiveSize), errors.New("must not be negative"))
+	}
+	v.platform("mirror.platform", c.Mirror.Platform)
+	for i, op := range c.Mirror.Operators {
+		v.operator(fmt.Sprintf("mirror.operators[%d]", i), op)
+	}
+	for i, img := range c.Mirror.AdditionalImages {
+		v.imageRef(fmt.Sprintf("mirror.additionalImages[%d].name", i), img.Name)
+	}
+	for i, img := range c.Mirror.BlockedImages {
+		v.required(fmt.Sprintf("mirror.blockedImages[%d].name", i), img.Name)
+	}
+	v.helm("mirror.helm", c.Mirror.Helm)
+
+	if len(v.issues) == 0 {
+		return nil
+	}
+	return libErrs.NewErr(libErrs.ValidationErrorKind, errors.Join(v.issues...))
+}
+
+// validator accumulates the problems found in a configuration.
+type validator struct {
+	issues []error
+}
+
+func (v *validator) add(path, value string, err error) {
+	v.issues = append(v.issues, &ValidationError{Path: path, Value: value, Err: err})
+}
+
+func (v *validator) required(path, value string) bool {
+	if value == "" {
+		v.add(path, "", errRequired)
+		return false
+	}
+	return true
+}
+
+// versionRange checks the optional `min` and `max` versions at `path`.
+func (v *validator) versionRange(path, minVer, maxVer string) {
+	var parsed [2]*semver.Version
+	for i, ver := range []struct{ field, value string }{{"minVersion", minVer}, {"maxVersion", maxVer}} {
+		if ver.value == "" {
+			continue
+		}
+		var err error
+		if parsed[i], err = semver.NewVersion(ver.value); err != nil {
+			v.add(path+"."+ver.field, ver.value, err)
+		}
+	}
+	if parsed[0] != nil && parsed[1] != nil && parsed[0].GreaterThan(parsed[1]) {
+		v.add(path+".minVersion", minVer, fmt.Errorf("greater than maxVersion %q", maxVer))
+	}
+}
+
+func (v *validator) imageRef(path, ref string) {
+	if !v.required(path, ref) {
+		return
+	}
+	if ociPath, ok := strings.CutPrefix(ref, ociPrefix); ok {
+		if ociPath == "" {
+			v.add(path, ref, errors.New("missing OCI layout path"))
+		}
+		return
+	}
+	if _, err := reference.ParseNormalizedNamed(strings.TrimPrefix(ref, dockerPrefix)); err != nil {
+		v.add(path, ref, err)
+	}
+}
+
+func (v *validator) platform(path string, p Platform) {
+	for i, arch := range p.Architectures {
+		if !slices.Contains(validArchs, release.Architecture(arch)) {
+			v.add(fmt.Sprintf("%s.architectures[%d]", path, i), arch, fmt.Errorf("expected one of %v", validArchs))
+		}
+	}
+	if p.Release != "" {
+		v.imageRef(path+".release", p.Release)
+	}
+	seen := map[string]int{}
+	for i, ch := range p.Channels {
+		chPath := fmt.Sprintf("%s.channels[%d]", path, i)
+		if v.required(chPath+".name", ch.Name) {
+			key := fmt.Sprintf("%s/%s", ch.GetType(), ch.Name)
+			if first, ok := seen[key]; ok {
+				v.add(chPath+".name", ch.Name, fmt.Errorf("duplicate of %s.channels[%d]", path, first))
+			} else {
+				seen[key] = i
+			}
+		}
+		if ch.Type != "" && ch.Type != OCPPlatform && ch.Type != OKDPlatform {
+			v.add(chPath+".type", string(ch.Type), fmt.Errorf("expected %q or %q", OCPPlatform, OKDPlatform))
+		}
+		if ch.Full && ch.ShortestPath {
+			v.add(chPath+".shortestPath", "", errors.New("cannot be combined with full"))
+		}
+		v.versionRange(chPath, ch.MinVersion, ch.MaxVersion)
+	}
+}
+
+func (v *validator) operator(path string, op Operator) {
+	v.imageRef(path+".catalog", op.Catalog)
+	seen := map[string]int{}
+	for i, pkg := range op.Packages {
+		pkgPath := fmt.Sprintf("%s.packages[%d]", path, i)
+		if v.required(pkgPath+".name", pkg.Name) {
+			if first, ok := seen[pkg.Name]; ok {
+				v.add(pkgPath+".name", pkg.Name, fmt.Errorf("duplicate of %s.packages[%d]", path, first))
+			} else {
+				seen[pkg.Name] = i
+			}
+		}
+		hasVersions := pkg.MinVersion != "" || pkg.MaxVersion != ""
+		v.versionRange(pkgPath, pkg.MinVersion, pkg.MaxVersion)
+		for j, ch := range pkg.Channels {
+			chPath := fmt.Sprintf("%s.channels[%d]", pkgPath, j)
+			v.required(chPath+".name", ch.Name)
+			v.versionRange(chPath, ch.MinVersion, ch.MaxVersion)
+			if ch.MinVersion != "" || ch.MaxVersion != "" {
+				if hasVersions {
+					v.add(chPath, "", errors.New("channel versions cannot be combined with package versions"))
+				}
+				hasVersions = true
+			}
+		}
+		if len(pkg.Bundles) > 0 && hasVersions {
+			v.add(pkgPath+".bundles", "", errors.New("cannot be combined with minVersion or maxVersion"))
+		}
+		if op.Full && hasVersions {
+			v.add(pkgPath, "", errors.New("minVersion and maxVersion cannot be combined with full"))
+		}
+		for j, bdl := range pkg.Bundles {
+			v.required(fmt.Sprintf("%s.bundles[%d].name", pkgPath, j), bdl.Name)
+		}
+	}
+}
+
+func (v *validator) helm(path string, h Helm) {
+	for i, repo := range h.Repositories {
+		repoPath := fmt.Sprintf("%s.repositories[%d]", path, i)
+		v.required(repoPath+".name", repo.Name)
+		if v.required(repoPath+".url", repo.URL) {
+			if u, err := url.Parse(repo.URL); err != nil {
+				v.add(repoPath+".url", repo.URL, err)
+			} else if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "oci" {
+				v.add(repoPath+".url", repo.URL, errors.New("expected an http, https or oci URL"))
+			}
+		}
+		for j, chart := range repo.Charts {
+			v.required(fmt.Sprintf("%s.charts[%d].name", repoPath, j), chart.Name)
+		}
+	}
+	for i, chart := range h.Local {
+		chartPath := fmt.Sprintf("%s.local[%d]", path, i)
+		v.required(chartPath+".name", chart.Name)
+		v.required(chartPath+".path", chart.Path)
+	}
+}
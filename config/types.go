@@ -0,0 +1,116 @@
+// Package config contains type definitions for oc-mirror v2 `ImageSetConfiguration` files.
+package config
+
+const (
+	APIVersion string = "mirror.openshift.io/v2alpha1"
+	Kind       string = "ImageSetConfiguration"
+)
+
+// PlatformType is the OpenShift flavor of a release channel.
+type PlatformType string
+
+const (
+	OCPPlatform PlatformType = "ocp"
+	OKDPlatform PlatformType = "okd"
+)
+
+// ImageSetConfiguration describes the content to mirror.
+type ImageSetConfiguration struct {
+	Kind       string `json:"kind"`
+	APIVersion string `json:"apiVersion"`
+	// ArchiveSize is the maximum size, in GiB, of the archives created for mirror-to-disk.
+	ArchiveSize int    `json:"archiveSize,omitempty"`
+	Mirror      Mirror `json:"mirror"`
+}
+
+// Mirror is the content to mirror.
+type Mirror struct {
+	Platform         Platform   `json:"platform,omitempty"`
+	Operators        []Operator `json:"operators,omitempty"`
+	AdditionalImages []Image    `json:"additionalImages,omitempty"`
+	BlockedImages    []Image    `json:"blockedImages,omitempty"`
+	Helm             Helm       `json:"helm,omitempty"`
+}
+
+// Platform selects the OpenShift releases to mirror.
+type Platform struct {
+	Channels []ReleaseChannel `json:"channels,omitempty"`
+	// Architectures defaults to amd64.
+	Architectures []string `json:"architectures,omitempty"`
+	// Graph requests the graph-data image for the OpenShift Update Service.
+	Graph bool `json:"graph,omitempty"`
+	// Release is a specific release image to mirror.
+	Release           string `json:"release,omitempty"`
+	KubeVirtContainer bool   `json:"kubeVirtContainer,omitempty"`
+}
+
+// ReleaseChannel selects releases in a Cincinnati channel.
+type ReleaseChannel struct {
+	Name string `json:"name"`
+	// Type defaults to OCPPlatform.
+	Type         PlatformType `json:"type,omitempty"`
+	MinVersion   string       `json:"minVersion,omitempty"`
+	MaxVersion   string       `json:"maxVersion,omitempty"`
+	ShortestPath bool         `json:"shortestPath,omitempty"`
+	Full         bool         `json:"full,omitempty"`
+}
+
+// Operator selects operator packages in a catalog.
+type Operator struct {
+	Catalog       string `json:"catalog"`
+	TargetCatalog string `json:"targetCatalog,omitempty"`
+	TargetTag     string `json:"targetTag,omitempty"`
+	Full          bool   `json:"full,omitempty"`
+	// SkipDependencies leaves out the bundles required by the selected ones.
+	SkipDependencies bool      `json:"skipDependencies,omitempty"`
+	Packages         []Package `json:"packages,omitempty"`
+}
+
+// Package selects bundles of an operator package.
+type Package struct {
+	Name           string           `json:"name"`
+	DefaultChannel string           `json:"defaultChannel,omitempty"`
+	MinVersion     string           `json:"minVersion,omitempty"`
+	MaxVersion     string           `json:"maxVersion,omitempty"`
+	Channels       []PackageChannel `json:"channels,omitempty"`
+	Bundles        []SelectedBundle `json:"bundles,omitempty"`
+}
+
+// PackageChannel selects bundles in an operator channel.
+type PackageChannel struct {
+	Name       string `json:"name"`
+	MinVersion string `json:"minVersion,omitempty"`
+	MaxVersion string `json:"maxVersion,omitempty"`
+}
+
+// SelectedBundle is a bundle selected by name.
+type SelectedBundle struct {
+	Name string `json:"name"`
+}
+
+// Image is a container image reference.
+type Image struct {
+	Name string `json:"name"`
+}
+
+// Helm selects helm charts whose images are mirrored.
+type Helm struct {
+	Repositories []Repository `json:"repositories,omitempty"`
+	Local        []Chart      `json:"local,omitempty"`
+}
+
+// Repository is a helm chart repository.
+type Repository struct {
+	Name   string  `json:"name"`
+	URL    string  `json:"url"`
+	Charts []Chart `json:"charts,omitempty"`
+}
+
+// Chart is a helm chart, either from a repository or a local path.
+type Chart struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path,omitempty"`
+	// ImagePaths are additional JSON paths in the rendered manifests that contain images.
+	ImagePaths []string `json:"imagePaths,omitempty"`
+}
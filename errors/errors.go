@@ -4,6 +4,7 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"net/http"
 )
 
 type ErrorKind uint
@@ -11,6 +12,22 @@ type ErrorKind uint
 const (
 	CatalogErrorKind ErrorKind = iota
 	ReleaseErrorKind
+	// RegistryErrorKind is used for registry and network failures.
+	RegistryErrorKind
+	// AuthErrorKind is used for missing or invalid credentials.
+	AuthErrorKind
+	// StorageErrorKind is used for local storage and filesystem failures.
+	StorageErrorKind
+	// ValidationErrorKind is used for invalid user input, such as malformed image references.
+	ValidationErrorKind
+	// PolicyErrorKind is used for images rejected by the signature policy.
+	PolicyErrorKind
+	// PlanErrorKind is used for failures computing the images to mirror.
+	PlanErrorKind
+	// ArchiveErrorKind is used for failures packing, unpacking or verifying mirror archives.
+	ArchiveErrorKind
+	// HelmErrorKind is used for failures loading or rendering helm charts.
+	HelmErrorKind
 )
 
 var (
@@ -23,9 +40,50 @@ var (
 	ErrExtract       = errors.New("cannot extract configs")
 
 	// Release errors
-	ErrParseURL       = errors.New("parse url")
-	ErrParseGraphData = errors.New("cannot parse graph data")
-	ErrUpdateNotFound = fmt.Errorf("update path %w", ErrNotFound)
+	ErrParseURL         = errors.New("parse url")
+	ErrParseGraphData   = errors.New("cannot parse graph data")
+	ErrInvalidGraphData = errors.New("invalid graph data")
+	ErrUpdateNotFound   = fmt.Errorf("update path %w", ErrNotFound)
+	ErrInvalidEUS       = errors.New("invalid EUS update")
+	ErrInvalidRange     = errors.New("invalid version range")
+	ErrAmbiguousArch    = errors.New("ambiguous architecture")
+	ErrBuildImage       = errors.New("cannot build image")
+	ErrVerifySignature  = errors.New("cannot verify signature")
+	ErrReadPayload      = errors.New("cannot read release payload")
+
+	// Registry errors
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrManifestUnknown = fmt.Errorf("manifest %w", ErrNotFound)
+	ErrRateLimited     = errors.New("rate limited")
+	ErrTLS             = errors.New("tls verification failed")
+	ErrNetwork         = errors.New("network error")
+	ErrStorage         = errors.New("storage error")
+	ErrInvalidRef      = errors.New("invalid image reference")
+	ErrPolicy          = errors.New("rejected by policy")
+
+	// Config errors
+	ErrInvalidConfig = errors.New("invalid image set configuration")
+
+	// Plan errors
+	ErrBuildPlan    = errors.New("cannot build mirror plan")
+	ErrMirror       = errors.New("cannot mirror image")
+	ErrParseMapping = errors.New("cannot parse mirror mapping")
+	ErrDelete       = errors.New("cannot delete image")
+
+	// History errors
+	ErrHistory = errors.New("cannot access mirror history")
+
+	// Archive errors
+	ErrPackArchive   = errors.New("cannot pack archive")
+	ErrUnpackArchive = errors.New("cannot unpack archive")
+	ErrCorruptedBlob = errors.New("corrupted blob")
+
+	// Helm errors
+	ErrLoadChart   = errors.New("cannot load chart")
+	ErrRenderChart = errors.New("cannot render chart")
+
+	// Render errors
+	ErrRenderGraph = errors.New("cannot render graph")
 )
 
 type Error struct {
@@ -33,17 +91,40 @@ type Error struct {
 	source error
 }
 
-func (e *Error) Error() string {
-	var kind string
-	switch e.kind {
+func (k ErrorKind) String() string {
+	switch k {
 	case CatalogErrorKind:
-		kind = "catalog error"
+		return "catalog error"
 	case ReleaseErrorKind:
-		kind = "release error"
+		return "release error"
+	case RegistryErrorKind:
+		return "registry error"
+	case AuthErrorKind:
+		return "authentication error"
+	case StorageErrorKind:
+		return "storage error"
+	case ValidationErrorKind:
+		return "validation error"
+	case PolicyErrorKind:
+		return "policy error"
+	case PlanErrorKind:
+		return "plan error"
+	case ArchiveErrorKind:
+		return "archive error"
+	case HelmErrorKind:
+		return "helm error"
 	default:
-		kind = "unknown error"
+		return "unknown error"
 	}
-	return fmt.Sprintf("%s: %s", kind, e.source)
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.kind, e.source)
+}
+
+// Kind returns the kind of the error.
+func (e *Error) Kind() ErrorKind {
+	return e.kind
 }
 
 func NewCatalogErr(src error) *Error {
@@ -54,6 +135,79 @@ func NewReleaseErr(src error) *Error {
 	return &Error{kind: ReleaseErrorKind, source: src}
 }
 
+// NewErr returns an error of the given kind.
+func NewErr(kind ErrorKind, src error) *Error {
+	return &Error{kind: kind, source: src}
+}
+
+// IsTemporary reports whether the operation that returned `err` may succeed when retried.
+func IsTemporary(err error) bool {
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, ErrNetwork) {
+		return true
+	}
+	var statusErr *HTTPStatusError
+	return errors.As(err, &statusErr) && statusErr.Temporary()
+}
+
 func (e *Error) Is(other error) bool {
 	return e == other || errors.Is(e.source, other)
 }
+
+func (e *Error) Unwrap() error {
+	return e.source
+}
+
+// HTTPStatusError is returned when a server replies with an unexpected HTTP status.
+type HTTPStatusError struct {
+	StatusCode int
+	URL        string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected http status %d from %s", e.StatusCode, e.URL)
+}
+
+// Is matches ErrNotFound for 404 responses.
+func (e *HTTPStatusError) Is(other error) bool {
+	return other == ErrNotFound && e.StatusCode == http.StatusNotFound
+}
+
+// Temporary reports whether the request may succeed when retried.
+func (e *HTTPStatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// VersionNotFoundError is returned when a release version is not in the graph data.
+type VersionNotFoundError struct {
+	Version string
+}
+
+func (e *VersionNotFoundError) Error() string {
+	return fmt.Sprintf("%q %s", e.Version, ErrNotFound)
+}
+
+// Is matches ErrNotFound.
+func (e *VersionNotFoundError) Is(other error) bool {
+	return other == ErrNotFound
+}
+
+// GraphInconsistencyError is returned when the update graph contradicts itself,
+// e.g. an edge references a release that is not part of the graph.
+type GraphInconsistencyError struct {
+	From string
+	To   string
+	Err  error
+}
+
+func (e *GraphInconsistencyError) Error() string {
+	return fmt.Sprintf("inconsistent update graph for %s -> %s: %s", e.From, e.To, e.Err)
+}
+
+// Is matches ErrInvalidGraphData.
+func (e *GraphInconsistencyError) Is(other error) bool {
+	return other == ErrInvalidGraphData
+}
+
+func (e *GraphInconsistencyError) Unwrap() error {
+	return e.Err
+}
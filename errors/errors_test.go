@@ -0,0 +1,62 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestError(t *testing.T) {
+	t.Run("should expose the error kind", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", NewCatalogErr(ErrDownload))
+		var libErr *Error
+		assert.Assert(t, errors.As(err, &libErr))
+		assert.Equal(t, libErr.Kind(), CatalogErrorKind)
+		assert.Equal(t, NewReleaseErr(ErrNotFound).Kind(), ReleaseErrorKind)
+		assert.Error(t, NewReleaseErr(ErrNotFound), "release error: not found")
+	})
+
+	t.Run("should match http status errors", func(t *testing.T) {
+		err := NewReleaseErr(&HTTPStatusError{StatusCode: http.StatusNotFound, URL: "https://example.com/graph"})
+		var statusErr *HTTPStatusError
+		assert.Assert(t, errors.As(err, &statusErr))
+		assert.Equal(t, statusErr.StatusCode, http.StatusNotFound)
+		assert.Equal(t, statusErr.URL, "https://example.com/graph")
+		assert.Assert(t, !statusErr.Temporary())
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		for _, code := range []int{http.StatusTooManyRequests, http.StatusBadGateway} {
+			assert.Assert(t, (&HTTPStatusError{StatusCode: code}).Temporary())
+		}
+		assert.Assert(t, !errors.Is(&HTTPStatusError{StatusCode: http.StatusForbidden}, ErrNotFound))
+	})
+
+	t.Run("should match version not found errors", func(t *testing.T) {
+		err := NewReleaseErr(&VersionNotFoundError{Version: "4.19.1"})
+		var notFound *VersionNotFoundError
+		assert.Assert(t, errors.As(err, &notFound))
+		assert.Equal(t, notFound.Version, "4.19.1")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.Error(t, err, `release error: "4.19.1" not found`)
+	})
+
+	t.Run("should match graph inconsistency errors", func(t *testing.T) {
+		cause := errors.New("vertex not found")
+		err := NewReleaseErr(&GraphInconsistencyError{From: "4.19.1", To: "4.19.2", Err: cause})
+		var inconsistent *GraphInconsistencyError
+		assert.Assert(t, errors.As(err, &inconsistent))
+		assert.Equal(t, inconsistent.From, "4.19.1")
+		assert.ErrorIs(t, err, ErrInvalidGraphData)
+		assert.ErrorIs(t, err, cause)
+	})
+
+	t.Run("should report temporary errors", func(t *testing.T) {
+		assert.Assert(t, IsTemporary(NewErr(RegistryErrorKind, fmt.Errorf("%w: %w", ErrDownload, ErrRateLimited))))
+		assert.Assert(t, IsTemporary(NewReleaseErr(&HTTPStatusError{StatusCode: http.StatusServiceUnavailable})))
+		assert.Assert(t, !IsTemporary(NewErr(AuthErrorKind, ErrUnauthorized)))
+		assert.Error(t, NewErr(AuthErrorKind, ErrUnauthorized), "authentication error: unauthorized")
+	})
+}
@@ -0,0 +1,175 @@
+// Package helm discovers the container images of helm charts.
+//
+// Charts are loaded from a local directory or packaged chart, or downloaded from an http repository index.
+// They are rendered offline with the supplied values, and every image reference in the rendered manifests
+// is reported in the same shape as the related images of operator bundles.
+package helm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"sigs.k8s.io/yaml"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+var logger = slog.Default().WithGroup("helm")
+
+// indexFile is the name of the repository index, relative to the repository URL.
+const indexFile = "index.yaml"
+
+// DownloadOptions is used to configure chart downloads.
+type DownloadOptions struct {
+	Client *http.Client
+}
+
+// repoIndex is the subset of a repository index needed to download charts.
+type repoIndex struct {
+	Entries map[string][]chartVersion `json:"entries"`
+}
+
+type chartVersion struct {
+	Version string   `json:"version"`
+	URLs    []string `json:"urls"`
+	// Digest is the hex encoded sha256 of the packaged chart.
+	Digest string `json:"digest,omitempty"`
+}
+
+// LoadChart loads a chart from a local directory or a packaged `.tgz` chart.
+func LoadChart(path string) (*chart.Chart, error) {
+	ch, err := loader.Load(path)
+	if err != nil {
+		return nil, libErrs.NewErr(libErrs.HelmErrorKind, fmt.Errorf("%w: %s: %w", libErrs.ErrLoadChart, path, err))
+	}
+	logger.Debug("loaded chart", slog.String("path", path), slog.String("name", ch.Name()), slog.String("version", ch.Metadata.Version))
+	return ch, nil
+}
+
+// DownloadChart downloads a packaged chart from the `index.yaml` of an http repository.
+// The version is an exact version or a semver constraint, e.g. `~6.5`.
+// An empty version selects the latest stable version.
+func DownloadChart(ctx context.Context, repoURL, name, version string, options DownloadOptions) (*chart.Chart, error) {
+	base, err := url.Parse(strings.TrimSuffix(repoURL, "/") + "/")
+	if err != nil {
+		return nil, newLoadErr(err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: unsupported repository %s, expected an http or https URL", libErrs.ErrLoadChart, base.Redacted()))
+	}
+
+	data, err := download(ctx, base.JoinPath(indexFile), options)
+	if err != nil {
+		return nil, err
+	}
+	var index repoIndex
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, newLoadErr(fmt.Errorf("%s: %w", indexFile, err))
+	}
+	cv, err := selectVersion(index.Entries[name], name, version)
+	if err != nil {
+		return nil, err
+	}
+	if len(cv.URLs) == 0 {
+		return nil, newLoadErr(fmt.Errorf("chart %s-%s has no URL", name, cv.Version))
+	}
+	chartURL, err := base.Parse(cv.URLs[0])
+	if err != nil {
+		return nil, newLoadErr(err)
+	}
+
+	lg := logger.With(slog.String("chart", name), slog.String("version", cv.Version))
+	lg.Debug("download chart", slog.String("GET", chartURL.Redacted()))
+	data, err = download(ctx, chartURL, options)
+	if err != nil {
+		return nil, err
+	}
+	if cv.Digest != "" {
+		sum := sha256.Sum256(data)
+		if actual := hex.EncodeToString(sum[:]); actual != cv.Digest {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %s-%s has digest %s, expected %s",
+				libErrs.ErrLoadChart, libErrs.ErrCorruptedBlob, name, cv.Version, actual, cv.Digest))
+		}
+	}
+	ch, err := loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, newLoadErr(fmt.Errorf("%s-%s: %w", name, cv.Version, err))
+	}
+	lg.Info("downloaded chart")
+	return ch, nil
+}
+
+// selectVersion returns the highest chart version matching the version constraint.
+func selectVersion(versions []chartVersion, name, version string) (*chartVersion, error) {
+	if len(versions) == 0 {
+		return nil, libErrs.NewErr(libErrs.HelmErrorKind, fmt.Errorf("%w: chart %s %w", libErrs.ErrLoadChart, name, libErrs.ErrNotFound))
+	}
+	if version == "" {
+		version = "*"
+	}
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %s version %q: %w", libErrs.ErrLoadChart, name, version, err))
+	}
+
+	var selected *chartVersion
+	var highest *semver.Version
+	for i, cv := range versions {
+		v, err := semver.NewVersion(cv.Version)
+		if err != nil {
+			logger.Debug("skipping invalid chart version", slog.String("chart", name), slog.String("version", cv.Version))
+			continue
+		}
+		if constraint.Check(v) && (highest == nil || v.GreaterThan(highest)) {
+			selected, highest = &versions[i], v
+		}
+	}
+	if selected == nil {
+		return nil, libErrs.NewErr(libErrs.HelmErrorKind, fmt.Errorf("%w: chart %s version %s %w", libErrs.ErrLoadChart, name, version, libErrs.ErrNotFound))
+	}
+	return selected, nil
+}
+
+func download(ctx context.Context, u *url.URL, options DownloadOptions) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, newLoadErr(err)
+	}
+	resp, err := httpClient(options).Do(req)
+	if err != nil {
+		return nil, newLoadErr(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if status := resp.StatusCode; status != http.StatusOK {
+		return nil, newLoadErr(&libErrs.HTTPStatusError{StatusCode: status, URL: req.URL.Redacted()})
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, newLoadErr(err)
+	}
+	return data, nil
+}
+
+func httpClient(options DownloadOptions) *http.Client {
+	if options.Client == nil {
+		logger.Debug("initializing default http client")
+		return &http.Client{}
+	}
+	return options.Client
+}
+
+func newLoadErr(err error) *libErrs.Error {
+	return libErrs.NewErr(libErrs.HelmErrorKind, fmt.Errorf("%w: %w", libErrs.ErrLoadChart, err))
+}
@@ -0,0 +1,129 @@
+package helm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const (
+	testChartDir    = "../testdata/helm/app"
+	testChartTgz    = "../testdata/helm/app-1.2.0.tgz"
+	testUmbrellaDir = "../testdata/helm/umbrella"
+)
+
+// newTestRepository serves an index with the test chart as 1.2.0, a 1.3.0-rc.1 pre-release and a 1.1.0
+// version with a wrong digest.
+func newTestRepository(t *testing.T) *httptest.Server {
+	data, err := os.ReadFile(testChartTgz)
+	assert.NilError(t, err)
+	sum := sha256.Sum256(data)
+	index := fmt.Sprintf(`apiVersion: v1
+entries:
+  app:
+  - name: app
+    version: 1.1.0
+    digest: %[2]s
+    urls: [charts/app-1.2.0.tgz]
+  - name: app
+    version: 1.2.0
+    digest: %[1]s
+    urls: [charts/app-1.2.0.tgz]
+  - name: app
+    version: 1.3.0-rc.1
+    urls: [charts/missing.tgz]
+`, hex.EncodeToString(sum[:]), "0000000000000000000000000000000000000000000000000000000000000000")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stable/index.yaml", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte(index)) })
+	mux.HandleFunc("/stable/charts/app-1.2.0.tgz", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write(data) })
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLoadChart(t *testing.T) {
+	t.Run("should succeed when", func(t *testing.T) {
+		for _, path := range []string{testChartDir, testChartTgz} {
+			t.Run("loading "+path, func(t *testing.T) {
+				ch, err := LoadChart(path)
+				assert.NilError(t, err)
+				assert.Equal(t, ch.Name(), "app")
+				assert.Equal(t, ch.Metadata.Version, "1.2.0")
+			})
+		}
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the chart does not exist", func(t *testing.T) {
+			_, err := LoadChart("../testdata/helm/missing")
+			assert.ErrorIs(t, err, libErrs.ErrLoadChart)
+		})
+	})
+}
+
+func TestDownloadChart(t *testing.T) {
+	ctx := context.Background()
+	srv := newTestRepository(t)
+	repoURL := srv.URL + "/stable"
+
+	t.Run("should succeed when", func(t *testing.T) {
+		for _, version := range []string{"", "1.2.0", "~1.2", ">=1.1 <2"} {
+			t.Run(fmt.Sprintf("selecting version %q", version), func(t *testing.T) {
+				ch, err := DownloadChart(ctx, repoURL, "app", version, DownloadOptions{Client: srv.Client()})
+				assert.NilError(t, err)
+				assert.Equal(t, ch.Metadata.Version, "1.2.0")
+			})
+		}
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the chart is not in the index", func(t *testing.T) {
+			_, err := DownloadChart(ctx, repoURL, "other", "", DownloadOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrLoadChart)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("no version matches", func(t *testing.T) {
+			_, err := DownloadChart(ctx, repoURL, "app", "2.0.0", DownloadOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("the version constraint is invalid", func(t *testing.T) {
+			_, err := DownloadChart(ctx, repoURL, "app", "not-a-version", DownloadOptions{})
+			var libErr *libErrs.Error
+			assert.Assert(t, errors.As(err, &libErr))
+			assert.Equal(t, libErr.Kind(), libErrs.ValidationErrorKind)
+		})
+
+		t.Run("the chart digest does not match", func(t *testing.T) {
+			_, err := DownloadChart(ctx, repoURL, "app", "1.1.0", DownloadOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrCorruptedBlob)
+		})
+
+		t.Run("the chart is missing", func(t *testing.T) {
+			_, err := DownloadChart(ctx, repoURL, "app", "1.3.0-rc.1", DownloadOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("the repository has no index", func(t *testing.T) {
+			_, err := DownloadChart(ctx, srv.URL, "app", "", DownloadOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("the repository is an oci registry", func(t *testing.T) {
+			_, err := DownloadChart(ctx, "oci://registry.example.com/charts", "app", "", DownloadOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrLoadChart)
+		})
+	})
+}
@@ -0,0 +1,295 @@
+package helm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"go.podman.io/image/v5/docker/reference"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/r4f4/oc-mirror-libs/catalog"
+	"github.com/r4f4/oc-mirror-libs/common"
+	"github.com/r4f4/oc-mirror-libs/config"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// defaultNamespace is the release namespace, unless configured.
+const defaultNamespace = "default"
+
+// RenderOptions is used to configure the rendering of a chart.
+type RenderOptions struct {
+	// ReleaseName defaults to the chart name.
+	ReleaseName string
+	// Namespace defaults to `default`.
+	Namespace string
+	// Values override the default values of the chart.
+	Values map[string]any
+	// ImagePaths are additional dotted paths to images in the rendered manifests, e.g. `spec.relatedImages.*.ref`.
+	// A `*` matches all the elements of a list or the values of a map.
+	ImagePaths []string
+}
+
+// Images renders the chart and returns the images referenced by its manifests, sorted by image.
+//
+// An image is either an `image` string, as in pod specs and most custom resources,
+// or an `image` map with `registry`, `repository`, `tag` and `digest` keys.
+// Images are named by their sibling `name` key, e.g. the container name, or else by their resource name.
+// The first name found, walking the manifests sorted by template and key, is kept for duplicate images.
+// Images are normalized to fully qualified references.
+func Images(ch *chart.Chart, opts RenderOptions) ([]catalog.RelatedImage, error) {
+	docs, err := Render(ch, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	images := map[string]catalog.RelatedImage{}
+	add := func(name string, value any) {
+		image, ok := imageValue(value)
+		if !ok {
+			return
+		}
+		named, err := reference.ParseNormalizedNamed(image)
+		if err != nil {
+			logger.Info("skipping invalid image", slog.String("chart", ch.Name()), slog.String("image", image), slog.String("error", err.Error()))
+			return
+		}
+		if _, ok := images[named.String()]; !ok {
+			images[named.String()] = catalog.RelatedImage{Name: name, Image: named.String()}
+		}
+	}
+	for _, doc := range docs {
+		resource := resourceName(doc)
+		walk(doc, resource, add)
+		for _, p := range opts.ImagePaths {
+			for _, value := range lookup(doc, splitPath(p)) {
+				add(resource, value)
+			}
+		}
+	}
+
+	related := slices.Collect(maps.Values(images))
+	slices.SortFunc(related, func(a, b catalog.RelatedImage) int { return strings.Compare(a.Image, b.Image) })
+	logger.Debug("found chart images", slog.String("chart", ch.Name()), slog.Int("images", len(related)))
+	return related, nil
+}
+
+// Render renders the chart templates and returns the rendered manifests, skipping empty documents.
+// As on install, subcharts disabled by their condition or tags are left out, and import-values are
+// merged into their parent values.
+func Render(ch *chart.Chart, opts RenderOptions) ([]map[string]any, error) {
+	if opts.ReleaseName == "" {
+		opts.ReleaseName = ch.Name()
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = defaultNamespace
+	}
+	// NOTE: processing the dependencies changes the chart, so that it can only be rendered once.
+	ch = cloneChart(ch)
+	if err := chartutil.ProcessDependenciesWithMerge(ch, opts.Values); err != nil {
+		return nil, newRenderErr(ch, err)
+	}
+	values, err := chartutil.ToRenderValues(ch, opts.Values, chartutil.ReleaseOptions{
+		Name:      opts.ReleaseName,
+		Namespace: opts.Namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, newRenderErr(ch, err)
+	}
+	rendered, err := engine.Render(ch, values)
+	if err != nil {
+		return nil, newRenderErr(ch, err)
+	}
+
+	docs := []map[string]any{}
+	for _, file := range slices.Sorted(maps.Keys(rendered)) {
+		if ext := path.Ext(file); ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(rendered[file])))
+		for {
+			data, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, newRenderErr(ch, fmt.Errorf("%s: %w", file, err))
+			}
+			var doc map[string]any
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				return nil, newRenderErr(ch, fmt.Errorf("%s: %w", file, err))
+			}
+			if len(doc) > 0 {
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return docs, nil
+}
+
+// ConfigImages returns the images of all the charts of the configuration, rendered with their default values.
+// Images are listed once, with the name found in the first chart using them, in configuration order.
+func ConfigImages(ctx context.Context, h config.Helm, options DownloadOptions) ([]catalog.RelatedImage, error) {
+	images := []catalog.RelatedImage{}
+	seen := sets.New[string]()
+	render := func(ch *chart.Chart, c config.Chart) error {
+		found, err := Images(ch, RenderOptions{ImagePaths: c.ImagePaths})
+		if err != nil {
+			return err
+		}
+		for _, img := range found {
+			if !seen.Has(img.Image) {
+				seen.Insert(img.Image)
+				images = append(images, img)
+			}
+		}
+		return nil
+	}
+	for _, repo := range h.Repositories {
+		for _, c := range repo.Charts {
+			ch, err := DownloadChart(ctx, repo.URL, c.Name, c.Version, options)
+			if err != nil {
+				return nil, err
+			}
+			if err := render(ch, c); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, c := range h.Local {
+		ch, err := LoadChart(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := render(ch, c); err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// walk calls `add` for every `image` key of the node, named by its sibling `name` key or else by `resource`.
+func walk(node any, resource string, add func(name string, value any)) {
+	switch n := node.(type) {
+	case map[string]any:
+		if value, ok := n["image"]; ok {
+			name, ok := n["name"].(string)
+			if !ok || name == "" {
+				name = resource
+			}
+			add(name, value)
+		}
+		for _, key := range slices.Sorted(maps.Keys(n)) {
+			walk(n[key], resource, add)
+		}
+	case []any:
+		for _, child := range n {
+			walk(child, resource, add)
+		}
+	}
+}
+
+// imageValue returns the image of an `image` string or map.
+func imageValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case map[string]any:
+		repository, _ := v["repository"].(string)
+		if repository == "" {
+			return "", false
+		}
+		image := repository
+		if registry, _ := v["registry"].(string); registry != "" {
+			image = registry + "/" + repository
+		}
+		if dgst, _ := v["digest"].(string); dgst != "" {
+			return image + "@" + dgst, true
+		}
+		if tag, _ := v["tag"].(string); tag != "" {
+			return image + ":" + tag, true
+		}
+		return image, true
+	}
+	return "", false
+}
+
+// lookup returns the values at the path of the node.
+func lookup(node any, segments []string) []any {
+	if len(segments) == 0 {
+		return []any{node}
+	}
+	segment, rest := segments[0], segments[1:]
+	values := []any{}
+	switch n := node.(type) {
+	case map[string]any:
+		if segment == "*" {
+			for _, key := range slices.Sorted(maps.Keys(n)) {
+				values = append(values, lookup(n[key], rest)...)
+			}
+		} else if child, ok := n[segment]; ok {
+			values = append(values, lookup(child, rest)...)
+		}
+	case []any:
+		if segment == "*" {
+			for _, child := range n {
+				values = append(values, lookup(child, rest)...)
+			}
+		}
+	}
+	return values
+}
+
+// splitPath splits a dotted path, accepting the `$.` prefix and `[*]` wildcards of JSON paths.
+func splitPath(p string) []string {
+	p = strings.ReplaceAll(p, "[*]", ".*")
+	p = strings.TrimPrefix(strings.TrimPrefix(p, "$"), ".")
+	return strings.Split(p, ".")
+}
+
+// resourceName returns the name of a rendered resource.
+func resourceName(doc map[string]any) string {
+	metadata, _ := doc["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+// cloneChart copies the parts of the chart tree changed by dependency processing:
+// the dependencies metadata, the subcharts and the values.
+func cloneChart(ch *chart.Chart) *chart.Chart {
+	clone := *ch
+	if ch.Metadata != nil {
+		metadata := *ch.Metadata
+		if ch.Metadata.Dependencies != nil {
+			metadata.Dependencies = common.Map(ch.Metadata.Dependencies, func(d *chart.Dependency) *chart.Dependency {
+				if d == nil {
+					return nil
+				}
+				dep := *d
+				return &dep
+			})
+		}
+		clone.Metadata = &metadata
+	}
+	clone.Values = maps.Clone(ch.Values)
+	clone.SetDependencies(common.Map(ch.Dependencies(), cloneChart)...)
+	return &clone
+}
+
+func newRenderErr(ch *chart.Chart, err error) *libErrs.Error {
+	return libErrs.NewErr(libErrs.HelmErrorKind, fmt.Errorf("%w: %s: %w", libErrs.ErrRenderChart, ch.Name(), err))
+}
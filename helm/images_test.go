@@ -0,0 +1,109 @@
+package helm
+
+import (
+	"context"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/catalog"
+	"github.com/r4f4/oc-mirror-libs/config"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const backupImage = "docker.io/example/backup@sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196"
+
+func TestImages(t *testing.T) {
+	ch, err := LoadChart(testChartDir)
+	assert.NilError(t, err)
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("rendering the default values", func(t *testing.T) {
+			images, err := Images(ch, RenderOptions{})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, images, []catalog.RelatedImage{
+				{Name: "backup", Image: backupImage},
+				{Name: "proxy", Image: "docker.io/library/nginx:1.27"},
+				{Name: "app-config", Image: "quay.io/example/app:v1.2.0"},
+				{Name: "postgres", Image: "registry.example.com/example/postgres:16"},
+			})
+		})
+
+		t.Run("rendering supplied values and image paths", func(t *testing.T) {
+			images, err := Images(ch, RenderOptions{
+				ReleaseName: "prod",
+				Values: map[string]any{
+					"image":  map[string]any{"tag": "v1.3.0"},
+					"backup": map[string]any{"enabled": false},
+				},
+				ImagePaths: []string{"$.spec.plugins[*].ref", "spec.missing.*"},
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, images, []catalog.RelatedImage{
+				{Name: "proxy", Image: "docker.io/library/nginx:1.27"},
+				{Name: "prod-config", Image: "quay.io/example/app:v1.3.0"},
+				{Name: "prod-config", Image: "quay.io/example/metrics-plugin:v0.3.0"},
+				{Name: "postgres", Image: "registry.example.com/example/postgres:16"},
+			})
+		})
+
+		t.Run("rendering a packaged chart", func(t *testing.T) {
+			packaged, err := LoadChart(testChartTgz)
+			assert.NilError(t, err)
+			docs, err := Render(packaged, RenderOptions{Namespace: "apps"})
+			assert.NilError(t, err)
+			kinds := []string{}
+			for _, doc := range docs {
+				kinds = append(kinds, doc["kind"].(string))
+			}
+			assert.DeepEqual(t, kinds, []string{"AppConfig", "CronJob", "Deployment", "StatefulSet"})
+			assert.Equal(t, docs[2]["metadata"].(map[string]any)["namespace"], "apps")
+		})
+
+		t.Run("listing the images of a configuration", func(t *testing.T) {
+			srv := newTestRepository(t)
+			images, err := ConfigImages(context.Background(), config.Helm{
+				Repositories: []config.Repository{{Name: "stable", URL: srv.URL + "/stable", Charts: []config.Chart{{Name: "app", Version: "1.2.0"}}}},
+				Local: []config.Chart{
+					{Name: "app", Path: testChartDir, ImagePaths: []string{"spec.plugins.*.ref"}},
+					{Name: "umbrella", Path: testUmbrellaDir},
+				},
+			}, DownloadOptions{Client: srv.Client()})
+			assert.NilError(t, err)
+			// the images of the repository chart are listed once, along with the plugin and subchart images
+			assert.Equal(t, len(images), 7)
+			assert.DeepEqual(t, images[4:], []catalog.RelatedImage{
+				{Name: "app-config", Image: "quay.io/example/metrics-plugin:v0.3.0"},
+				{Name: "redis", Image: "docker.io/library/redis:7.4"},
+				{Name: "exporter", Image: "quay.io/example/redis-exporter:v1.62.0"},
+			})
+		})
+
+		t.Run("rendering subcharts", func(t *testing.T) {
+			umbrella, err := LoadChart(testUmbrellaDir)
+			assert.NilError(t, err)
+			images, err := Images(umbrella, RenderOptions{
+				Values: map[string]any{"cache": map[string]any{"enabled": false}, "tags": map[string]any{"monitoring": true}},
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, images, []catalog.RelatedImage{
+				{Name: "collector", Image: "quay.io/example/metrics-collector:v2.1.0"},
+			})
+
+			// the disabled subchart is still rendered with other values
+			images, err = Images(umbrella, RenderOptions{})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, images, []catalog.RelatedImage{
+				{Name: "redis", Image: "docker.io/library/redis:7.4"},
+				{Name: "exporter", Image: "quay.io/example/redis-exporter:v1.62.0"},
+			})
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the values break the templates", func(t *testing.T) {
+			_, err := Images(ch, RenderOptions{Values: map[string]any{"plugins": "not-a-list"}})
+			assert.ErrorIs(t, err, libErrs.ErrRenderChart)
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+var logger = slog.Default().WithGroup("history")
+
+// Run is the record of what was mirrored by a mirror run.
+type Run struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"`
+	// Catalogs are the mirrored catalog digests, by catalog source.
+	Catalogs map[string]digest.Digest `json:"catalogs,omitempty"`
+	// Bundles are the names of the mirrored bundles.
+	Bundles []string `json:"bundles,omitempty"`
+	// Releases are the mirrored release versions.
+	Releases []string `json:"releases,omitempty"`
+	// Images are the mirrored plan entries.
+	Images []plan.Entry `json:"images"`
+	// Digests are the manifest digests the images mirrored by tag resolved to, by source.
+	Digests map[string]digest.Digest `json:"digests,omitempty"`
+}
+
+// ResolveFunc returns the manifest digest the source of a plan entry currently resolves to,
+// e.g. with `plan.ResolveDigest`.
+type ResolveFunc func(ctx context.Context, e plan.Entry) (digest.Digest, error)
+
+// History is the list of mirror runs, from oldest to newest.
+type History struct {
+	Runs []Run `json:"runs"`
+}
+
+// NewRun returns the record of a mirror run of the plan.
+// The plan must be the full plan of the run, not the delta, without the entries that failed to mirror.
+func NewRun(p *plan.Plan, t time.Time) Run {
+	run := Run{Time: t.UTC(), Target: p.Target, Catalogs: map[string]digest.Digest{}, Images: slices.Clone(p.Entries)}
+	bundles, releases := sets.New[string](), sets.New[string]()
+	for _, e := range p.Entries {
+		switch {
+		case e.Type == plan.CatalogImage:
+			run.Catalogs[e.Source] = e.Digest
+		case e.Type == plan.ReleasePayloadImage:
+			releases.Insert(e.RequiredBy...)
+		case e.Origin == plan.OperatorOrigin:
+			// bundle and related images are required by bundle names
+			bundles.Insert(e.RequiredBy...)
+		}
+	}
+	run.Bundles, run.Releases = sets.List(bundles), sets.List(releases)
+	return run
+}
+
+// SetDigests records the digests the images mirrored by tag resolved to, from the execution results of the run.
+func (r *Run) SetDigests(results []plan.Result) {
+	for _, res := range results {
+		if res.Err != nil || res.Entry.Digest != "" || res.Digest == "" {
+			continue
+		}
+		if r.Digests == nil {
+			r.Digests = map[string]digest.Digest{}
+		}
+		r.Digests[res.Entry.Source] = res.Digest
+	}
+}
+
+// Plan returns the plan mirrored by the run.
+// Images mirrored by tag have the digest they resolved to, when known.
+func (r *Run) Plan() *plan.Plan {
+	return &plan.Plan{Target: r.Target, Entries: r.withDigests(r.Images)}
+}
+
+// withDigests returns a copy of the entries, with the digests the images mirrored by tag resolved to.
+func (r *Run) withDigests(entries []plan.Entry) []plan.Entry {
+	entries = slices.Clone(entries)
+	for i, e := range entries {
+		if e.Digest == "" {
+			entries[i].Digest = r.Digests[e.Source]
+		}
+	}
+	return entries
+}
+
+// Latest returns the most recent run, or nil if there is none.
+func (h *History) Latest() *Run {
+	if len(h.Runs) == 0 {
+		return nil
+	}
+	return &h.Runs[len(h.Runs)-1]
+}
+
+// Record appends the run to the history.
+// Images by tag that were not mirrored again keep the digest they resolved to in the latest run.
+func (h *History) Record(run Run) {
+	if latest := h.Latest(); latest != nil && latest.Target == run.Target {
+		for _, e := range run.Images {
+			if _, ok := run.Digests[e.Source]; ok || e.Digest != "" {
+				continue
+			}
+			if dgst, ok := latest.Digests[e.Source]; ok {
+				if run.Digests == nil {
+					run.Digests = map[string]digest.Digest{}
+				}
+				run.Digests[e.Source] = dgst
+			}
+		}
+	}
+	h.Runs = append(h.Runs, run)
+	logger.Debug("recorded mirror run", slog.Time("time", run.Time), slog.Int("images", len(run.Images)))
+}
+
+// Delta returns the plan entries that were not mirrored by the latest run.
+// Images by tag are mirrored again unless their source still resolves to the digest recorded by the latest run.
+// Without a previous run, the whole plan is returned.
+func (h *History) Delta(ctx context.Context, p *plan.Plan, resolve ResolveFunc) (*plan.Plan, error) {
+	latest := h.Latest()
+	if latest == nil || latest.Target != p.Target {
+		return p, nil
+	}
+
+	tags := sets.New[string]()
+	for _, e := range p.Entries {
+		if e.Digest == "" {
+			tags.Insert(e.Source)
+		}
+	}
+	resolved := map[string]digest.Digest{}
+	mirrored := &plan.Plan{Target: latest.Target}
+	for _, e := range latest.Images {
+		if e.Digest == "" && tags.Has(e.Source) {
+			dgst, ok := resolved[e.Source]
+			if !ok {
+				var err error
+				if dgst, err = resolve(ctx, e); err != nil {
+					return nil, newHistoryErr(err)
+				}
+				resolved[e.Source] = dgst
+			}
+			if latest.Digests[e.Source] != dgst {
+				logger.Debug("image tag changed", slog.String("source", e.Source), slog.String("digest", dgst.String()))
+				continue
+			}
+		}
+		mirrored.Entries = append(mirrored.Entries, e)
+	}
+	return &plan.Plan{Target: p.Target, Entries: p.Diff(mirrored).Added}, nil
+}
+
+// Unreferenced returns the entries mirrored by the latest run that are no longer in the plan.
+func (h *History) Unreferenced(p *plan.Plan) []plan.Entry {
+	latest := h.Latest()
+	if latest == nil || latest.Target != p.Target {
+		return nil
+	}
+	// NOTE: entries are compared as planned, since images by tag have no digest in the plan.
+	return latest.withDigests(p.Diff(&plan.Plan{Target: latest.Target, Entries: latest.Images}).Removed)
+}
+
+// CatalogChanges returns the catalogs whose digest differs from the latest run, including new catalogs.
+func (h *History) CatalogChanges(p *plan.Plan) []string {
+	previous := map[string]digest.Digest{}
+	if latest := h.Latest(); latest != nil {
+		previous = latest.Catalogs
+	}
+	current := NewRun(p, time.Time{}).Catalogs
+	changed := []string{}
+	for _, src := range slices.Sorted(maps.Keys(current)) {
+		if previous[src] != current[src] {
+			changed = append(changed, src)
+		}
+	}
+	return changed
+}
+
+// DeletePlan returns the manifests mirrored by the latest run that can be deleted from the target registry
+// because the plan no longer references them, see `plan.NewDeletePlan`.
+func (h *History) DeletePlan(ctx context.Context, p *plan.Plan, target plan.TargetReader) (*plan.DeletePlan, error) {
+	latest := h.Latest()
+	if latest == nil || latest.Target != p.Target {
+		return &plan.DeletePlan{Target: p.Target}, nil
+	}
+	return plan.NewDeletePlan(ctx, latest.Plan(), p, target)
+}
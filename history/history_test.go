@@ -0,0 +1,191 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+const (
+	testTarget = "registry.example.com:5000/mirror"
+	testIndex  = "registry.redhat.io/redhat/redhat-operator-index:v4.19"
+)
+
+var (
+	payload = plan.Entry{
+		Source:      "quay.io/openshift-release-dev/ocp-release@sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196",
+		Destination: testTarget + "/openshift-release-dev/ocp-release:4.19.1-x86_64",
+		Digest:      "sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196",
+		Origin:      plan.ReleaseOrigin,
+		Type:        plan.ReleasePayloadImage,
+		RequiredBy:  []string{"4.19.1"},
+	}
+	catalogV1 = plan.Entry{Source: testIndex, Destination: testTarget + "/redhat/redhat-operator-index:v4.19", Digest: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Origin: plan.OperatorOrigin, Type: plan.CatalogImage}
+	bundle    = plan.Entry{
+		Source:      "registry.redhat.io/rhbk/keycloak-operator-bundle@sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		Destination: testTarget + "/rhbk/keycloak-operator-bundle@sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		Digest:      "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb",
+		Origin:      plan.OperatorOrigin,
+		Type:        plan.BundleImage,
+		RequiredBy:  []string{"rhbk-operator.v26.2.11-opr.1"},
+	}
+	ubiDigest    = digest.Digest("sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd")
+	newUBIDigest = digest.Digest("sha256:eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+	ubi          = plan.Entry{Source: "registry.redhat.io/ubi9/ubi:latest", Destination: testTarget + "/ubi9/ubi:latest", Origin: plan.AdditionalOrigin, Type: plan.AdditionalImage}
+)
+
+func TestNewRun(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	run := NewRun(&plan.Plan{Target: testTarget, Entries: []plan.Entry{payload, catalogV1, bundle, ubi}}, now)
+	assert.Equal(t, run.Time, now.UTC())
+	assert.Equal(t, run.Target, testTarget)
+	assert.DeepEqual(t, run.Catalogs, map[string]digest.Digest{testIndex: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"})
+	assert.DeepEqual(t, run.Bundles, []string{"rhbk-operator.v26.2.11-opr.1"})
+	assert.DeepEqual(t, run.Releases, []string{"4.19.1"})
+	assert.Equal(t, len(run.Images), 4)
+	assert.DeepEqual(t, run.Plan(), &plan.Plan{Target: testTarget, Entries: []plan.Entry{payload, catalogV1, bundle, ubi}})
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	catalogV2 := catalogV1
+	catalogV2.Digest = "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	first := &plan.Plan{Target: testTarget, Entries: []plan.Entry{payload, catalogV1, bundle}}
+	second := &plan.Plan{Target: testTarget, Entries: []plan.Entry{payload, catalogV2, ubi}}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("there is no previous run", func(t *testing.T) {
+			h := &History{}
+			assert.Assert(t, h.Latest() == nil)
+			delta, err := h.Delta(ctx, first, resolveTo(ubiDigest))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, delta, first)
+			assert.Equal(t, len(h.Unreferenced(first)), 0)
+			d, err := h.DeletePlan(ctx, first, emptyTarget{})
+			assert.NilError(t, err)
+			assert.Equal(t, len(d.Delete), 0)
+			assert.DeepEqual(t, h.CatalogChanges(first), []string{testIndex})
+		})
+
+		t.Run("computing the delta with the latest run", func(t *testing.T) {
+			h := &History{}
+			h.Record(NewRun(first, time.Now()))
+			h.Record(NewRun(first, time.Now()))
+			assert.Equal(t, len(h.Runs), 2)
+			delta, err := h.Delta(ctx, first, resolveTo(ubiDigest))
+			assert.NilError(t, err)
+			assert.Equal(t, len(delta.Entries), 0)
+			assert.DeepEqual(t, h.CatalogChanges(first), []string{})
+
+			delta, err = h.Delta(ctx, second, resolveTo(ubiDigest))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, delta.Entries, []plan.Entry{catalogV2, ubi})
+			assert.DeepEqual(t, h.Unreferenced(second), []plan.Entry{catalogV1, bundle})
+			assert.DeepEqual(t, h.CatalogChanges(second), []string{testIndex})
+
+			d, err := h.DeletePlan(ctx, second, emptyTarget{})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, common.Map(d.Delete, func(e plan.DeleteEntry) string { return e.Reference }), []string{testTarget + "/redhat/redhat-operator-index@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", testTarget + "/rhbk/keycloak-operator-bundle@sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"})
+		})
+
+		t.Run("images by tag are updated", func(t *testing.T) {
+			h := &History{}
+			run := NewRun(second, time.Now())
+			run.SetDigests([]plan.Result{
+				{Entry: ubi, Status: plan.MirroredStatus, Digest: ubiDigest},
+				{Entry: payload, Status: plan.MirroredStatus, Digest: payload.Digest},
+			})
+			assert.DeepEqual(t, run.Digests, map[string]digest.Digest{ubi.Source: ubiDigest})
+			h.Record(run)
+
+			delta, err := h.Delta(ctx, second, resolveTo(ubiDigest))
+			assert.NilError(t, err)
+			assert.Equal(t, len(delta.Entries), 0)
+			// the tag still resolves to the same digest in a run that doesn't mirror it again
+			h.Record(NewRun(second, time.Now()))
+			assert.DeepEqual(t, h.Latest().Digests, map[string]digest.Digest{ubi.Source: ubiDigest})
+
+			delta, err = h.Delta(ctx, second, resolveTo(newUBIDigest))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, delta.Entries, []plan.Entry{ubi})
+		})
+
+		t.Run("an image mirrored by tag is no longer mirrored", func(t *testing.T) {
+			h := &History{}
+			run := NewRun(second, time.Now())
+			run.SetDigests([]plan.Result{{Entry: ubi, Status: plan.MirroredStatus, Digest: ubiDigest}})
+			h.Record(run)
+			withUBIDigest := ubi
+			withUBIDigest.Digest = ubiDigest
+			assert.DeepEqual(t, h.Latest().Plan().Entries, []plan.Entry{payload, catalogV2, withUBIDigest})
+
+			third := &plan.Plan{Target: testTarget, Entries: []plan.Entry{payload, catalogV2}}
+			assert.DeepEqual(t, h.Unreferenced(third), []plan.Entry{withUBIDigest})
+			assert.Equal(t, len(h.Unreferenced(second)), 0)
+			d, err := h.DeletePlan(ctx, third, emptyTarget{})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, d.Delete, []plan.DeleteEntry{{
+				Reference: testTarget + "/ubi9/ubi@" + ubiDigest.String(),
+				Digest:    ubiDigest,
+				Tags:      []string{"latest"},
+				Origin:    plan.AdditionalOrigin,
+				Type:      plan.AdditionalImage,
+			}})
+			assert.Equal(t, len(d.Skipped), 0)
+		})
+
+		t.Run("the latest run has no digest for an image by tag", func(t *testing.T) {
+			h := &History{}
+			h.Record(NewRun(second, time.Now()))
+			delta, err := h.Delta(ctx, second, resolveTo(ubiDigest))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, delta.Entries, []plan.Entry{ubi})
+		})
+
+		t.Run("the target changed", func(t *testing.T) {
+			h := &History{}
+			h.Record(NewRun(first, time.Now()))
+			other := &plan.Plan{Target: "other.example.com", Entries: first.Entries}
+			delta, err := h.Delta(ctx, other, resolveTo(ubiDigest))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, delta, other)
+			assert.Equal(t, len(h.Unreferenced(other)), 0)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("an image tag can't be resolved", func(t *testing.T) {
+			h := &History{}
+			h.Record(NewRun(second, time.Now()))
+			_, err := h.Delta(ctx, second, func(context.Context, plan.Entry) (digest.Digest, error) {
+				return "", libErrs.ErrManifestUnknown
+			})
+			assert.ErrorIs(t, err, libErrs.ErrHistory)
+		})
+	})
+}
+
+// emptyTarget is a TargetReader for an empty target registry.
+type emptyTarget struct{}
+
+func (emptyTarget) Digest(context.Context, string) (digest.Digest, error) {
+	return "", nil
+}
+
+func (emptyTarget) Instances(context.Context, string) ([]digest.Digest, error) {
+	return nil, nil
+}
+
+// resolveTo returns a ResolveFunc resolving every image to `dgst`.
+func resolveTo(dgst digest.Digest) ResolveFunc {
+	return func(context.Context, plan.Entry) (digest.Digest, error) {
+		return dgst, nil
+	}
+}
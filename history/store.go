@@ -0,0 +1,177 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/types"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const (
+	// HistoryFile is the name of the history file in the working directory.
+	HistoryFile = "mirror-history.json"
+	// HistoryMediaType is the media type of the history layer and artifact stored in a registry.
+	HistoryMediaType = "application/vnd.oc-mirror.history.v1+json"
+)
+
+// Store loads and saves the mirror history.
+// Loading a history that was never saved returns an empty history.
+type Store interface {
+	Load(ctx context.Context) (*History, error)
+	Save(ctx context.Context, h *History) error
+}
+
+// FileStore stores the history as a JSON file.
+type FileStore struct {
+	Path string
+}
+
+// NewFileStore returns a store for the history file in the `dir` working directory.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Path: filepath.Join(dir, HistoryFile)}
+}
+
+func (s *FileStore) Load(_ context.Context) (*History, error) {
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &History{}, nil
+	}
+	if err != nil {
+		return nil, newHistoryErr(err)
+	}
+	return decode(data)
+}
+
+// Save writes the history to a temporary file renamed over the history file,
+// so that an interrupted save never leaves a truncated history.
+func (s *FileStore) Save(_ context.Context, h *History) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return newHistoryErr(err)
+	}
+	if err := common.WriteFileAtomic(s.Path, data); err != nil {
+		return newHistoryErr(err)
+	}
+	logger.Info("saved mirror history", slog.String("path", s.Path))
+	return nil
+}
+
+// RegistryStore stores the history as an OCI artifact in the target registry,
+// so that every client mirroring to the registry shares it.
+type RegistryStore struct {
+	// Ref is the artifact reference, e.g. `registry.example.com/mirror/oc-mirror-history:latest`.
+	Ref       string
+	SystemCtx *types.SystemContext
+}
+
+func (s *RegistryStore) Load(ctx context.Context) (*History, error) {
+	ref, err := docker.ParseReference("//" + s.Ref)
+	if err != nil {
+		return nil, newHistoryErr(err)
+	}
+	src, err := ref.NewImageSource(ctx, s.SystemCtx)
+	if err != nil {
+		if _, cause, _ := common.ClassifyImageError(err); errors.Is(cause, libErrs.ErrManifestUnknown) {
+			return &History{}, nil
+		}
+		return nil, newHistoryErr(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	rawManifest, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		if _, cause, _ := common.ClassifyImageError(err); errors.Is(cause, libErrs.ErrManifestUnknown) {
+			return &History{}, nil
+		}
+		return nil, newHistoryErr(err)
+	}
+	var m imgspecv1.Manifest
+	if err := json.Unmarshal(rawManifest, &m); err != nil {
+		return nil, newHistoryErr(err)
+	}
+	if m.ArtifactType != HistoryMediaType || len(m.Layers) != 1 {
+		return nil, newHistoryErr(fmt.Errorf("%s is not a mirror history artifact", s.Ref))
+	}
+
+	reader, _, err := src.GetBlob(ctx, types.BlobInfo{Digest: m.Layers[0].Digest, Size: m.Layers[0].Size}, none.NoCache)
+	if err != nil {
+		return nil, newHistoryErr(err)
+	}
+	defer func() { _ = reader.Close() }()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, newHistoryErr(err)
+	}
+	return decode(data)
+}
+
+func (s *RegistryStore) Save(ctx context.Context, h *History) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	ref, err := docker.ParseReference("//" + s.Ref)
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	dest, err := ref.NewImageDestination(ctx, s.SystemCtx)
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	defer func() { _ = dest.Close() }()
+
+	configDesc, err := common.PutBlob(ctx, dest, imgspecv1.MediaTypeEmptyJSON, imgspecv1.DescriptorEmptyJSON.Data, true)
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	layerDesc, err := common.PutBlob(ctx, dest, HistoryMediaType, data, false)
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	rawManifest, err := json.Marshal(imgspecv1.Manifest{
+		Versioned:    imgspecs.Versioned{SchemaVersion: 2},
+		MediaType:    imgspecv1.MediaTypeImageManifest,
+		ArtifactType: HistoryMediaType,
+		Config:       configDesc,
+		Layers:       []imgspecv1.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return newHistoryErr(err)
+	}
+	if err := dest.PutManifest(ctx, rawManifest, nil); err != nil {
+		return newHistoryErr(err)
+	}
+	if err := dest.Commit(ctx, nil); err != nil {
+		return newHistoryErr(err)
+	}
+	logger.Info("saved mirror history", slog.String("ref", s.Ref))
+	return nil
+}
+
+func decode(data []byte) (*History, error) {
+	var h History
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w", libErrs.ErrHistory, err))
+	}
+	return &h, nil
+}
+
+func newHistoryErr(err error) *libErrs.Error {
+	return common.WrapImageError(libErrs.PlanErrorKind, libErrs.ErrHistory, err)
+}
@@ -0,0 +1,92 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.podman.io/image/v5/types"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/internal/testregistry"
+	"github.com/r4f4/oc-mirror-libs/plan"
+)
+
+func testHistory() *History {
+	h := &History{}
+	h.Record(NewRun(&plan.Plan{Target: testTarget, Entries: []plan.Entry{payload, catalogV1, bundle, ubi}}, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)))
+	return h
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("the history was never saved", func(t *testing.T) {
+			h, err := NewFileStore(t.TempDir()).Load(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, len(h.Runs), 0)
+		})
+
+		t.Run("saving and loading the history", func(t *testing.T) {
+			store := NewFileStore(filepath.Join(t.TempDir(), "workdir"))
+			assert.NilError(t, store.Save(ctx, testHistory()))
+			h, err := store.Load(ctx)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, h, testHistory())
+
+			entries, err := os.ReadDir(filepath.Dir(store.Path))
+			assert.NilError(t, err)
+			assert.Equal(t, len(entries), 1)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the history is corrupted", func(t *testing.T) {
+			store := NewFileStore(t.TempDir())
+			assert.NilError(t, os.WriteFile(store.Path, []byte(`{"runs": [`), 0o644))
+			_, err := store.Load(ctx)
+			assert.ErrorIs(t, err, libErrs.ErrHistory)
+		})
+	})
+}
+
+func TestRegistryStore(t *testing.T) {
+	ctx := context.Background()
+	host, _ := testregistry.New(t)
+	sysCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("the history was never saved", func(t *testing.T) {
+			store := &RegistryStore{Ref: host + "/mirror/oc-mirror-history:latest", SystemCtx: sysCtx}
+			h, err := store.Load(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, len(h.Runs), 0)
+		})
+
+		t.Run("saving and loading the history", func(t *testing.T) {
+			store := &RegistryStore{Ref: host + "/mirror/oc-mirror-history:latest", SystemCtx: sysCtx}
+			assert.NilError(t, store.Save(ctx, testHistory()))
+			h, err := store.Load(ctx)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, h, testHistory())
+
+			// saving again replaces the history
+			h.Record(NewRun(&plan.Plan{Target: testTarget}, time.Date(2025, 10, 2, 12, 0, 0, 0, time.UTC)))
+			assert.NilError(t, store.Save(ctx, h))
+			h, err = store.Load(ctx)
+			assert.NilError(t, err)
+			assert.Equal(t, len(h.Runs), 2)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the reference is invalid", func(t *testing.T) {
+			_, err := (&RegistryStore{Ref: "INVALID"}).Load(ctx)
+			assert.ErrorIs(t, err, libErrs.ErrHistory)
+		})
+	})
+}
@@ -0,0 +1,77 @@
+// Package testregistry runs in-memory registries and pushes random images to them, for tests.
+package testregistry
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+)
+
+// New starts an in-memory registry, stopped with the test, and returns its host. Manifest requests
+// for `flaky` repositories fail with 503 while the returned number of failures is positive.
+func New(t *testing.T) (string, *atomic.Int32) {
+	var failures atomic.Int32
+	handler := registry.New(registry.Logger(log.New(io.Discard, "", 0)))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/flaky/manifests/") && failures.Add(-1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://"), &failures
+}
+
+// ParseRef parses a reference to an in-memory registry.
+func ParseRef(t *testing.T, ref string) name.Reference {
+	parsed, err := name.ParseReference(ref, name.Insecure)
+	assert.NilError(t, err)
+	return parsed
+}
+
+// PushImage pushes a random image with 2 layers to all the references and returns its digest.
+func PushImage(t *testing.T, refs ...string) digest.Digest {
+	img, err := random.Image(512, 2)
+	assert.NilError(t, err)
+	for _, ref := range refs {
+		assert.NilError(t, remote.Write(ParseRef(t, ref), img))
+	}
+	dgst, err := img.Digest()
+	assert.NilError(t, err)
+	return digest.Digest(dgst.String())
+}
+
+// PushIndex pushes a random manifest list of 2 images to `ref` and returns its digest.
+func PushIndex(t *testing.T, ref string) digest.Digest {
+	idx, err := random.Index(512, 1, 2)
+	assert.NilError(t, err)
+	assert.NilError(t, remote.WriteIndex(ParseRef(t, ref), idx))
+	dgst, err := idx.Digest()
+	assert.NilError(t, err)
+	return digest.Digest(dgst.String())
+}
+
+// Instances returns the instance digests of the manifest list `ref`.
+func Instances(t *testing.T, ref string) []digest.Digest {
+	idx, err := remote.Index(ParseRef(t, ref))
+	assert.NilError(t, err)
+	manifest, err := idx.IndexManifest()
+	assert.NilError(t, err)
+	instances := []digest.Digest{}
+	for _, desc := range manifest.Manifests {
+		instances = append(instances, digest.Digest(desc.Digest.String()))
+	}
+	return instances
+}
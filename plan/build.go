@@ -0,0 +1,302 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/types"
+
+	"github.com/r4f4/oc-mirror-libs/catalog"
+	"github.com/r4f4/oc-mirror-libs/config"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/release"
+)
+
+const (
+	dockerPrefix = "docker://"
+	ociPrefix    = "oci:"
+)
+
+// Catalog is a loaded operator catalog and the digest of its image.
+type Catalog struct {
+	Introspector catalog.CatalogIntrospector
+	Digest       digest.Digest
+}
+
+// ComponentsFunc returns the component images of a release payload.
+type ComponentsFunc func(ctx context.Context, payload release.ReleasePayload) ([]release.ReleaseComponent, error)
+
+// Builder computes mirror plans.
+type Builder struct {
+	// Target is the registry, and optional namespace, that images are mirrored to, e.g. `registry.example.com:5000/mirror`.
+	Target string
+	// Releases are the clients with the graph data of the platform channels, by platform type.
+	Releases map[config.PlatformType]*release.ReleaseClient
+	// Catalogs are the loaded operator catalogs, by `catalog` reference as written in the configuration.
+	Catalogs map[string]Catalog
+	// Components reads the component images of the release payloads.
+	// Defaults to `release.GetReleaseComponents` for the payload architecture.
+	Components ComponentsFunc
+	SystemCtx  *types.SystemContext
+}
+
+// payloadArchs are the architecture names used in release payload tags.
+var payloadArchs = map[release.Architecture]string{
+	release.AMD64: "x86_64",
+	release.ARM64: "aarch64",
+}
+
+// Build returns the plan to mirror the content of the image set configuration.
+// Images listed in `blockedImages`, by repository or full reference, are left out.
+// The graph-data image and helm charts are not part of the plan.
+func (b *Builder) Build(ctx context.Context, isc *config.ImageSetConfiguration) (*Plan, error) {
+	target := strings.TrimSuffix(strings.TrimPrefix(b.Target, dockerPrefix), "/")
+	if target == "" {
+		return nil, libErrs.NewErr(libErrs.PlanErrorKind, fmt.Errorf("%w: missing target registry", libErrs.ErrBuildPlan))
+	}
+	s := &planState{target: target, entries: map[string]*Entry{}}
+
+	if err := b.addReleases(ctx, s, isc.Mirror.Platform); err != nil {
+		return nil, err
+	}
+	for _, op := range isc.Mirror.Operators {
+		if err := b.addOperator(s, op); err != nil {
+			return nil, err
+		}
+	}
+	for _, img := range isc.Mirror.AdditionalImages {
+		if err := s.addImage(img.Name, "", "", Entry{Origin: AdditionalOrigin, Type: AdditionalImage}); err != nil {
+			return nil, err
+		}
+	}
+
+	blocked, err := parseBlocked(isc.Mirror.BlockedImages)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if isBlocked(blocked, e.Source) {
+			logger.Info("skipping blocked image", slog.String("source", e.Source))
+			continue
+		}
+		entries = append(entries, *e)
+	}
+	sortEntries(entries)
+	logger.Info("built mirror plan", slog.Int("images", len(entries)))
+	return &Plan{Target: target, Entries: entries}, nil
+}
+
+func (b *Builder) addReleases(ctx context.Context, s *planState, platform config.Platform) error {
+	payloads := []release.ReleasePayload{}
+	for _, ch := range platform.Channels {
+		client, ok := b.Releases[ch.GetType()]
+		if !ok {
+			return libErrs.NewErr(libErrs.PlanErrorKind, fmt.Errorf("%w: %s graph data %w", libErrs.ErrBuildPlan, ch.GetType(), libErrs.ErrNotFound))
+		}
+		r, err := ch.MirrorRange()
+		if err != nil {
+			return err
+		}
+		chPayloads, err := client.GetMirrorSet([]release.MirrorRange{r}, ch.MirrorSetOptions())
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, chPayloads...)
+	}
+	if platform.Release != "" {
+		payloads = append(payloads, release.ReleasePayload{Payload: platform.Release})
+	}
+
+	components := b.Components
+	if components == nil {
+		components = b.defaultComponents
+	}
+	seen := map[string]bool{}
+	for _, p := range payloads {
+		if seen[p.Payload] {
+			continue
+		}
+		seen[p.Payload] = true
+
+		name, tag := p.Payload, ""
+		if p.Version != nil {
+			arch, ok := payloadArchs[p.Arch]
+			if !ok {
+				arch = string(p.Arch)
+			}
+			name, tag = p.Version.String(), fmt.Sprintf("%s-%s", p.Version, arch)
+		}
+		if err := s.addImage(p.Payload, "", tag, Entry{Origin: ReleaseOrigin, Type: ReleasePayloadImage, RequiredBy: []string{name}}); err != nil {
+			return err
+		}
+
+		logger.Debug("reading release components", slog.String("release", name))
+		comps, err := components(ctx, p)
+		if err != nil {
+			return err
+		}
+		for _, c := range comps {
+			requiredBy := []string{path.Join(name, c.Name)}
+			if err := s.addImage(c.Image, "", "", Entry{Origin: ReleaseOrigin, Type: ReleaseComponentImage, RequiredBy: requiredBy}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Builder) defaultComponents(ctx context.Context, payload release.ReleasePayload) ([]release.ReleaseComponent, error) {
+	sysCtx := types.SystemContext{OSChoice: "linux"}
+	if b.SystemCtx != nil {
+		sysCtx = *b.SystemCtx
+	}
+	if payload.Arch != "" && payload.Arch != release.MULTI {
+		sysCtx.ArchitectureChoice = string(payload.Arch)
+	}
+	return release.GetReleaseComponents(ctx, payload.Payload, &sysCtx)
+}
+
+func (b *Builder) addOperator(s *planState, op config.Operator) error {
+	cat, ok := b.Catalogs[op.Catalog]
+	if !ok {
+		return libErrs.NewErr(libErrs.PlanErrorKind, fmt.Errorf("%w: catalog %q %w", libErrs.ErrBuildPlan, op.Catalog, libErrs.ErrNotFound))
+	}
+
+	if op.IsLocal() {
+		dir, tag, _ := strings.Cut(op.LocalPath(), ":")
+		repo := firstNonEmpty(op.TargetCatalog, path.Base(dir))
+		s.add(Entry{
+			Source:      ociPrefix + op.LocalPath(),
+			Destination: fmt.Sprintf("%s/%s:%s", s.target, repo, firstNonEmpty(op.TargetTag, tag, "latest")),
+			Digest:      cat.Digest,
+			Origin:      OperatorOrigin,
+			Type:        CatalogImage,
+		})
+	} else {
+		entry := Entry{Digest: cat.Digest, Origin: OperatorOrigin, Type: CatalogImage}
+		if err := s.addImage(op.Catalog, op.TargetCatalog, op.TargetTag, entry); err != nil {
+			return err
+		}
+	}
+
+	bundles, err := op.SelectBundles(cat.Introspector)
+	if err != nil {
+		return err
+	}
+	for _, bdl := range bundles {
+		requiredBy := []string{bdl.Name}
+		if bdl.Image != "" {
+			if err := s.addImage(bdl.Image, "", "", Entry{Origin: OperatorOrigin, Type: BundleImage, RequiredBy: requiredBy}); err != nil {
+				return err
+			}
+		}
+		related, err := cat.Introspector.GetRelatedImagesForBundle(bdl.Package, bdl.Name)
+		if err != nil {
+			return err
+		}
+		for _, ri := range related {
+			if ri.Image == "" {
+				continue
+			}
+			if err := s.addImage(ri.Image, "", "", Entry{Origin: OperatorOrigin, Type: RelatedImage, RequiredBy: requiredBy}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// planState holds the plan entries while building, by source and destination.
+type planState struct {
+	target  string
+	entries map[string]*Entry
+}
+
+// add adds the entry, or merges its requirements if the source is already in the plan
+// with the same destination. The origin and type of the first entry are kept.
+func (s *planState) add(e Entry) {
+	key := e.Source + " " + e.Destination
+	if existing, ok := s.entries[key]; ok {
+		existing.RequiredBy = append(existing.RequiredBy, e.RequiredBy...)
+		if existing.Digest == "" {
+			existing.Digest = e.Digest
+		}
+		return
+	}
+	s.entries[key] = &e
+}
+
+// addImage adds the registry image `ref` to the plan, setting the entry source, destination and digest.
+// The destination keeps the source repository path unless `repo` is set.
+// It is pinned by `tag` if set, or by the source digest or tag.
+func (s *planState) addImage(ref string, repo string, tag string, e Entry) error {
+	named, err := reference.ParseNormalizedNamed(strings.TrimPrefix(ref, dockerPrefix))
+	if err != nil {
+		return libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %q: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, ref, err))
+	}
+	named = reference.TagNameOnly(named)
+	if repo == "" {
+		repo = reference.Path(named)
+	}
+
+	e.Source = named.String()
+	dest := fmt.Sprintf("%s/%s", s.target, repo)
+	canonical, isCanonical := named.(reference.Canonical)
+	if isCanonical {
+		e.Digest = canonical.Digest()
+	}
+	switch tagged, isTagged := named.(reference.Tagged); {
+	case tag != "":
+		dest += ":" + tag
+	case isCanonical:
+		dest += "@" + canonical.Digest().String()
+	case isTagged:
+		dest += ":" + tagged.Tag()
+	}
+	e.Destination = dest
+	s.add(e)
+	return nil
+}
+
+func parseBlocked(images []config.Image) ([]reference.Named, error) {
+	blocked := make([]reference.Named, 0, len(images))
+	for _, img := range images {
+		named, err := reference.ParseNormalizedNamed(strings.TrimPrefix(img.Name, dockerPrefix))
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %q: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, img.Name, err))
+		}
+		blocked = append(blocked, named)
+	}
+	return blocked, nil
+}
+
+// isBlocked returns whether the source matches a blocked repository, or a blocked reference exactly.
+func isBlocked(blocked []reference.Named, source string) bool {
+	named, err := reference.ParseNormalizedNamed(source)
+	if err != nil {
+		// NOTE: local OCI layouts can't be blocked.
+		return false
+	}
+	return slices.ContainsFunc(blocked, func(b reference.Named) bool {
+		if reference.IsNameOnly(b) {
+			return b.Name() == named.Name()
+		}
+		return b.String() == named.String()
+	})
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
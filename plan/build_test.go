@@ -0,0 +1,215 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/catalog"
+	"github.com/r4f4/oc-mirror-libs/common"
+	"github.com/r4f4/oc-mirror-libs/config"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/release"
+)
+
+const (
+	graphData419 = "../testdata/cincinnati/ocp-graph-data-4.19-amd64.json"
+	testCatalog  = "../testdata/config/catalog/configs"
+	testTarget   = "registry.example.com:5000/mirror"
+
+	payload4191 = "quay.io/openshift-release-dev/ocp-release@sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196"
+	etcdImage   = "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	bundleImage = "registry.redhat.io/rhbk/keycloack-operator-bundle@sha256:dededededededededededededededededededededededededededededededede"
+)
+
+const testConfig = `
+kind: ImageSetConfiguration
+apiVersion: mirror.openshift.io/v2alpha1
+mirror:
+  platform:
+    channels:
+      - name: stable-4.19
+        minVersion: 4.19.1
+        maxVersion: 4.19.2
+        full: true
+  operators:
+    - catalog: registry.redhat.io/redhat/redhat-operator-index:v4.19
+      targetCatalog: mirror/operator-index
+      packages:
+        - name: rhbk-operator
+          channels:
+            - name: stable-v26
+              minVersion: 26.1.0
+  additionalImages:
+    - name: registry.redhat.io/ubi9/ubi:latest
+    - name: registry.redhat.io/ubi9/ubi-minimal:9.4
+    - name: quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111
+  blockedImages:
+    - name: registry.redhat.io/ubi9/ubi-minimal
+`
+
+// stubComponents returns an `etcd` component shared by all releases and a `cli` component for each release.
+func stubComponents(_ context.Context, payload release.ReleasePayload) ([]release.ReleaseComponent, error) {
+	return []release.ReleaseComponent{
+		{Name: "etcd", Image: etcdImage},
+		{Name: "cli", Image: fmt.Sprintf("quay.io/openshift-release-dev/ocp-v4.0-art-dev:cli-%s", payload.Version)},
+	}, nil
+}
+
+func newTestBuilder(t *testing.T) *Builder {
+	data, err := os.ReadFile(graphData419)
+	assert.NilError(t, err)
+	client, err := release.NewReleaseClient(data)
+	assert.NilError(t, err)
+	ci, err := catalog.LoadCatalog(context.Background(), testCatalog)
+	assert.NilError(t, err)
+	return &Builder{
+		Target:     "docker://" + testTarget + "/",
+		Releases:   map[config.PlatformType]*release.ReleaseClient{config.OCPPlatform: client},
+		Catalogs:   map[string]Catalog{"registry.redhat.io/redhat/redhat-operator-index:v4.19": {Introspector: ci, Digest: "sha256:cafe"}},
+		Components: stubComponents,
+	}
+}
+
+func findEntry(t *testing.T, p *Plan, source string) Entry {
+	for _, e := range p.Entries {
+		if e.Source == source {
+			return e
+		}
+	}
+	t.Fatalf("entry %q not found", source)
+	return Entry{}
+}
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	isc, err := config.Parse([]byte(testConfig))
+	assert.NilError(t, err)
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("combining releases, operators and additional images", func(t *testing.T) {
+			p, err := newTestBuilder(t).Build(ctx, isc)
+			assert.NilError(t, err)
+			assert.Equal(t, p.Target, testTarget)
+			// 2 payloads + 1 shared and 2 cli components, catalog + bundle, 1 additional image
+			assert.Equal(t, len(p.Entries), 8)
+			assert.Equal(t, len(p.ByOrigin(ReleaseOrigin)), 5)
+			assert.Equal(t, len(p.ByOrigin(OperatorOrigin)), 2)
+			assert.Equal(t, len(p.ByOrigin(AdditionalOrigin)), 1)
+			assert.Assert(t, slices.IsSorted(common.Map(p.Entries, func(e Entry) string { return e.Source })))
+
+			assert.DeepEqual(t, findEntry(t, p, payload4191), Entry{
+				Source:      payload4191,
+				Destination: testTarget + "/openshift-release-dev/ocp-release:4.19.1-x86_64",
+				Digest:      "sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196",
+				Origin:      ReleaseOrigin,
+				Type:        ReleasePayloadImage,
+				RequiredBy:  []string{"4.19.1"},
+			})
+			// the component is deduplicated with the additional image
+			assert.DeepEqual(t, findEntry(t, p, etcdImage), Entry{
+				Source:      etcdImage,
+				Destination: testTarget + "/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				Digest:      "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				Origin:      ReleaseOrigin,
+				Type:        ReleaseComponentImage,
+				RequiredBy:  []string{"4.19.1/etcd", "4.19.2/etcd"},
+			})
+			cli := findEntry(t, p, "quay.io/openshift-release-dev/ocp-v4.0-art-dev:cli-4.19.2")
+			assert.Equal(t, cli.Destination, testTarget+"/openshift-release-dev/ocp-v4.0-art-dev:cli-4.19.2")
+			assert.Equal(t, cli.Digest.String(), "")
+
+			assert.DeepEqual(t, findEntry(t, p, "registry.redhat.io/redhat/redhat-operator-index:v4.19"), Entry{
+				Source:      "registry.redhat.io/redhat/redhat-operator-index:v4.19",
+				Destination: testTarget + "/mirror/operator-index:v4.19",
+				Digest:      "sha256:cafe",
+				Origin:      OperatorOrigin,
+				Type:        CatalogImage,
+			})
+			// the bundle image is also its related image
+			assert.DeepEqual(t, findEntry(t, p, bundleImage).RequiredBy, []string{"rhbk-operator.v26.2.11-opr.1"})
+			assert.Equal(t, findEntry(t, p, bundleImage).Type, BundleImage)
+
+			assert.Equal(t, findEntry(t, p, "registry.redhat.io/ubi9/ubi:latest").Destination, testTarget+"/ubi9/ubi:latest")
+			assert.Equal(t, len(p.Filter(func(e Entry) bool { return e.Source == "registry.redhat.io/ubi9/ubi-minimal:9.4" })), 0)
+		})
+
+		t.Run("mirroring a local catalog", func(t *testing.T) {
+			b := newTestBuilder(t)
+			b.Catalogs = map[string]Catalog{"oci:///tmp/catalogs/index:v1": b.Catalogs["registry.redhat.io/redhat/redhat-operator-index:v4.19"]}
+			p, err := b.Build(ctx, &config.ImageSetConfiguration{Mirror: config.Mirror{
+				Operators: []config.Operator{{Catalog: "oci:///tmp/catalogs/index:v1", Packages: []config.Package{{Name: "devspaces"}}}},
+			}})
+			assert.NilError(t, err)
+			catalogs := p.Filter(func(e Entry) bool { return e.Type == CatalogImage })
+			assert.Equal(t, len(catalogs), 1)
+			assert.Equal(t, catalogs[0].Source, "oci:/tmp/catalogs/index:v1")
+			assert.Equal(t, catalogs[0].Destination, testTarget+"/index:v1")
+		})
+
+		t.Run("an image is mirrored to two destinations", func(t *testing.T) {
+			const index = "registry.redhat.io/redhat/redhat-operator-index:v4.19"
+			p, err := newTestBuilder(t).Build(ctx, &config.ImageSetConfiguration{Mirror: config.Mirror{
+				Operators:        []config.Operator{{Catalog: index, TargetCatalog: "mirror/operator-index", Packages: []config.Package{{Name: "devspaces"}}}},
+				AdditionalImages: []config.Image{{Name: index}},
+			}})
+			assert.NilError(t, err)
+			indexes := p.Filter(func(e Entry) bool { return e.Source == index })
+			assert.DeepEqual(t, common.Map(indexes, func(e Entry) string { return e.Destination }), []string{
+				testTarget + "/mirror/operator-index:v4.19",
+				testTarget + "/redhat/redhat-operator-index:v4.19",
+			})
+		})
+
+		t.Run("mirroring operator dependencies", func(t *testing.T) {
+			op := config.Operator{
+				Catalog:  "registry.redhat.io/redhat/redhat-operator-index:v4.19",
+				Packages: []config.Package{{Name: "web-terminal"}},
+			}
+			p, err := newTestBuilder(t).Build(ctx, &config.ImageSetConfiguration{Mirror: config.Mirror{Operators: []config.Operator{op}}})
+			assert.NilError(t, err)
+			// catalog + web-terminal bundle, devworkspace-operator bundle and controller
+			assert.Equal(t, len(p.ByOrigin(OperatorOrigin)), 4)
+			controller := "registry.redhat.io/devworkspace/devworkspace-rhel8-operator@sha256:0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d0d"
+			assert.DeepEqual(t, findEntry(t, p, controller).RequiredBy, []string{"devworkspace-operator.v0.12.1"})
+
+			op.SkipDependencies = true
+			p, err = newTestBuilder(t).Build(ctx, &config.ImageSetConfiguration{Mirror: config.Mirror{Operators: []config.Operator{op}}})
+			assert.NilError(t, err)
+			assert.Equal(t, len(p.ByOrigin(OperatorOrigin)), 2)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the target is missing", func(t *testing.T) {
+			b := newTestBuilder(t)
+			b.Target = ""
+			_, err := b.Build(ctx, isc)
+			assert.ErrorIs(t, err, libErrs.ErrBuildPlan)
+		})
+
+		t.Run("a catalog is not loaded", func(t *testing.T) {
+			b := newTestBuilder(t)
+			b.Catalogs = nil
+			_, err := b.Build(ctx, isc)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			var libErr *libErrs.Error
+			assert.Assert(t, errors.As(err, &libErr))
+			assert.Equal(t, libErr.Kind(), libErrs.PlanErrorKind)
+		})
+
+		t.Run("release components can't be read", func(t *testing.T) {
+			b := newTestBuilder(t)
+			b.Components = func(context.Context, release.ReleasePayload) ([]release.ReleaseComponent, error) {
+				return nil, libErrs.NewReleaseErr(libErrs.ErrReadPayload)
+			}
+			_, err := b.Build(ctx, isc)
+			assert.ErrorIs(t, err, libErrs.ErrReadPayload)
+		})
+	})
+}
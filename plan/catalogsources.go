@@ -0,0 +1,183 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const (
+	defaultCatalogNamespace = "openshift-marketplace"
+	defaultPollInterval     = 10 * time.Minute
+	// maxNameLength is the maximum length of a DNS-1123 label.
+	maxNameLength = 63
+	// hashSuffixLength is the length of the hash telling apart catalogs with the same name.
+	hashSuffixLength = 8
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// CatalogSource is the OLM v0 `operators.coreos.com/v1alpha1` resource serving a catalog image.
+type CatalogSource struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              CatalogSourceSpec `json:"spec"`
+}
+
+type CatalogSourceSpec struct {
+	SourceType     string          `json:"sourceType"`
+	Image          string          `json:"image"`
+	DisplayName    string          `json:"displayName,omitempty"`
+	Publisher      string          `json:"publisher,omitempty"`
+	UpdateStrategy *UpdateStrategy `json:"updateStrategy,omitempty"`
+}
+
+type UpdateStrategy struct {
+	RegistryPoll *RegistryPoll `json:"registryPoll,omitempty"`
+}
+
+type RegistryPoll struct {
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ClusterCatalog is the OLM v1 `olm.operatorframework.io/v1` resource serving a catalog image.
+type ClusterCatalog struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ClusterCatalogSpec `json:"spec"`
+}
+
+type ClusterCatalogSpec struct {
+	Source CatalogImageSource `json:"source"`
+}
+
+type CatalogImageSource struct {
+	Type  string            `json:"type"`
+	Image ImageSourceConfig `json:"image"`
+}
+
+// ImageSourceConfig has no poll interval: OLM v1 rejects it for catalogs pinned by digest.
+type ImageSourceConfig struct {
+	Ref string `json:"ref"`
+}
+
+// CatalogSourcesOptions is used to configure the generated catalog resources.
+type CatalogSourcesOptions struct {
+	// Namespace of the CatalogSources. Defaults to "openshift-marketplace".
+	Namespace string
+	// PollInterval of the CatalogSources. Defaults to 10 minutes.
+	PollInterval time.Duration
+	// DisplayName and Publisher shown in the console for the CatalogSources.
+	DisplayName string
+	Publisher   string
+	// Digests overrides the destination digests of rebuilt catalogs, by catalog source.
+	Digests map[string]digest.Digest
+}
+
+// CatalogSources are the resources that make the mirrored catalogs available in the cluster.
+type CatalogSources struct {
+	CatalogSources  []CatalogSource
+	ClusterCatalogs []ClusterCatalog
+}
+
+// CatalogSources returns a CatalogSource and a ClusterCatalog for each catalog in the plan,
+// pointing at the catalog destination digest.
+// Resources are named by the `CatalogName` of the catalog destination, which follows the configured
+// target catalog and tag. Catalogs with the same name, e.g. `quay.io/a/index:v1` and `quay.io/b/index:v1`,
+// are told apart by a short hash of their source.
+func (p *Plan) CatalogSources(opts CatalogSourcesOptions) (*CatalogSources, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = defaultCatalogNamespace
+	}
+	if opts.PollInterval == 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+
+	catalogs := p.Filter(func(e Entry) bool { return e.Type == CatalogImage })
+	names := map[string]int{}
+	for _, e := range catalogs {
+		names[CatalogName(e.Destination)]++
+	}
+
+	cs := &CatalogSources{}
+	for _, e := range catalogs {
+		dest, err := reference.ParseNormalizedNamed(e.Destination)
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, err))
+		}
+		dgst := e.Digest
+		if override, ok := opts.Digests[e.Source]; ok {
+			dgst = override
+		}
+		if dgst == "" {
+			return nil, libErrs.NewErr(libErrs.PlanErrorKind, fmt.Errorf("%w: catalog %q digest %w", libErrs.ErrBuildPlan, e.Source, libErrs.ErrNotFound))
+		}
+		ref := fmt.Sprintf("%s@%s", dest.Name(), dgst)
+		name := CatalogName(e.Destination)
+		if names[name] > 1 {
+			name = hashedName(name, e.Source)
+		}
+
+		cs.CatalogSources = append(cs.CatalogSources, CatalogSource{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "operators.coreos.com/v1alpha1", Kind: "CatalogSource"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: opts.Namespace},
+			Spec: CatalogSourceSpec{
+				SourceType:  "grpc",
+				Image:       ref,
+				DisplayName: opts.DisplayName,
+				Publisher:   opts.Publisher,
+				UpdateStrategy: &UpdateStrategy{
+					RegistryPoll: &RegistryPoll{Interval: &metav1.Duration{Duration: opts.PollInterval}},
+				},
+			},
+		})
+		cs.ClusterCatalogs = append(cs.ClusterCatalogs, ClusterCatalog{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "olm.operatorframework.io/v1", Kind: "ClusterCatalog"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: ClusterCatalogSpec{
+				Source: CatalogImageSource{Type: "Image", Image: ImageSourceConfig{Ref: ref}},
+			},
+		})
+	}
+	return cs, nil
+}
+
+// Write writes all the resources to `w` as a multi-document YAML stream.
+func (c *CatalogSources) Write(w io.Writer) error {
+	objs := []any{}
+	for _, src := range c.CatalogSources {
+		objs = append(objs, src)
+	}
+	for _, cat := range c.ClusterCatalogs {
+		objs = append(objs, cat)
+	}
+	return common.WriteManifests(w, objs...)
+}
+
+// CatalogName returns a valid resource name for a catalog reference,
+// e.g. `cs-redhat-operator-index-v4-19` for `registry.redhat.io/redhat/redhat-operator-index:v4.19`.
+func CatalogName(ref string) string {
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, dockerPrefix), ociPrefix)
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	base := ref[strings.LastIndex(ref, "/")+1:]
+	name := "cs-" + strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	return strings.TrimRight(name[:min(len(name), maxNameLength)], "-")
+}
+
+// hashedName suffixes the resource name with a short hash of `ref`, keeping it a valid name.
+func hashedName(name, ref string) string {
+	suffix := digest.FromString(ref).Encoded()[:hashSuffixLength]
+	name = strings.TrimRight(name[:min(len(name), maxNameLength-hashSuffixLength-1)], "-")
+	return name + "-" + suffix
+}
@@ -0,0 +1,116 @@
+package plan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestCatalogSources(t *testing.T) {
+	const (
+		remoteCatalog = "registry.redhat.io/redhat/redhat-operator-index:v4.19"
+		localCatalog  = "oci:/tmp/catalogs/my_index:v1"
+		rebuilt       = digest.Digest("sha256:beefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeefbeef")
+	)
+	p := &Plan{Target: testTarget, Entries: []Entry{
+		{Source: remoteCatalog, Destination: testTarget + "/mirror/operator-index:v4.19", Digest: "sha256:cafecafecafecafecafecafecafecafecafecafecafecafecafecafecafecafe", Type: CatalogImage},
+		{Source: localCatalog, Destination: testTarget + "/my_index:v1", Digest: "sha256:abababababababababababababababababababababababababababababababab", Type: CatalogImage},
+		{Source: bundleImage, Destination: testTarget + "/rhbk/keycloack-operator-bundle@" + bundleImage[strings.Index(bundleImage, "@")+1:], Type: BundleImage},
+	}}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("using the defaults", func(t *testing.T) {
+			cs, err := p.CatalogSources(CatalogSourcesOptions{})
+			assert.NilError(t, err)
+			assert.Equal(t, len(cs.CatalogSources), 2)
+			assert.Equal(t, len(cs.ClusterCatalogs), 2)
+
+			src := cs.CatalogSources[1]
+			assert.Equal(t, src.Name, "cs-my-index-v1")
+			assert.Equal(t, src.Namespace, "openshift-marketplace")
+			assert.Equal(t, src.Spec.Image, testTarget+"/my_index@sha256:abababababababababababababababababababababababababababababababab")
+			assert.Equal(t, src.Spec.UpdateStrategy.RegistryPoll.Interval.Duration, 10*time.Minute)
+
+			// the catalog is named after its target catalog
+			cat := cs.ClusterCatalogs[0]
+			assert.Equal(t, cat.Name, "cs-operator-index-v4-19")
+			assert.Equal(t, cat.Spec.Source.Image.Ref, testTarget+"/mirror/operator-index@sha256:cafecafecafecafecafecafecafecafecafecafecafecafecafecafecafecafe")
+		})
+
+		t.Run("configuring the catalogs and rebuilt digests", func(t *testing.T) {
+			cs, err := p.CatalogSources(CatalogSourcesOptions{
+				Namespace:    "olm",
+				PollInterval: time.Hour,
+				DisplayName:  "Mirrored operators",
+				Publisher:    "ACME",
+				Digests:      map[string]digest.Digest{remoteCatalog: rebuilt},
+			})
+			assert.NilError(t, err)
+			src := cs.CatalogSources[0]
+			assert.Equal(t, src.Namespace, "olm")
+			assert.Equal(t, src.Spec.DisplayName, "Mirrored operators")
+			assert.Equal(t, src.Spec.Publisher, "ACME")
+			assert.Equal(t, src.Spec.Image, testTarget+"/mirror/operator-index@"+rebuilt.String())
+			assert.Equal(t, cs.ClusterCatalogs[0].Spec.Source.Image.Ref, src.Spec.Image)
+
+			var buf bytes.Buffer
+			assert.NilError(t, cs.Write(&buf))
+			assert.Equal(t, strings.Count(buf.String(), "kind: CatalogSource"), 2)
+			assert.Equal(t, strings.Count(buf.String(), "kind: ClusterCatalog"), 2)
+			assert.Assert(t, strings.Contains(buf.String(), "interval: 1h0m0s"))
+		})
+
+		t.Run("catalogs have the same name", func(t *testing.T) {
+			dgst := digest.Digest("sha256:cafecafecafecafecafecafecafecafecafecafecafecafecafecafecafecafe")
+			same := &Plan{Entries: []Entry{
+				{Source: "quay.io/a/index:v1", Destination: testTarget + "/a/index:v1", Digest: dgst, Type: CatalogImage},
+				{Source: "quay.io/b/index:v1", Destination: testTarget + "/b/index:v1", Digest: dgst, Type: CatalogImage},
+				{Source: "quay.io/c/other:v1", Destination: testTarget + "/c/other:v1", Digest: dgst, Type: CatalogImage},
+			}}
+			cs, err := same.CatalogSources(CatalogSourcesOptions{})
+			assert.NilError(t, err)
+			names := common.Map(cs.CatalogSources, func(src CatalogSource) string { return src.Name })
+			assert.Assert(t, strings.HasPrefix(names[0], "cs-index-v1-"))
+			assert.Assert(t, strings.HasPrefix(names[1], "cs-index-v1-"))
+			assert.Assert(t, names[0] != names[1])
+			assert.Equal(t, len(names[0]), len("cs-index-v1-")+hashSuffixLength)
+			assert.Equal(t, names[2], "cs-other-v1")
+			assert.Equal(t, cs.ClusterCatalogs[1].Name, names[1])
+
+			long := &Plan{Entries: []Entry{
+				{Source: "quay.io/a/index:v1", Destination: testTarget + "/a/" + strings.Repeat("x", 80) + ":v1", Digest: dgst, Type: CatalogImage},
+				{Source: "quay.io/b/index:v1", Destination: testTarget + "/b/" + strings.Repeat("x", 80) + ":v1", Digest: dgst, Type: CatalogImage},
+			}}
+			cs, err = long.CatalogSources(CatalogSourcesOptions{})
+			assert.NilError(t, err)
+			assert.Equal(t, len(cs.CatalogSources[0].Name), maxNameLength)
+			assert.Assert(t, cs.CatalogSources[0].Name != cs.CatalogSources[1].Name)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("a catalog digest is missing", func(t *testing.T) {
+			noDigest := &Plan{Entries: []Entry{{Source: remoteCatalog, Destination: testTarget + "/redhat-operator-index:v4.19", Type: CatalogImage}}}
+			_, err := noDigest.CatalogSources(CatalogSourcesOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+	})
+}
+
+func TestCatalogName(t *testing.T) {
+	for ref, name := range map[string]string{
+		"registry.redhat.io/redhat/certified-operator-index:v4.19":      "cs-certified-operator-index-v4-19",
+		"docker://registry.example.com:5000/catalogs/Index_Name":        "cs-index-name",
+		"quay.io/org/index@sha256:cafecafecafecafecafecafecafecafecafe": "cs-index",
+		"oci:///tmp/" + strings.Repeat("x", 80) + ":v1":                 "cs-" + strings.Repeat("x", 60),
+	} {
+		assert.Equal(t, CatalogName(ref), name, ref)
+	}
+}
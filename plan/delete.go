@@ -0,0 +1,356 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// DeletedStatus is used for images deleted from the target registry.
+const DeletedStatus ResultStatus = "deleted"
+
+// DeleteEntry is an image manifest to delete from the target registry.
+type DeleteEntry struct {
+	// Reference is the manifest to delete, by digest.
+	Reference string        `json:"reference"`
+	Digest    digest.Digest `json:"digest"`
+	// Tags are the removed destination tags pointing at the manifest.
+	Tags   []string  `json:"tags,omitempty"`
+	Origin Origin    `json:"origin"`
+	Type   ImageType `json:"type"`
+}
+
+// SkippedDelete is a removed plan entry that is kept in the target registry.
+type SkippedDelete struct {
+	Destination string `json:"destination"`
+	Reason      string `json:"reason"`
+}
+
+// DeletePlan is the list of manifests that are no longer mirrored and can be deleted from the target registry.
+// Only manifests are deleted: the registry garbage collection then removes the blobs no longer
+// referenced by any manifest, so that blobs shared with kept images survive.
+type DeletePlan struct {
+	Target  string          `json:"target"`
+	Delete  []DeleteEntry   `json:"delete"`
+	Skipped []SkippedDelete `json:"skipped,omitempty"`
+}
+
+// TargetReader reads the manifests mirrored to the target registry.
+type TargetReader interface {
+	// Digest returns the manifest digest of `ref`, or an empty digest for missing manifests.
+	Digest(ctx context.Context, ref string) (digest.Digest, error)
+	// Instances returns the instance digests of the manifest list `ref`,
+	// or none for image manifests and missing manifests.
+	Instances(ctx context.Context, ref string) ([]digest.Digest, error)
+}
+
+// RegistryTarget is a TargetReader for a container registry.
+type RegistryTarget struct {
+	SysCtx *types.SystemContext
+}
+
+// Digest implements TargetReader.
+func (r RegistryTarget) Digest(ctx context.Context, ref string) (digest.Digest, error) {
+	rawManifest, _, err := r.getManifest(ctx, ref)
+	if err != nil || rawManifest == nil {
+		return "", err
+	}
+	return manifest.Digest(rawManifest)
+}
+
+// Instances implements TargetReader.
+func (r RegistryTarget) Instances(ctx context.Context, ref string) ([]digest.Digest, error) {
+	rawManifest, mimeType, err := r.getManifest(ctx, ref)
+	if err != nil || rawManifest == nil || !manifest.MIMETypeIsMultiImage(mimeType) {
+		return nil, err
+	}
+	list, err := manifest.ListFromBlob(rawManifest, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	return list.Instances(), nil
+}
+
+// getManifest returns the manifest of `ref`, or none if it is missing.
+func (r RegistryTarget) getManifest(ctx context.Context, ref string) ([]byte, string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, "", err
+	}
+	dockerRef, err := docker.NewReference(named)
+	if err != nil {
+		return nil, "", err
+	}
+	src, err := dockerRef.NewImageSource(ctx, r.SysCtx)
+	if err != nil {
+		return nil, "", ignoreManifestUnknown(err)
+	}
+	defer func() { _ = src.Close() }()
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return nil, "", ignoreManifestUnknown(err)
+	}
+	return rawManifest, mimeType, nil
+}
+
+func ignoreManifestUnknown(err error) error {
+	if _, cause, _ := common.ClassifyImageError(err); errors.Is(cause, libErrs.ErrManifestUnknown) {
+		return nil
+	}
+	return err
+}
+
+// NewDeletePlan returns the manifests mirrored for the `old` plan that are no longer referenced by the `cur` plan.
+// Entries of the `cur` plan mirrored by tag get the digest their destination has in the target registry.
+// A manifest is kept if a kept entry has the same destination repository and digest, since deleting a
+// manifest by digest also removes all its tags, or if it is an instance of a kept manifest list.
+// The instances of deleted manifest lists are deleted too, unless kept. Entries whose digest is unknown
+// can't be safely deleted and are listed as skipped for review.
+func NewDeletePlan(ctx context.Context, old, cur *Plan, target TargetReader) (*DeletePlan, error) {
+	resolved := &Plan{Target: cur.Target, Entries: slices.Clone(cur.Entries)}
+	keptTags := sets.New[string]()
+	for i, e := range resolved.Entries {
+		_, dgst, err := destinationDigest(e)
+		if err != nil {
+			return nil, err
+		}
+		if dgst != "" {
+			continue
+		}
+		keptTags.Insert(e.Destination)
+		// NOTE: destinations missing from the target registry have no manifest to keep.
+		if resolved.Entries[i].Digest, err = target.Digest(ctx, e.Destination); err != nil {
+			return nil, newDeleteErr(err)
+		}
+	}
+
+	removed := resolved.Diff(old).Removed
+	candidates := sets.New[string]()
+	for _, e := range removed {
+		repo, _, err := destinationDigest(e)
+		if err != nil {
+			return nil, err
+		}
+		candidates.Insert(repo)
+	}
+
+	kept := sets.New[string]()
+	keptInstances := sets.New[string]()
+	for _, e := range resolved.Entries {
+		repo, dgst, err := destinationDigest(e)
+		if err != nil {
+			return nil, err
+		}
+		if dgst == "" {
+			continue
+		}
+		ref := repo + "@" + dgst.String()
+		if kept.Has(ref) {
+			continue
+		}
+		kept.Insert(ref)
+		// NOTE: only lists in repositories with removed entries can hold instances to delete.
+		if !candidates.Has(repo) {
+			continue
+		}
+		children, err := target.Instances(ctx, ref)
+		if err != nil {
+			return nil, newDeleteErr(err)
+		}
+		for _, child := range children {
+			keptInstances.Insert(repo + "@" + child.String())
+		}
+	}
+
+	d := &DeletePlan{Target: cur.Target}
+	deletes := map[string]*DeleteEntry{}
+	for _, e := range removed {
+		repo, dgst, err := destinationDigest(e)
+		if err != nil {
+			return nil, err
+		}
+		ref := repo + "@" + dgst.String()
+		switch {
+		case dgst == "" && keptTags.Has(e.Destination):
+			// NOTE: the tag is still mirrored, only its digest wasn't recorded.
+			continue
+		case dgst == "":
+			d.Skipped = append(d.Skipped, SkippedDelete{Destination: e.Destination, Reason: "unknown digest"})
+			continue
+		case kept.Has(ref):
+			d.Skipped = append(d.Skipped, SkippedDelete{Destination: e.Destination, Reason: "digest referenced by a kept image"})
+			continue
+		case keptInstances.Has(ref):
+			d.Skipped = append(d.Skipped, SkippedDelete{Destination: e.Destination, Reason: "instance of a kept manifest list"})
+			continue
+		}
+
+		del, ok := deletes[ref]
+		if !ok {
+			del = &DeleteEntry{Reference: ref, Digest: dgst, Origin: e.Origin, Type: e.Type}
+			deletes[ref] = del
+		}
+		// NOTE: kept tags were moved to another manifest, which the deletion doesn't affect.
+		if named, err := reference.ParseNormalizedNamed(e.Destination); err == nil && !keptTags.Has(e.Destination) {
+			if tagged, ok := named.(reference.NamedTagged); ok && !slices.Contains(del.Tags, tagged.Tag()) {
+				del.Tags = append(del.Tags, tagged.Tag())
+				slices.Sort(del.Tags)
+			}
+		}
+	}
+	slices.SortFunc(d.Skipped, func(a, b SkippedDelete) int { return strings.Compare(a.Destination, b.Destination) })
+	for _, ref := range slices.Sorted(maps.Keys(deletes)) {
+		d.Delete = append(d.Delete, *deletes[ref])
+	}
+
+	// NOTE: instances go after their lists, since registries may refuse to delete referenced manifests.
+	children := map[string]*DeleteEntry{}
+	for _, del := range d.Delete {
+		instances, err := target.Instances(ctx, del.Reference)
+		if err != nil {
+			return nil, newDeleteErr(err)
+		}
+		repo, _, _ := strings.Cut(del.Reference, "@")
+		for _, child := range instances {
+			ref := repo + "@" + child.String()
+			if kept.Has(ref) || keptInstances.Has(ref) || deletes[ref] != nil {
+				continue
+			}
+			children[ref] = &DeleteEntry{Reference: ref, Digest: child, Origin: del.Origin, Type: del.Type}
+		}
+	}
+	for _, ref := range slices.Sorted(maps.Keys(children)) {
+		d.Delete = append(d.Delete, *children[ref])
+	}
+	return d, nil
+}
+
+// destinationDigest returns the destination repository of the entry and its manifest digest, if known.
+func destinationDigest(e Entry) (string, digest.Digest, error) {
+	named, err := reference.ParseNormalizedNamed(e.Destination)
+	if err != nil {
+		return "", "", libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, err))
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		return named.Name(), canonical.Digest(), nil
+	}
+	if e.Digest != "" {
+		if err := e.Digest.Validate(); err != nil {
+			return "", "", libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, err))
+		}
+	}
+	return named.Name(), e.Digest, nil
+}
+
+// Write writes the delete plan as YAML, for review before executing it.
+func (d *DeletePlan) Write(w io.Writer) error {
+	data, err := yaml.Marshal(d)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadDeletePlan reads a delete plan written by Write, possibly edited after review.
+func ReadDeletePlan(r io.Reader) (*DeletePlan, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, libErrs.NewErr(libErrs.PlanErrorKind, fmt.Errorf("%w: %w", libErrs.ErrDelete, err))
+	}
+	var d DeletePlan
+	if err := yaml.UnmarshalStrict(data, &d); err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w", libErrs.ErrDelete, err))
+	}
+	return &d, nil
+}
+
+// DeleteResult is the outcome of deleting a manifest.
+type DeleteResult struct {
+	Entry  DeleteEntry
+	Status ResultStatus
+	// Err is set for failed deletions, classified as in `common.WrapImageError`.
+	Err error
+}
+
+// ExecuteDelete deletes the manifests of the delete plan from the target registry.
+// Manifests already missing from the registry are skipped. Deletion stops when the context is done.
+//
+// Deleting a manifest by digest removes every tag pointing at it, including tags that are not listed
+// in the plan, such as tags pushed to the target registry outside of mirroring.
+func ExecuteDelete(ctx context.Context, d *DeletePlan, sysCtx *types.SystemContext) []DeleteResult {
+	results := []DeleteResult{}
+	for _, del := range d.Delete {
+		if ctx.Err() != nil {
+			break
+		}
+		res := deleteEntry(ctx, del, sysCtx)
+		attrs := []any{slog.String("reference", del.Reference), slog.String("status", string(res.Status))}
+		if res.Err != nil {
+			logger.Error("image delete failed", append(attrs, slog.String("error", res.Err.Error()))...)
+		} else {
+			logger.Info("image deleted", attrs...)
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+func deleteEntry(ctx context.Context, del DeleteEntry, sysCtx *types.SystemContext) DeleteResult {
+	res := DeleteResult{Entry: del, Status: FailedStatus}
+	named, err := reference.ParseNormalizedNamed(del.Reference)
+	if err != nil {
+		res.Err = libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrDelete, libErrs.ErrInvalidRef, err))
+		return res
+	}
+	if _, ok := named.(reference.Canonical); !ok {
+		res.Err = libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %q is not a digest reference", libErrs.ErrDelete, libErrs.ErrInvalidRef, del.Reference))
+		return res
+	}
+	ref, err := docker.NewReference(named)
+	if err != nil {
+		res.Err = newDeleteErr(err)
+		return res
+	}
+
+	// NOTE: the manifest is read with GET: registries send no error details in HEAD responses.
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err == nil {
+		_, _, err = src.GetManifest(ctx, nil)
+		_ = src.Close()
+	}
+	if err != nil {
+		if _, cause, _ := common.ClassifyImageError(err); errors.Is(cause, libErrs.ErrManifestUnknown) {
+			res.Status = SkippedStatus
+			return res
+		}
+		res.Err = newDeleteErr(err)
+		return res
+	}
+	if err := ref.DeleteImage(ctx, sysCtx); err != nil {
+		res.Err = newDeleteErr(err)
+		return res
+	}
+	res.Status = DeletedStatus
+	return res
+}
+
+func newDeleteErr(err error) *libErrs.Error {
+	return common.WrapImageError(libErrs.PlanErrorKind, libErrs.ErrDelete, err)
+}
@@ -0,0 +1,198 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/types"
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/internal/testregistry"
+)
+
+// fakeTarget is a TargetReader for a target registry without manifest lists.
+type fakeTarget map[string]digest.Digest
+
+func (f fakeTarget) Digest(_ context.Context, ref string) (digest.Digest, error) {
+	return f[ref], nil
+}
+
+func (f fakeTarget) Instances(context.Context, string) ([]digest.Digest, error) {
+	return nil, nil
+}
+
+// failingTarget is a TargetReader for an unreachable target registry.
+type failingTarget struct{}
+
+func (failingTarget) Digest(context.Context, string) (digest.Digest, error) {
+	return "", errors.New("unreachable")
+}
+
+func (failingTarget) Instances(context.Context, string) ([]digest.Digest, error) {
+	return nil, errors.New("unreachable")
+}
+
+func TestNewDeletePlan(t *testing.T) {
+	ctx := context.Background()
+	const (
+		digestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+		digestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+		digestC = "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	)
+	app := Entry{Source: "quay.io/team/app:v1", Destination: testTarget + "/team/app:v1", Digest: digestA, Origin: AdditionalOrigin, Type: AdditionalImage}
+	appByDigest := Entry{Source: "quay.io/team/app@" + digestA, Destination: testTarget + "/team/app@" + digestA, Digest: digestA, Origin: OperatorOrigin, Type: RelatedImage}
+	tool := Entry{Source: "quay.io/team/tool@" + digestB, Destination: testTarget + "/team/tool@" + digestB, Digest: digestB, Origin: OperatorOrigin, Type: RelatedImage}
+	sharedV1 := Entry{Source: "quay.io/team/shared:v1", Destination: testTarget + "/team/shared:v1", Digest: digestC, Origin: AdditionalOrigin, Type: AdditionalImage}
+	sharedV2 := Entry{Source: "quay.io/team/shared:v2", Destination: testTarget + "/team/shared:v2", Digest: digestC, Origin: AdditionalOrigin, Type: AdditionalImage}
+	ubiOld := Entry{Source: "registry.redhat.io/ubi9/ubi:9.4", Destination: testTarget + "/ubi9/ubi:9.4", Digest: digestA, Origin: AdditionalOrigin, Type: AdditionalImage}
+	ubiLatest := Entry{Source: "registry.redhat.io/ubi9/ubi:latest", Destination: testTarget + "/ubi9/ubi:latest", Origin: AdditionalOrigin, Type: AdditionalImage}
+	ubiLatestOld := ubiLatest
+	ubiLatestOld.Digest = digestC
+	minimal := Entry{Source: "registry.redhat.io/ubi9/ubi-minimal:9.4", Destination: testTarget + "/ubi9/ubi-minimal:9.4", Origin: AdditionalOrigin, Type: AdditionalImage}
+
+	old := &Plan{Target: testTarget, Entries: []Entry{app, appByDigest, tool, sharedV1, ubiOld, ubiLatestOld, minimal}}
+	cur := &Plan{Target: testTarget, Entries: []Entry{sharedV2, ubiLatest}}
+	target := fakeTarget{ubiLatest.Destination: digestB}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("images are no longer mirrored", func(t *testing.T) {
+			d, err := NewDeletePlan(ctx, old, cur, target)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, d, &DeletePlan{
+				Target: testTarget,
+				Delete: []DeleteEntry{
+					{Reference: testTarget + "/team/app@" + digestA, Digest: digestA, Tags: []string{"v1"}, Origin: AdditionalOrigin, Type: AdditionalImage},
+					{Reference: testTarget + "/team/tool@" + digestB, Digest: digestB, Origin: OperatorOrigin, Type: RelatedImage},
+					{Reference: testTarget + "/ubi9/ubi@" + digestA, Digest: digestA, Tags: []string{"9.4"}, Origin: AdditionalOrigin, Type: AdditionalImage},
+					// the kept tag now points at another manifest
+					{Reference: testTarget + "/ubi9/ubi@" + digestC, Digest: digestC, Origin: AdditionalOrigin, Type: AdditionalImage},
+				},
+				Skipped: []SkippedDelete{
+					{Destination: testTarget + "/team/shared:v1", Reason: "digest referenced by a kept image"},
+					{Destination: testTarget + "/ubi9/ubi-minimal:9.4", Reason: "unknown digest"},
+				},
+			})
+
+			var buf bytes.Buffer
+			assert.NilError(t, d.Write(&buf))
+			assert.Assert(t, strings.Contains(buf.String(), "reference: "+testTarget+"/team/tool@"+digestB))
+			read, err := ReadDeletePlan(&buf)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, read, d)
+		})
+
+		t.Run("a kept image by tag has the removed digest", func(t *testing.T) {
+			d, err := NewDeletePlan(ctx, old, cur, fakeTarget{ubiLatest.Destination: digestA})
+			assert.NilError(t, err)
+			assert.Assert(t, !slices.ContainsFunc(d.Delete, func(e DeleteEntry) bool { return e.Digest == digestA && strings.Contains(e.Reference, "/ubi9/ubi@") }))
+			assert.Assert(t, slices.Contains(d.Skipped, SkippedDelete{Destination: ubiOld.Destination, Reason: "digest referenced by a kept image"}))
+		})
+
+		t.Run("an instance of a kept manifest list is removed", func(t *testing.T) {
+			target, _ := testregistry.New(t)
+			listDigest := testregistry.PushIndex(t, target+"/team/multi:v1")
+			instances := testregistry.Instances(t, target+"/team/multi:v1")
+			child, other := instances[0], instances[1]
+
+			list := Entry{Source: "quay.io/team/multi:v1", Destination: target + "/team/multi:v1", Digest: listDigest, Origin: AdditionalOrigin, Type: AdditionalImage}
+			instance := Entry{Source: "quay.io/team/multi@" + child.String(), Destination: target + "/team/multi@" + child.String(), Digest: child, Origin: OperatorOrigin, Type: RelatedImage}
+			// the kept list is mirrored by tag, without a known digest
+			listByTag := list
+			listByTag.Digest = ""
+			registry := RegistryTarget{SysCtx: &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}}
+			d, err := NewDeletePlan(ctx, &Plan{Target: target, Entries: []Entry{list, instance}}, &Plan{Target: target, Entries: []Entry{listByTag}}, registry)
+			assert.NilError(t, err)
+			assert.Equal(t, len(d.Delete), 0)
+			assert.DeepEqual(t, d.Skipped, []SkippedDelete{{Destination: instance.Destination, Reason: "instance of a kept manifest list"}})
+
+			// the list and all its instances can be deleted once nothing keeps it
+			d, err = NewDeletePlan(ctx, &Plan{Target: target, Entries: []Entry{list, instance}}, &Plan{Target: target}, registry)
+			assert.NilError(t, err)
+			refs := common.Map(d.Delete, func(e DeleteEntry) string { return e.Reference })
+			assert.DeepEqual(t, refs[:2], slices.Sorted(slices.Values([]string{target + "/team/multi@" + listDigest.String(), instance.Destination})))
+			assert.DeepEqual(t, refs[2:], []string{target + "/team/multi@" + other.String()})
+			assert.Equal(t, d.Delete[2].Type, list.Type)
+		})
+
+		t.Run("nothing was removed", func(t *testing.T) {
+			d, err := NewDeletePlan(ctx, cur, cur, target)
+			assert.NilError(t, err)
+			assert.Equal(t, len(d.Delete), 0)
+			assert.Equal(t, len(d.Skipped), 0)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("a destination is invalid", func(t *testing.T) {
+			_, err := NewDeletePlan(ctx, &Plan{Entries: []Entry{{Source: "quay.io/team/app:v1", Destination: "INVALID"}}}, cur, target)
+			assert.ErrorIs(t, err, libErrs.ErrInvalidRef)
+		})
+
+		t.Run("the kept manifest lists can't be read", func(t *testing.T) {
+			_, err := NewDeletePlan(ctx, old, &Plan{Target: testTarget, Entries: []Entry{appByDigest}}, failingTarget{})
+			assert.ErrorIs(t, err, libErrs.ErrDelete)
+		})
+
+		t.Run("the kept images by tag can't be resolved", func(t *testing.T) {
+			_, err := NewDeletePlan(ctx, old, cur, failingTarget{})
+			assert.ErrorIs(t, err, libErrs.ErrDelete)
+		})
+
+		t.Run("the delete plan has unknown fields", func(t *testing.T) {
+			_, err := ReadDeletePlan(strings.NewReader("target: mirror.local\nimages: []\n"))
+			assert.ErrorIs(t, err, libErrs.ErrDelete)
+		})
+	})
+}
+
+// headImage checks that the manifest of `ref` is in the registry.
+func headImage(t *testing.T, ref string) error {
+	_, err := remote.Head(testregistry.ParseRef(t, ref))
+	return err
+}
+
+func TestExecuteDelete(t *testing.T) {
+	ctx := context.Background()
+	sysCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	target, _ := testregistry.New(t)
+	appDigest := testregistry.PushImage(t, target+"/team/app:v1")
+	keptDigest := testregistry.PushImage(t, target+"/team/kept:v1")
+
+	d := &DeletePlan{Target: target, Delete: []DeleteEntry{
+		{Reference: target + "/team/app@" + appDigest.String(), Digest: appDigest, Tags: []string{"v1"}},
+	}}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("deleting manifests", func(t *testing.T) {
+			results := ExecuteDelete(ctx, d, sysCtx)
+			assert.Equal(t, len(results), 1)
+			assert.NilError(t, results[0].Err)
+			assert.Equal(t, results[0].Status, DeletedStatus)
+
+			assert.ErrorContains(t, headImage(t, target+"/team/app@"+appDigest.String()), "404")
+			assert.NilError(t, headImage(t, target+"/team/kept@"+keptDigest.String()))
+		})
+
+		t.Run("the manifests are already deleted", func(t *testing.T) {
+			results := ExecuteDelete(ctx, d, sysCtx)
+			assert.NilError(t, results[0].Err)
+			assert.Equal(t, results[0].Status, SkippedStatus)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the reference is not a digest", func(t *testing.T) {
+			results := ExecuteDelete(ctx, &DeletePlan{Delete: []DeleteEntry{{Reference: target + "/team/kept:v1"}}}, sysCtx)
+			assert.Equal(t, results[0].Status, FailedStatus)
+			assert.ErrorIs(t, results[0].Err, libErrs.ErrInvalidRef)
+		})
+	})
+}
@@ -0,0 +1,413 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/copy"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/archive"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/signature"
+	"go.podman.io/image/v5/types"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// Mode selects where images are copied from and to.
+type Mode int
+
+const (
+	// MirrorToMirror copies images from their source registries to the target registry.
+	MirrorToMirror Mode = iota
+	// MirrorToDisk copies images from their source registries to a local directory.
+	MirrorToDisk
+	// DiskToMirror copies images from a local directory, written by MirrorToDisk, to the target registry.
+	DiskToMirror
+)
+
+// DiskFormat is the format of the images stored on disk.
+type DiskFormat int
+
+const (
+	// OCILayoutFormat stores each repository as an OCI layout, with one image per tag or digest.
+	// Manifests are stored unchanged, docker manifests included, along with all the instances of
+	// manifest lists, so images keep their digest through disk.
+	OCILayoutFormat DiskFormat = iota
+	// DockerArchiveFormat stores each image as a docker-archive tarball.
+	// Archives can't hold manifest lists, so only the system image of a list is copied, and their
+	// manifests are rewritten, so destinations by digest are rejected.
+	DockerArchiveFormat
+)
+
+// ResultStatus is the outcome of mirroring an image.
+type ResultStatus string
+
+const (
+	MirroredStatus ResultStatus = "mirrored"
+	// SkippedStatus is used for images already in the destination.
+	SkippedStatus ResultStatus = "skipped"
+	FailedStatus  ResultStatus = "failed"
+)
+
+// defaultParallelism is the number of images copied at the same time, unless configured.
+const defaultParallelism = 4
+
+// ExecuteOptions is used to configure the plan execution.
+type ExecuteOptions struct {
+	Mode Mode
+	// Dir is the local directory used by MirrorToDisk and DiskToMirror.
+	Dir    string
+	Format DiskFormat
+	// Parallelism is the number of images copied at the same time. Defaults to 4.
+	Parallelism int
+	// Retries is the number of times a copy is retried after a temporary failure, such as
+	// rate limiting or a network error. Each retry waits twice as long as the previous one,
+	// starting with RetryDelay.
+	Retries    int
+	RetryDelay time.Duration
+	// Force copies images even if they are already in the destination.
+	Force          bool
+	SourceCtx      *types.SystemContext
+	DestinationCtx *types.SystemContext
+	Policy         *signature.Policy
+	// ImageSelection selects the instances of manifest lists copied to registries and docker archives.
+	// OCI layouts hold all the instances, which are all copied back by DiskToMirror.
+	ImageSelection copy.ImageListSelection
+}
+
+// Result is the outcome of mirroring a plan entry.
+type Result struct {
+	Entry  Entry
+	Status ResultStatus
+	// Digest is the manifest digest in the destination.
+	Digest   digest.Digest
+	Attempts int
+	// Err is set for failed images, classified as in `common.WrapImageError`.
+	Err error
+}
+
+// Execute copies the images of the plan and streams a result for each entry.
+// The returned channel is closed once all the entries are processed or the context is done;
+// entries not processed when the context is done are not reported.
+func Execute(ctx context.Context, p *Plan, opts ExecuteOptions) (<-chan Result, error) {
+	if opts.Mode != MirrorToMirror && opts.Dir == "" {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: missing directory for disk mirroring", libErrs.ErrMirror))
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = defaultParallelism
+	}
+	if opts.Policy == nil {
+		logger.Debug("initializing system policy")
+		var err error
+		if opts.Policy, err = signature.DefaultPolicy(opts.SourceCtx); err != nil {
+			return nil, newMirrorErr(err)
+		}
+	}
+
+	entries := make(chan Entry)
+	results := make(chan Result)
+	var wg sync.WaitGroup
+	for range opts.Parallelism {
+		wg.Go(func() {
+			// NOTE: policy contexts can't be shared between concurrent copies.
+			policyCtx, err := signature.NewPolicyContext(opts.Policy)
+			if err != nil {
+				for e := range entries {
+					sendResult(ctx, results, Result{Entry: e, Status: FailedStatus, Err: newMirrorErr(err)})
+				}
+				return
+			}
+			defer func() { _ = policyCtx.Destroy() }()
+			for e := range entries {
+				sendResult(ctx, results, mirrorEntry(ctx, policyCtx, p.Target, e, opts))
+			}
+		})
+	}
+
+	go func() {
+		defer close(results)
+		defer wg.Wait()
+		defer close(entries)
+		for _, e := range p.Entries {
+			select {
+			case entries <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
+
+func sendResult(ctx context.Context, results chan<- Result, res Result) {
+	select {
+	case results <- res:
+	case <-ctx.Done():
+	}
+}
+
+// mirrorEntry copies a single image, retrying temporary failures.
+func mirrorEntry(ctx context.Context, policyCtx *signature.PolicyContext, target string, e Entry, opts ExecuteOptions) Result {
+	lg := logger.With(slog.String("source", e.Source), slog.String("destination", e.Destination))
+	res := Result{Entry: e, Status: FailedStatus}
+	srcRef, destRef, err := entryReferences(target, e, opts)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	delay := opts.RetryDelay
+	for res.Attempts = 1; ; res.Attempts++ {
+		res.Status, res.Digest, res.Err = copyImage(ctx, policyCtx, target, srcRef, destRef, e, opts)
+		if res.Err == nil || res.Attempts > opts.Retries || !libErrs.IsTemporary(res.Err) {
+			break
+		}
+		lg.Warn("retrying image copy", slog.Int("attempt", res.Attempts), slog.Any("error", res.Err))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			res.Err = newMirrorErr(ctx.Err())
+			return res
+		}
+		delay *= 2
+	}
+	if res.Err != nil {
+		lg.Error("image copy failed", slog.Any("error", res.Err))
+	} else {
+		lg.Info("image copied", slog.String("status", string(res.Status)), slog.String("digest", res.Digest.String()))
+	}
+	return res
+}
+
+func copyImage(ctx context.Context, policyCtx *signature.PolicyContext, target string, srcRef, destRef types.ImageReference, e Entry, opts ExecuteOptions) (ResultStatus, digest.Digest, error) {
+	if !opts.Force {
+		dgst, err := existingDigest(ctx, srcRef, destRef, e, opts)
+		if err != nil {
+			return FailedStatus, "", err
+		}
+		if dgst != "" {
+			return SkippedStatus, dgst, nil
+		}
+	}
+
+	if opts.Mode == MirrorToDisk && opts.Format == OCILayoutFormat {
+		repoDir, name, _, err := diskPath(opts.Dir, target, e)
+		if err != nil {
+			return FailedStatus, "", newMirrorErr(err)
+		}
+		dgst, err := writeLayoutImage(ctx, policyCtx, srcRef, repoDir, name, opts.SourceCtx)
+		if err != nil {
+			return FailedStatus, "", newMirrorErr(err)
+		}
+		return MirroredStatus, dgst, nil
+	}
+
+	selection := opts.ImageSelection
+	if opts.Mode == DiskToMirror && opts.Format == OCILayoutFormat {
+		selection = copy.CopyAllImages
+	}
+	_, isDigestDest := destRef.DockerReference().(reference.Canonical)
+	rawManifest, err := copy.Image(ctx, policyCtx, destRef, srcRef, &copy.Options{
+		SourceCtx:          opts.SourceCtx,
+		DestinationCtx:     opts.DestinationCtx,
+		RemoveSignatures:   opts.Mode == MirrorToDisk, // NOTE: docker archives don't support signatures
+		ImageListSelection: selection,
+		// NOTE: pushing by digest requires the manifest to be copied unchanged.
+		PreserveDigests: isDigestDest,
+	})
+	if err != nil {
+		return FailedStatus, "", newMirrorErr(err)
+	}
+	dgst, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return FailedStatus, "", newMirrorErr(err)
+	}
+	return MirroredStatus, dgst, nil
+}
+
+// existingDigest returns the digest of the image in the destination if it needs no copy, or an empty digest.
+// Registry destinations are up to date when they have the source digest; disk destinations when they exist,
+// since docker archives change the manifest. For the same reason, images copied from disk are also
+// up to date when they have the same configuration as the source.
+func existingDigest(ctx context.Context, srcRef, destRef types.ImageReference, e Entry, opts ExecuteOptions) (digest.Digest, error) {
+	destDigest, err := manifestDigest(ctx, destRef, opts.DestinationCtx)
+	if err != nil {
+		// NOTE: any failure to read the destination is handled by the copy.
+		return "", nil
+	}
+	if opts.Mode == MirrorToDisk {
+		return destDigest, nil
+	}
+	want := e.Digest
+	if want == "" || opts.Mode == DiskToMirror {
+		if want, err = manifestDigest(ctx, srcRef, opts.SourceCtx); err != nil {
+			return "", newMirrorErr(err)
+		}
+	}
+	if destDigest == want {
+		return destDigest, nil
+	}
+	if opts.Mode == DiskToMirror {
+		srcConfig, err := configDigest(ctx, srcRef, opts.SourceCtx)
+		if err != nil {
+			return "", newMirrorErr(err)
+		}
+		if destConfig, err := configDigest(ctx, destRef, opts.DestinationCtx); err == nil && destConfig == srcConfig {
+			return destDigest, nil
+		}
+	}
+	return "", nil
+}
+
+// configDigest returns the digest of the image configuration, or of the system image configuration for lists.
+func configDigest(ctx context.Context, ref types.ImageReference, sysCtx *types.SystemContext) (digest.Digest, error) {
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
+	img, err := image.FromUnparsedImage(ctx, sysCtx, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return "", err
+	}
+	return img.ConfigInfo().Digest, nil
+}
+
+func manifestDigest(ctx context.Context, ref types.ImageReference, sysCtx *types.SystemContext) (digest.Digest, error) {
+	if ref.Transport().Name() == docker.Transport.Name() {
+		return docker.GetDigest(ctx, sysCtx, ref)
+	}
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
+	rawManifest, _, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest(rawManifest)
+}
+
+// ResolveDigest returns the manifest digest the source of the entry currently resolves to.
+func ResolveDigest(ctx context.Context, e Entry, sysCtx *types.SystemContext) (digest.Digest, error) {
+	ref, err := SourceReference(e)
+	if err != nil {
+		return "", libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrMirror, libErrs.ErrInvalidRef, err))
+	}
+	dgst, err := manifestDigest(ctx, ref, sysCtx)
+	if err != nil {
+		return "", newMirrorErr(err)
+	}
+	return dgst, nil
+}
+
+// entryReferences returns the source and destination of the entry for the execution mode.
+func entryReferences(target string, e Entry, opts ExecuteOptions) (types.ImageReference, types.ImageReference, error) {
+	var srcRef, destRef types.ImageReference
+	var err error
+	switch opts.Mode {
+	case MirrorToMirror:
+		if srcRef, err = SourceReference(e); err == nil {
+			destRef, err = docker.ParseReference("//" + e.Destination)
+		}
+	case MirrorToDisk:
+		repoDir, _, _, pathErr := diskPath(opts.Dir, target, e)
+		if pathErr == nil {
+			if err := os.MkdirAll(repoDir, 0o755); err != nil {
+				return nil, nil, newMirrorErr(err)
+			}
+		}
+		if srcRef, err = SourceReference(e); err == nil {
+			destRef, err = DiskReference(opts.Dir, opts.Format, target, e)
+		}
+	case DiskToMirror:
+		if srcRef, err = DiskReference(opts.Dir, opts.Format, target, e); err == nil {
+			destRef, err = docker.ParseReference("//" + e.Destination)
+		}
+	default:
+		err = fmt.Errorf("unknown mode %d", opts.Mode)
+	}
+	if err != nil {
+		return nil, nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrMirror, libErrs.ErrInvalidRef, err))
+	}
+	return srcRef, destRef, nil
+}
+
+// SourceReference returns the registry or OCI layout source of the entry.
+// Registry sources are pinned to the entry digest, when known.
+func SourceReference(e Entry) (types.ImageReference, error) {
+	if ociRef, ok := strings.CutPrefix(e.Source, ociPrefix); ok {
+		return layout.ParseReference(ociRef)
+	}
+	named, err := reference.ParseNormalizedNamed(e.Source)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := named.(reference.Canonical); !ok && e.Digest != "" {
+		if named, err = reference.WithDigest(reference.TrimNamed(named), e.Digest); err != nil {
+			return nil, err
+		}
+	}
+	return docker.NewReference(named)
+}
+
+// DiskReference returns where the entry is stored on disk under `dir`.
+// Images are stored by their destination repository, relative to the plan `target`,
+// and named by their destination tag or digest.
+func DiskReference(dir string, format DiskFormat, target string, e Entry) (types.ImageReference, error) {
+	repoDir, name, tagged, err := diskPath(dir, target, e)
+	if err != nil {
+		return nil, err
+	}
+	if format == DockerArchiveFormat {
+		if tagged == nil {
+			return nil, errors.New("docker archives can't preserve the digest of destinations by digest")
+		}
+		return archive.NewReference(filepath.Join(repoDir, name+".tar"), tagged)
+	}
+	return layout.NewReference(repoDir, name)
+}
+
+// diskPath returns the repository directory of the entry under `dir` and its image name,
+// along with its destination tag, if any. Destinations outside of `target` keep their full name.
+func diskPath(dir string, target string, e Entry) (string, string, reference.NamedTagged, error) {
+	named, err := reference.ParseNormalizedNamed(e.Destination)
+	if err != nil {
+		return "", "", nil, err
+	}
+	repo := named.Name()
+	if target = strings.TrimSuffix(strings.TrimPrefix(target, dockerPrefix), "/"); target != "" {
+		// NOTE: the target is normalized as the parent of its destinations, e.g. `foo` as `docker.io/foo`.
+		parent, err := reference.ParseNormalizedNamed(target + "/image")
+		if err != nil {
+			return "", "", nil, err
+		}
+		repo = strings.TrimPrefix(repo, strings.TrimSuffix(parent.Name(), "image"))
+	}
+	repoDir := filepath.Join(dir, filepath.FromSlash(repo))
+	if tagged, ok := named.(reference.NamedTagged); ok {
+		return repoDir, tagged.Tag(), tagged, nil
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		return repoDir, canonical.Digest().Encoded(), nil, nil
+	}
+	return "", "", nil, errors.New("destination without tag or digest")
+}
+
+func newMirrorErr(err error) *libErrs.Error {
+	return common.WrapImageError(libErrs.PlanErrorKind, libErrs.ErrMirror, err)
+}
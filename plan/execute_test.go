@@ -0,0 +1,242 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/signature"
+	"go.podman.io/image/v5/types"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/internal/testregistry"
+)
+
+func testExecuteOptions(mode Mode) ExecuteOptions {
+	sysCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	return ExecuteOptions{
+		Mode:           mode,
+		Parallelism:    2,
+		SourceCtx:      sysCtx,
+		DestinationCtx: sysCtx,
+		Policy:         &signature.Policy{Default: signature.PolicyRequirements{signature.NewPRInsecureAcceptAnything()}},
+	}
+}
+
+// collect executes the plan and returns the results by source.
+func collect(t *testing.T, p *Plan, opts ExecuteOptions) map[string]Result {
+	results, err := Execute(context.Background(), p, opts)
+	assert.NilError(t, err)
+	bySource := map[string]Result{}
+	for res := range results {
+		bySource[res.Entry.Source] = res
+	}
+	assert.Equal(t, len(bySource), len(p.Entries))
+	return bySource
+}
+
+func TestExecute(t *testing.T) {
+	src, _ := testregistry.New(t)
+	appDigest := testregistry.PushImage(t, src+"/team/app:v1")
+	toolDigest := testregistry.PushImage(t, src+"/team/tool:v2")
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("mirroring to a registry", func(t *testing.T) {
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest + "/mirror", Entries: []Entry{
+				{Source: src + "/team/app:v1", Destination: dest + "/mirror/team/app:v1"},
+				{Source: src + "/team/tool@" + toolDigest.String(), Destination: dest + "/mirror/team/tool@" + toolDigest.String(), Digest: toolDigest},
+			}}
+			results := collect(t, p, testExecuteOptions(MirrorToMirror))
+			assert.Equal(t, results[src+"/team/app:v1"].Status, MirroredStatus)
+			assert.Equal(t, results[src+"/team/app:v1"].Digest, appDigest)
+			assert.Equal(t, results[src+"/team/tool@"+toolDigest.String()].Digest, toolDigest)
+
+			dgst, err := ResolveDigest(context.Background(), p.Entries[0], testExecuteOptions(MirrorToMirror).SourceCtx)
+			assert.NilError(t, err)
+			assert.Equal(t, dgst, appDigest)
+
+			// a second run finds the images in the destination
+			for _, res := range collect(t, p, testExecuteOptions(MirrorToMirror)) {
+				assert.NilError(t, res.Err)
+				assert.Equal(t, res.Status, SkippedStatus)
+			}
+		})
+
+		t.Run("mirroring through disk", func(t *testing.T) {
+			for _, format := range []DiskFormat{OCILayoutFormat, DockerArchiveFormat} {
+				dest, _ := testregistry.New(t)
+				p := &Plan{Target: dest + "/mirror", Entries: []Entry{
+					{Source: src + "/team/app:v1", Destination: dest + "/mirror/team/app:v1"},
+					{Source: src + "/team/tool:v2", Destination: dest + "/mirror/team/tool:v2"},
+				}}
+				m2d := testExecuteOptions(MirrorToDisk)
+				m2d.Dir, m2d.Format = t.TempDir(), format
+				for _, res := range collect(t, p, m2d) {
+					assert.NilError(t, res.Err)
+					assert.Equal(t, res.Status, MirroredStatus)
+				}
+				for _, res := range collect(t, p, m2d) {
+					assert.Equal(t, res.Status, SkippedStatus)
+				}
+
+				d2m := testExecuteOptions(DiskToMirror)
+				d2m.Dir, d2m.Format = m2d.Dir, format
+				for _, res := range collect(t, p, d2m) {
+					assert.NilError(t, res.Err)
+					assert.Equal(t, res.Status, MirroredStatus)
+				}
+				for _, res := range collect(t, p, d2m) {
+					assert.Equal(t, res.Status, SkippedStatus)
+				}
+			}
+		})
+
+		t.Run("mirroring a repository through disk in parallel", func(t *testing.T) {
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest + "/mirror"}
+			digests := map[string]digest.Digest{}
+			for i := range 8 {
+				source := fmt.Sprintf("%s/team/parallel:v%d", src, i)
+				digests[source] = testregistry.PushImage(t, source)
+				p.Entries = append(p.Entries, Entry{Source: source, Destination: fmt.Sprintf("%s/mirror/team/parallel:v%d", dest, i)})
+			}
+			m2d := testExecuteOptions(MirrorToDisk)
+			m2d.Dir, m2d.Parallelism = t.TempDir(), 4
+			for _, res := range collect(t, p, m2d) {
+				assert.NilError(t, res.Err)
+				assert.Equal(t, res.Digest, digests[res.Entry.Source])
+			}
+
+			d2m := testExecuteOptions(DiskToMirror)
+			d2m.Dir, d2m.Parallelism = m2d.Dir, 4
+			for _, res := range collect(t, p, d2m) {
+				assert.NilError(t, res.Err)
+				assert.Equal(t, res.Status, MirroredStatus)
+				assert.Equal(t, res.Digest, digests[res.Entry.Source])
+			}
+		})
+
+		t.Run("mirroring through disk to destinations by digest", func(t *testing.T) {
+			indexDigest := testregistry.PushIndex(t, src+"/team/multi:v1")
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest + "/mirror", Entries: []Entry{
+				{Source: src + "/team/app@" + appDigest.String(), Destination: dest + "/mirror/team/app@" + appDigest.String(), Digest: appDigest},
+				{Source: src + "/team/multi@" + indexDigest.String(), Destination: dest + "/mirror/team/multi@" + indexDigest.String(), Digest: indexDigest},
+			}}
+			m2d := testExecuteOptions(MirrorToDisk)
+			m2d.Dir = t.TempDir()
+			for _, res := range collect(t, p, m2d) {
+				assert.NilError(t, res.Err)
+				assert.Equal(t, res.Digest, res.Entry.Digest)
+			}
+
+			d2m := testExecuteOptions(DiskToMirror)
+			d2m.Dir = m2d.Dir
+			for _, res := range collect(t, p, d2m) {
+				assert.NilError(t, res.Err)
+				assert.Equal(t, res.Status, MirroredStatus)
+				assert.Equal(t, res.Digest, res.Entry.Digest)
+			}
+			// the destination is now up to date with the source registry
+			for _, res := range collect(t, p, testExecuteOptions(MirrorToMirror)) {
+				assert.NilError(t, res.Err)
+				assert.Equal(t, res.Status, SkippedStatus)
+			}
+		})
+
+		t.Run("retrying temporary failures", func(t *testing.T) {
+			flaky, failures := testregistry.New(t)
+			testregistry.PushImage(t, flaky+"/team/flaky:v1")
+			failures.Store(2)
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest, Entries: []Entry{{Source: flaky + "/team/flaky:v1", Destination: dest + "/team/flaky:v1"}}}
+			opts := testExecuteOptions(MirrorToMirror)
+			opts.Retries = 2
+			res := collect(t, p, opts)[flaky+"/team/flaky:v1"]
+			assert.NilError(t, res.Err)
+			assert.Equal(t, res.Status, MirroredStatus)
+			assert.Equal(t, res.Attempts, 3)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("temporary failures persist", func(t *testing.T) {
+			flaky, failures := testregistry.New(t)
+			failures.Store(10)
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest, Entries: []Entry{{Source: flaky + "/team/flaky:v1", Destination: dest + "/team/flaky:v1"}}}
+			opts := testExecuteOptions(MirrorToMirror)
+			opts.Retries = 1
+			res := collect(t, p, opts)[flaky+"/team/flaky:v1"]
+			assert.Equal(t, res.Status, FailedStatus)
+			assert.Equal(t, res.Attempts, 2)
+			assert.ErrorIs(t, res.Err, libErrs.ErrMirror)
+			assert.Assert(t, libErrs.IsTemporary(res.Err))
+		})
+
+		t.Run("the source image is missing", func(t *testing.T) {
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest, Entries: []Entry{{Source: src + "/team/missing:v1", Destination: dest + "/team/missing:v1"}}}
+			opts := testExecuteOptions(MirrorToMirror)
+			opts.Retries = 3
+			res := collect(t, p, opts)[src+"/team/missing:v1"]
+			assert.Equal(t, res.Status, FailedStatus)
+			assert.Equal(t, res.Attempts, 1)
+			assert.ErrorIs(t, res.Err, libErrs.ErrMirror)
+		})
+
+		t.Run("a docker archive destination is by digest", func(t *testing.T) {
+			dest, _ := testregistry.New(t)
+			p := &Plan{Target: dest, Entries: []Entry{
+				{Source: src + "/team/app@" + appDigest.String(), Destination: dest + "/team/app@" + appDigest.String(), Digest: appDigest},
+			}}
+			opts := testExecuteOptions(MirrorToDisk)
+			opts.Dir, opts.Format = t.TempDir(), DockerArchiveFormat
+			res := collect(t, p, opts)[src+"/team/app@"+appDigest.String()]
+			assert.Equal(t, res.Status, FailedStatus)
+			assert.ErrorIs(t, res.Err, libErrs.ErrInvalidRef)
+		})
+
+		t.Run("the disk directory is missing", func(t *testing.T) {
+			_, err := Execute(context.Background(), &Plan{}, ExecuteOptions{Mode: MirrorToDisk})
+			assert.ErrorIs(t, err, libErrs.ErrMirror)
+		})
+	})
+}
+
+func TestDiskPath(t *testing.T) {
+	dir := t.TempDir()
+	entry := Entry{Source: "quay.io/team/app:v1", Destination: "registry.example.com:5000/mirror/team/app:v1"}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			target string
+			entry  Entry
+			want   string
+		}{
+			{name: "the target is a namespace", target: "registry.example.com:5000/mirror", entry: entry, want: "team/app"},
+			{name: "the target has a trailing slash", target: "docker://registry.example.com:5000/mirror/", entry: entry, want: "team/app"},
+			{name: "the target is a prefix of another namespace", target: "registry.example.com:5000/mir", entry: entry, want: "registry.example.com:5000/mirror/team/app"},
+			{name: "the target is a docker hub namespace", target: "foo", entry: Entry{Destination: "foo/team/app:v1"}, want: "team/app"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				repoDir, name, _, err := diskPath(dir, tc.target, tc.entry)
+				assert.NilError(t, err)
+				assert.Equal(t, repoDir, filepath.Join(dir, tc.want))
+				assert.Equal(t, name, "v1")
+			})
+		}
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the target is invalid", func(t *testing.T) {
+			_, _, _, err := diskPath(dir, "INVALID", entry)
+			assert.ErrorContains(t, err, "repository name must be lowercase")
+		})
+	})
+}
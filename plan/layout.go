@@ -0,0 +1,196 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/signature"
+	"go.podman.io/image/v5/types"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+)
+
+// layoutLocks serializes the index updates of OCI layouts, by layout directory.
+var layoutLocks sync.Map
+
+// writeLayoutImage copies the source image, with all its instances, to the OCI layout in `dir` as `name`,
+// and returns its manifest digest.
+//
+// Unlike `copy.Image`, which converts docker manifests for OCI layouts, manifests are stored unchanged
+// so that images keep their digest. Blobs are written atomically and index updates are serialized,
+// so concurrent copies can share a layout.
+func writeLayoutImage(ctx context.Context, policyCtx *signature.PolicyContext, srcRef types.ImageReference, dir, name string, sysCtx *types.SystemContext) (digest.Digest, error) {
+	src, err := srcRef.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
+
+	allowed, err := policyCtx.IsRunningImageAllowed(ctx, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return "", err
+	}
+	if !allowed {
+		return "", errors.New("source image rejected by policy")
+	}
+
+	rawManifest, mimeType, err := src.GetManifest(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	dgst, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return "", err
+	}
+	if err := writeLayoutManifest(ctx, src, dir, rawManifest, mimeType); err != nil {
+		return "", err
+	}
+	desc := imgspecv1.Descriptor{
+		MediaType:   mimeType,
+		Digest:      dgst,
+		Size:        int64(len(rawManifest)),
+		Annotations: map[string]string{imgspecv1.AnnotationRefName: name},
+	}
+	if err := addLayoutDescriptor(dir, desc); err != nil {
+		return "", err
+	}
+	return dgst, nil
+}
+
+// writeLayoutManifest writes the manifest blob with its instances, config and layers.
+func writeLayoutManifest(ctx context.Context, src types.ImageSource, dir string, rawManifest []byte, mimeType string) error {
+	dgst, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return err
+	}
+
+	if manifest.MIMETypeIsMultiImage(mimeType) {
+		list, err := manifest.ListFromBlob(rawManifest, mimeType)
+		if err != nil {
+			return err
+		}
+		for _, instance := range list.Instances() {
+			raw, instanceType, err := src.GetManifest(ctx, &instance)
+			if err != nil {
+				return err
+			}
+			if err := writeLayoutManifest(ctx, src, dir, raw, instanceType); err != nil {
+				return err
+			}
+		}
+	} else {
+		m, err := manifest.FromBlob(rawManifest, mimeType)
+		if err != nil {
+			return err
+		}
+		infos := []types.BlobInfo{m.ConfigInfo()}
+		for _, layer := range m.LayerInfos() {
+			infos = append(infos, layer.BlobInfo)
+		}
+		for _, info := range infos {
+			if info.Digest == "" {
+				continue
+			}
+			if err := writeLayoutBlob(dir, info.Digest, func() (io.ReadCloser, error) {
+				reader, _, err := src.GetBlob(ctx, info, none.NoCache)
+				return reader, err
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	// NOTE: the manifest goes last, so a layout never references missing blobs.
+	return writeLayoutBlob(dir, dgst, func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(rawManifest)), nil
+	})
+}
+
+// writeLayoutBlob writes the blob to the layout, verifying its digest, unless it is already there.
+func writeLayoutBlob(dir string, dgst digest.Digest, open func() (io.ReadCloser, error)) error {
+	blobDir := filepath.Join(dir, "blobs", dgst.Algorithm().String())
+	blobFile := filepath.Join(blobDir, dgst.Encoded())
+	if _, err := os.Stat(blobFile); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(blobDir, 0o755); err != nil {
+		return err
+	}
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = reader.Close() }()
+
+	tmp, err := os.CreateTemp(blobDir, dgst.Encoded()+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	verifier := dgst.Verifier()
+	if _, err := io.Copy(tmp, io.TeeReader(reader, verifier)); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("blob %s doesn't match its digest", dgst)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), blobFile)
+}
+
+// addLayoutDescriptor adds the descriptor to the layout index, replacing any image of the same name.
+func addLayoutDescriptor(dir string, desc imgspecv1.Descriptor) error {
+	lock, _ := layoutLocks.LoadOrStore(filepath.Clean(dir), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	layoutData, err := json.Marshal(imgspecv1.ImageLayout{Version: imgspecv1.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+	if err := common.WriteFileAtomic(filepath.Join(dir, imgspecv1.ImageLayoutFile), layoutData); err != nil {
+		return err
+	}
+
+	indexFile := filepath.Join(dir, "index.json")
+	index := imgspecv1.Index{Versioned: imgspecs.Versioned{SchemaVersion: 2}, MediaType: imgspecv1.MediaTypeImageIndex}
+	data, err := os.ReadFile(indexFile)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &index); err != nil {
+			return err
+		}
+	}
+	name := desc.Annotations[imgspecv1.AnnotationRefName]
+	index.Manifests = slices.DeleteFunc(index.Manifests, func(d imgspecv1.Descriptor) bool {
+		return d.Annotations[imgspecv1.AnnotationRefName] == name
+	})
+	index.Manifests = append(index.Manifests, desc)
+	if data, err = json.Marshal(index); err != nil {
+		return err
+	}
+	return common.WriteFileAtomic(indexFile, data)
+}
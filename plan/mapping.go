@@ -0,0 +1,95 @@
+package plan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.podman.io/image/v5/docker/reference"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// WriteMapping writes the plan in the `mapping.txt` format consumed by `oc image mirror --filename`,
+// i.e. one `source=destination` line per entry.
+func (p *Plan) WriteMapping(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range p.Entries {
+		if _, err := fmt.Fprintf(bw, "%s=%s\n", e.Source, e.Destination); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadMapping reads a plan in the `mapping.txt` format. Blank lines and lines starting with `#` are ignored.
+// The entries have no origin information and are imported as additional images. Their digest is taken
+// from the source reference, when pinned. `target` is the destination registry and path prefix of the plan.
+func ReadMapping(r io.Reader, target string) (*Plan, error) {
+	p := &Plan{Target: strings.TrimSuffix(target, "/")}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		src, dest, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: line %d: missing `=` separator", libErrs.ErrParseMapping, line))
+		}
+		e, err := mappingEntry(strings.TrimSpace(src), strings.TrimSpace(dest))
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: line %d: %w: %w", libErrs.ErrParseMapping, line, libErrs.ErrInvalidRef, err))
+		}
+		p.Entries = append(p.Entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, libErrs.NewErr(libErrs.PlanErrorKind, fmt.Errorf("%w: %w", libErrs.ErrParseMapping, err))
+	}
+	sortEntries(p.Entries)
+	return p, nil
+}
+
+// WriteJSON writes the plan as JSON, keeping the digests and origins of the entries.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// ReadJSON reads a plan written by WriteJSON.
+func ReadJSON(r io.Reader) (*Plan, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	var p Plan
+	if err := dec.Decode(&p); err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w", libErrs.ErrParseMapping, err))
+	}
+	for _, e := range p.Entries {
+		if _, err := mappingEntry(e.Source, e.Destination); err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrParseMapping, libErrs.ErrInvalidRef, err))
+		}
+	}
+	return &p, nil
+}
+
+// mappingEntry validates the references of a mapping line and returns its entry.
+func mappingEntry(src, dest string) (Entry, error) {
+	e := Entry{Source: src, Destination: dest, Origin: AdditionalOrigin, Type: AdditionalImage}
+	if _, err := reference.ParseNormalizedNamed(dest); err != nil {
+		return Entry{}, fmt.Errorf("destination %q: %w", dest, err)
+	}
+	if strings.HasPrefix(src, ociPrefix) {
+		return e, nil
+	}
+	named, err := reference.ParseNormalizedNamed(src)
+	if err != nil {
+		return Entry{}, fmt.Errorf("source %q: %w", src, err)
+	}
+	if canonical, ok := named.(reference.Canonical); ok {
+		e.Digest = canonical.Digest()
+	}
+	return e, nil
+}
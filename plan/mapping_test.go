@@ -0,0 +1,75 @@
+package plan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestMapping(t *testing.T) {
+	p := &Plan{Target: testTarget, Entries: []Entry{
+		{Source: etcdImage, Destination: testTarget + "/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111", Digest: "sha256:1111111111111111111111111111111111111111111111111111111111111111", Origin: ReleaseOrigin, Type: ReleaseComponentImage, RequiredBy: []string{"4.19.1/etcd"}},
+		{Source: "oci:/tmp/catalogs/index:v1", Destination: testTarget + "/index:v1", Digest: "sha256:cafe", Origin: OperatorOrigin, Type: CatalogImage},
+		{Source: "registry.redhat.io/ubi9/ubi:latest", Destination: testTarget + "/ubi9/ubi:latest", Origin: AdditionalOrigin, Type: AdditionalImage},
+	}}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("round-tripping the mapping format", func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NilError(t, p.WriteMapping(&buf))
+			assert.Equal(t, buf.String(), strings.Join([]string{
+				etcdImage + "=" + testTarget + "/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				"oci:/tmp/catalogs/index:v1=" + testTarget + "/index:v1",
+				"registry.redhat.io/ubi9/ubi:latest=" + testTarget + "/ubi9/ubi:latest",
+			}, "\n")+"\n")
+
+			read, err := ReadMapping(strings.NewReader("# curated\n\n"+buf.String()), testTarget+"/")
+			assert.NilError(t, err)
+			assert.Equal(t, read.Target, testTarget)
+			assert.Equal(t, len(read.Entries), 3)
+			assert.DeepEqual(t, findEntry(t, read, etcdImage), Entry{
+				Source:      etcdImage,
+				Destination: p.Entries[0].Destination,
+				Digest:      p.Entries[0].Digest,
+				Origin:      AdditionalOrigin,
+				Type:        AdditionalImage,
+			})
+			assert.Equal(t, findEntry(t, read, "registry.redhat.io/ubi9/ubi:latest").Digest.String(), "")
+		})
+
+		t.Run("round-tripping the JSON format", func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NilError(t, p.WriteJSON(&buf))
+			read, err := ReadJSON(&buf)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, read, p)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		for name, mapping := range map[string]string{
+			"the separator is missing":   "registry.redhat.io/ubi9/ubi:latest",
+			"the source is invalid":      "registry.redhat.io/UBI9:latest=" + testTarget + "/ubi9:latest",
+			"the destination is invalid": "registry.redhat.io/ubi9/ubi:latest=",
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := ReadMapping(strings.NewReader(mapping), testTarget)
+				assert.ErrorIs(t, err, libErrs.ErrParseMapping)
+			})
+		}
+
+		t.Run("the JSON has unknown fields", func(t *testing.T) {
+			_, err := ReadJSON(strings.NewReader(`{"target": "mirror.local", "entries": [], "extra": true}`))
+			assert.ErrorIs(t, err, libErrs.ErrParseMapping)
+		})
+
+		t.Run("a JSON entry is invalid", func(t *testing.T) {
+			_, err := ReadJSON(strings.NewReader(`{"target": "mirror.local", "entries": [{"source": "ubi", "destination": "INVALID"}]}`))
+			assert.ErrorIs(t, err, libErrs.ErrInvalidRef)
+		})
+	})
+}
@@ -0,0 +1,220 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.podman.io/image/v5/docker/reference"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// ReleaseRepositories are the repositories of OCP and OKD release payloads and their components.
+// Their mirrors are never collapsed and are kept in their own resources, named with a `-release` suffix.
+var ReleaseRepositories = []string{
+	"quay.io/openshift-release-dev/ocp-release",
+	"quay.io/openshift-release-dev/ocp-v4.0-art-dev",
+	"quay.io/openshift/okd",
+	"quay.io/openshift/okd-content",
+}
+
+// defaultMirrorSetName is the name of the generated resources, unless configured.
+const defaultMirrorSetName = "oc-mirror"
+
+// ImageDigestMirrorSet is the `config.openshift.io/v1` resource for images pulled by digest.
+type ImageDigestMirrorSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ImageDigestMirrorSetSpec `json:"spec"`
+}
+
+type ImageDigestMirrorSetSpec struct {
+	ImageDigestMirrors []ImageMirrors `json:"imageDigestMirrors"`
+}
+
+// ImageTagMirrorSet is the `config.openshift.io/v1` resource for images pulled by tag.
+type ImageTagMirrorSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ImageTagMirrorSetSpec `json:"spec"`
+}
+
+type ImageTagMirrorSetSpec struct {
+	ImageTagMirrors []ImageMirrors `json:"imageTagMirrors"`
+}
+
+// ImageContentSourcePolicy is the legacy `operator.openshift.io/v1alpha1` resource for images pulled by digest.
+type ImageContentSourcePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+	Spec              ImageContentSourcePolicySpec `json:"spec"`
+}
+
+type ImageContentSourcePolicySpec struct {
+	RepositoryDigestMirrors []ImageMirrors `json:"repositoryDigestMirrors"`
+}
+
+// ImageMirrors is a source repository, or repository prefix, and its mirrors.
+type ImageMirrors struct {
+	Source  string   `json:"source"`
+	Mirrors []string `json:"mirrors"`
+}
+
+// MirrorSetsOptions is used to configure the generated mirror resources.
+type MirrorSetsOptions struct {
+	// Name of the resources. Defaults to "oc-mirror".
+	Name string
+	// CollapseDepth groups repositories by their first `CollapseDepth` path components,
+	// e.g. with 1, `registry.redhat.io/rhbk/keycloak-operator` is mirrored as `registry.redhat.io/rhbk`.
+	// Repositories are only grouped when their mirrors keep the same path. Zero keeps full repositories.
+	CollapseDepth int
+	// ICSP also generates the legacy ImageContentSourcePolicy resources, for clusters older than 4.13.
+	ICSP bool
+}
+
+// MirrorSets are the resources that redirect the cluster image pulls to the mirror registry.
+type MirrorSets struct {
+	IDMS []ImageDigestMirrorSet
+	ITMS []ImageTagMirrorSet
+	ICSP []ImageContentSourcePolicy
+}
+
+// MirrorSets returns the mirror resources for the plan entries.
+// Sources pinned by digest are mirrored with ImageDigestMirrorSets, and sources by tag with
+// ImageTagMirrorSets. Entries from local OCI layouts have no source to redirect and are skipped.
+func (p *Plan) MirrorSets(opts MirrorSetsOptions) (*MirrorSets, error) {
+	if opts.Name == "" {
+		opts.Name = defaultMirrorSetName
+	}
+	if opts.CollapseDepth < 0 {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: negative collapse depth %d", libErrs.ErrBuildPlan, opts.CollapseDepth))
+	}
+
+	var releaseDigests, releaseTags, digests, tags mirrorGroups
+	for _, e := range p.Entries {
+		if strings.HasPrefix(e.Source, ociPrefix) {
+			continue
+		}
+		src, err := reference.ParseNormalizedNamed(e.Source)
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, err))
+		}
+		dest, err := reference.ParseNormalizedNamed(e.Destination)
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrBuildPlan, libErrs.ErrInvalidRef, err))
+		}
+
+		_, byDigest := src.(reference.Canonical)
+		release := e.Origin == ReleaseOrigin || slices.Contains(ReleaseRepositories, src.Name())
+		switch {
+		case byDigest && release:
+			releaseDigests.add(src.Name(), dest.Name())
+		case byDigest:
+			digests.add(collapse(src.Name(), dest.Name(), opts.CollapseDepth))
+		case release:
+			releaseTags.add(src.Name(), dest.Name())
+		default:
+			tags.add(collapse(src.Name(), dest.Name(), opts.CollapseDepth))
+		}
+	}
+
+	ms := &MirrorSets{}
+	for _, group := range []struct {
+		name    string
+		mirrors mirrorGroups
+	}{
+		{name: opts.Name + "-release", mirrors: releaseDigests},
+		{name: opts.Name, mirrors: digests},
+	} {
+		if len(group.mirrors) == 0 {
+			continue
+		}
+		ms.IDMS = append(ms.IDMS, ImageDigestMirrorSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "config.openshift.io/v1", Kind: "ImageDigestMirrorSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: group.name},
+			Spec:       ImageDigestMirrorSetSpec{ImageDigestMirrors: group.mirrors.list()},
+		})
+		if opts.ICSP {
+			ms.ICSP = append(ms.ICSP, ImageContentSourcePolicy{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "operator.openshift.io/v1alpha1", Kind: "ImageContentSourcePolicy"},
+				ObjectMeta: metav1.ObjectMeta{Name: group.name},
+				Spec:       ImageContentSourcePolicySpec{RepositoryDigestMirrors: group.mirrors.list()},
+			})
+		}
+	}
+	for _, group := range []struct {
+		name    string
+		mirrors mirrorGroups
+	}{
+		{name: opts.Name + "-release", mirrors: releaseTags},
+		{name: opts.Name, mirrors: tags},
+	} {
+		if len(group.mirrors) == 0 {
+			continue
+		}
+		ms.ITMS = append(ms.ITMS, ImageTagMirrorSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "config.openshift.io/v1", Kind: "ImageTagMirrorSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: group.name},
+			Spec:       ImageTagMirrorSetSpec{ImageTagMirrors: group.mirrors.list()},
+		})
+	}
+	return ms, nil
+}
+
+// Write writes all the resources to `w` as a multi-document YAML stream.
+func (m *MirrorSets) Write(w io.Writer) error {
+	objs := []any{}
+	for _, idms := range m.IDMS {
+		objs = append(objs, idms)
+	}
+	for _, itms := range m.ITMS {
+		objs = append(objs, itms)
+	}
+	for _, icsp := range m.ICSP {
+		objs = append(objs, icsp)
+	}
+	return common.WriteManifests(w, objs...)
+}
+
+// mirrorGroups are the mirrors of each source repository.
+type mirrorGroups map[string]sets.Set[string]
+
+func (g *mirrorGroups) add(src, dest string) {
+	if *g == nil {
+		*g = mirrorGroups{}
+	}
+	if (*g)[src] == nil {
+		(*g)[src] = sets.New[string]()
+	}
+	(*g)[src].Insert(dest)
+}
+
+// list returns the mirrors sorted by source.
+func (g mirrorGroups) list() []ImageMirrors {
+	mirrors := make([]ImageMirrors, 0, len(g))
+	for _, src := range slices.Sorted(maps.Keys(g)) {
+		mirrors = append(mirrors, ImageMirrors{Source: src, Mirrors: sets.List(g[src])})
+	}
+	return mirrors
+}
+
+// collapse returns the source repository prefix with `depth` path components and its mirror.
+// The full repositories are returned if the mirror doesn't keep the source path below the prefix.
+func collapse(src, dest string, depth int) (string, string) {
+	domain, path, _ := strings.Cut(src, "/")
+	parts := strings.Split(path, "/")
+	if depth == 0 || depth >= len(parts) {
+		return src, dest
+	}
+	suffix := "/" + strings.Join(parts[depth:], "/")
+	if !strings.HasSuffix(dest, suffix) {
+		return src, dest
+	}
+	return domain + "/" + strings.Join(parts[:depth], "/"), strings.TrimSuffix(dest, suffix)
+}
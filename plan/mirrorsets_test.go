@@ -0,0 +1,105 @@
+package plan
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestMirrorSets(t *testing.T) {
+	keycloak := "registry.redhat.io/rhbk/keycloak-rhel9@sha256:dededededededededededededededededededededededededededededededede"
+	operator := "registry.redhat.io/rhbk/keycloak-rhel9-operator@sha256:dededededededededededededededededededededededededededededededede"
+	p := &Plan{Target: testTarget, Entries: []Entry{
+		{Source: payload4191, Destination: testTarget + "/openshift-release-dev/ocp-release:4.19.1-x86_64", Origin: ReleaseOrigin},
+		{Source: etcdImage, Destination: testTarget + "/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111", Origin: ReleaseOrigin},
+		{Source: keycloak, Destination: testTarget + "/rhbk/keycloak-rhel9@sha256:dededededededededededededededededededededededededededededededede", Origin: OperatorOrigin},
+		{Source: operator, Destination: testTarget + "/rhbk/keycloak-rhel9-operator@sha256:dededededededededededededededededededededededededededededededede", Origin: OperatorOrigin},
+		{Source: "registry.redhat.io/ubi9/ubi:latest", Destination: testTarget + "/ubi9/ubi:latest", Origin: AdditionalOrigin},
+		{Source: "registry.redhat.io/ubi9/ubi-minimal:9.4", Destination: "other.example.com/minimal:9.4", Origin: AdditionalOrigin},
+		{Source: "oci:/tmp/catalogs/index:v1", Destination: testTarget + "/index:v1", Origin: OperatorOrigin},
+	}}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("mirroring full repositories", func(t *testing.T) {
+			ms, err := p.MirrorSets(MirrorSetsOptions{})
+			assert.NilError(t, err)
+			assert.Equal(t, len(ms.IDMS), 2)
+			assert.Equal(t, len(ms.ICSP), 0)
+			assert.Equal(t, ms.IDMS[0].Name, "oc-mirror-release")
+			assert.DeepEqual(t, ms.IDMS[0].Spec.ImageDigestMirrors, []ImageMirrors{
+				{Source: "quay.io/openshift-release-dev/ocp-release", Mirrors: []string{testTarget + "/openshift-release-dev/ocp-release"}},
+				{Source: "quay.io/openshift-release-dev/ocp-v4.0-art-dev", Mirrors: []string{testTarget + "/openshift-release-dev/ocp-v4.0-art-dev"}},
+			})
+			assert.Equal(t, ms.IDMS[1].Name, "oc-mirror")
+			assert.DeepEqual(t, ms.IDMS[1].Spec.ImageDigestMirrors, []ImageMirrors{
+				{Source: "registry.redhat.io/rhbk/keycloak-rhel9", Mirrors: []string{testTarget + "/rhbk/keycloak-rhel9"}},
+				{Source: "registry.redhat.io/rhbk/keycloak-rhel9-operator", Mirrors: []string{testTarget + "/rhbk/keycloak-rhel9-operator"}},
+			})
+			assert.Equal(t, len(ms.ITMS), 1)
+			assert.DeepEqual(t, ms.ITMS[0].Spec.ImageTagMirrors, []ImageMirrors{
+				{Source: "registry.redhat.io/ubi9/ubi", Mirrors: []string{testTarget + "/ubi9/ubi"}},
+				{Source: "registry.redhat.io/ubi9/ubi-minimal", Mirrors: []string{"other.example.com/minimal"}},
+			})
+		})
+
+		t.Run("collapsing repositories and generating ICSPs", func(t *testing.T) {
+			ms, err := p.MirrorSets(MirrorSetsOptions{Name: "mirror", CollapseDepth: 1, ICSP: true})
+			assert.NilError(t, err)
+			// release repositories are never collapsed
+			assert.Equal(t, len(ms.IDMS[0].Spec.ImageDigestMirrors), 2)
+			assert.DeepEqual(t, ms.IDMS[1].Spec.ImageDigestMirrors, []ImageMirrors{
+				{Source: "registry.redhat.io/rhbk", Mirrors: []string{testTarget + "/rhbk"}},
+			})
+			// the mirror of ubi-minimal doesn't keep the source path
+			assert.DeepEqual(t, ms.ITMS[0].Spec.ImageTagMirrors, []ImageMirrors{
+				{Source: "registry.redhat.io/ubi9", Mirrors: []string{testTarget + "/ubi9"}},
+				{Source: "registry.redhat.io/ubi9/ubi-minimal", Mirrors: []string{"other.example.com/minimal"}},
+			})
+			assert.Equal(t, len(ms.ICSP), 2)
+			assert.Equal(t, ms.ICSP[1].Name, "mirror")
+			assert.DeepEqual(t, ms.ICSP[1].Spec.RepositoryDigestMirrors, ms.IDMS[1].Spec.ImageDigestMirrors)
+
+			var buf bytes.Buffer
+			assert.NilError(t, ms.Write(&buf))
+			assert.Equal(t, strings.Count(buf.String(), "kind: ImageDigestMirrorSet"), 2)
+			assert.Equal(t, strings.Count(buf.String(), "kind: ImageTagMirrorSet"), 1)
+			assert.Equal(t, strings.Count(buf.String(), "kind: ImageContentSourcePolicy"), 2)
+			assert.Assert(t, strings.Contains(buf.String(), "apiVersion: operator.openshift.io/v1alpha1"))
+		})
+
+		t.Run("release images are mirrored by tag", func(t *testing.T) {
+			byTag := &Plan{Target: testTarget, Entries: []Entry{
+				{Source: payload4191, Destination: testTarget + "/openshift-release-dev/ocp-release:4.19.1-x86_64", Origin: ReleaseOrigin},
+				{Source: "quay.io/openshift-release-dev/ocp-release:4.19.2-x86_64", Destination: testTarget + "/openshift-release-dev/ocp-release:4.19.2-x86_64", Origin: ReleaseOrigin},
+				{Source: "quay.io/openshift/okd:4.19.0-okd-scos.1", Destination: testTarget + "/openshift/okd:4.19.0-okd-scos.1", Origin: AdditionalOrigin},
+			}}
+			ms, err := byTag.MirrorSets(MirrorSetsOptions{CollapseDepth: 1})
+			assert.NilError(t, err)
+			assert.Equal(t, len(ms.IDMS), 1)
+			assert.Equal(t, ms.IDMS[0].Name, "oc-mirror-release")
+			assert.Equal(t, len(ms.ITMS), 1)
+			assert.Equal(t, ms.ITMS[0].Name, "oc-mirror-release")
+			assert.DeepEqual(t, ms.ITMS[0].Spec.ImageTagMirrors, []ImageMirrors{
+				{Source: "quay.io/openshift-release-dev/ocp-release", Mirrors: []string{testTarget + "/openshift-release-dev/ocp-release"}},
+				{Source: "quay.io/openshift/okd", Mirrors: []string{testTarget + "/openshift/okd"}},
+			})
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the collapse depth is negative", func(t *testing.T) {
+			_, err := p.MirrorSets(MirrorSetsOptions{CollapseDepth: -1})
+			assert.ErrorIs(t, err, libErrs.ErrBuildPlan)
+		})
+
+		t.Run("a destination is invalid", func(t *testing.T) {
+			invalid := &Plan{Entries: []Entry{{Source: "registry.redhat.io/ubi9/ubi:latest", Destination: "INVALID"}}}
+			_, err := invalid.MirrorSets(MirrorSetsOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrInvalidRef)
+		})
+	})
+}
@@ -0,0 +1,124 @@
+// Package plan computes the images to mirror for an image set configuration.
+package plan
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+)
+
+var logger = slog.Default().WithGroup("plan")
+
+// Origin is the part of the image set configuration an image comes from.
+type Origin string
+
+const (
+	ReleaseOrigin    Origin = "release"
+	OperatorOrigin   Origin = "operator"
+	AdditionalOrigin Origin = "additional"
+)
+
+// ImageType is the role of an image in its origin.
+type ImageType string
+
+const (
+	ReleasePayloadImage   ImageType = "release-payload"
+	ReleaseComponentImage ImageType = "release-component"
+	CatalogImage          ImageType = "catalog"
+	BundleImage           ImageType = "bundle"
+	RelatedImage          ImageType = "related"
+	AdditionalImage       ImageType = "additional"
+)
+
+// Entry is an image to mirror.
+type Entry struct {
+	// Source is the fully qualified source reference, or `oci:path[:tag]` for local OCI layouts.
+	Source string `json:"source"`
+	// Destination is the reference in the target registry.
+	Destination string `json:"destination"`
+	// Digest is the source manifest digest, when known. Sources by tag are resolved when mirrored.
+	Digest digest.Digest `json:"digest,omitempty"`
+	Origin Origin        `json:"origin"`
+	Type   ImageType     `json:"type"`
+	// RequiredBy lists what selected the image, such as a release version, component or bundle, sorted.
+	RequiredBy []string `json:"requiredBy,omitempty"`
+}
+
+// Plan is the deduplicated list of images to mirror to a target registry, sorted by source.
+type Plan struct {
+	Target  string  `json:"target"`
+	Entries []Entry `json:"entries"`
+}
+
+// Filter returns the entries for which `keep` returns true.
+func (p *Plan) Filter(keep func(Entry) bool) []Entry {
+	entries := []Entry{}
+	for _, e := range p.Entries {
+		if keep(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ByOrigin returns the entries from the given origin.
+func (p *Plan) ByOrigin(origin Origin) []Entry {
+	return p.Filter(func(e Entry) bool { return e.Origin == origin })
+}
+
+// Diff is the difference between two plans.
+type Diff struct {
+	// Added are the entries only in the new plan.
+	Added []Entry `json:"added,omitempty"`
+	// Removed are the entries only in the old plan.
+	Removed []Entry `json:"removed,omitempty"`
+}
+
+// Empty returns whether both plans mirror the same images.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the entries added and removed from `old` to `p`.
+// Entries are compared by source, digest and destination; an image whose digest changed
+// is both removed and added.
+func (p *Plan) Diff(old *Plan) Diff {
+	var diff Diff
+	oldKeys := map[string]bool{}
+	for _, e := range old.Entries {
+		oldKeys[e.key()] = true
+	}
+	newKeys := map[string]bool{}
+	for _, e := range p.Entries {
+		newKeys[e.key()] = true
+		if !oldKeys[e.key()] {
+			diff.Added = append(diff.Added, e)
+		}
+	}
+	for _, e := range old.Entries {
+		if !newKeys[e.key()] {
+			diff.Removed = append(diff.Removed, e)
+		}
+	}
+	return diff
+}
+
+func (e Entry) key() string {
+	return strings.Join([]string{e.Source, e.Digest.String(), e.Destination}, " ")
+}
+
+func compareEntries(a, b Entry) int {
+	return cmp.Or(strings.Compare(a.Source, b.Source), strings.Compare(a.Destination, b.Destination))
+}
+
+// sortEntries sorts the entries by source and their requirements by name.
+func sortEntries(entries []Entry) {
+	for i := range entries {
+		slices.Sort(entries[i].RequiredBy)
+		entries[i].RequiredBy = slices.Compact(entries[i].RequiredBy)
+	}
+	slices.SortFunc(entries, compareEntries)
+}
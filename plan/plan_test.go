@@ -0,0 +1,24 @@
+package plan
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPlanDiff(t *testing.T) {
+	ubi := Entry{Source: "registry.redhat.io/ubi9/ubi:latest", Destination: "mirror.local/ubi9/ubi:latest", Origin: AdditionalOrigin}
+	etcd := Entry{Source: "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111", Destination: "mirror.local/ocp@sha256:1111", Digest: "sha256:1111", Origin: ReleaseOrigin}
+	catalogV1 := Entry{Source: "registry.redhat.io/redhat/redhat-operator-index:v4.19", Digest: "sha256:aaaa", Origin: OperatorOrigin}
+	catalogV2 := catalogV1
+	catalogV2.Digest = "sha256:bbbb"
+
+	old := &Plan{Entries: []Entry{catalogV1, ubi}}
+	cur := &Plan{Entries: []Entry{etcd, catalogV2, ubi}}
+
+	diff := cur.Diff(old)
+	assert.DeepEqual(t, diff.Added, []Entry{etcd, catalogV2})
+	assert.DeepEqual(t, diff.Removed, []Entry{catalogV1})
+	assert.Assert(t, !diff.Empty())
+	assert.Assert(t, cur.Diff(cur).Empty())
+}
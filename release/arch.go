@@ -0,0 +1,76 @@
+package release
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// GetArchitectures returns the architectures with graph data in the client.
+func (c *ReleaseClient) GetArchitectures() []Architecture {
+	archs := []Architecture{}
+	for _, gdata := range c.data {
+		if !slices.Contains(archs, gdata.arch) {
+			archs = append(archs, gdata.arch)
+		}
+	}
+	slices.Sort(archs)
+	return archs
+}
+
+// ForArch returns a client restricted to the graph data of the `arch` architecture.
+// All the queries in the returned client only consider releases of that architecture.
+func (c *ReleaseClient) ForArch(arch Architecture) (*ReleaseClient, error) {
+	if view, ok := c.archViews[arch]; ok {
+		return view, nil
+	}
+	// Single architecture client
+	if len(c.data) > 0 && c.archViews == nil && c.data[0].arch == arch {
+		return c, nil
+	}
+	return nil, libErrs.NewReleaseErr(fmt.Errorf("architecture %q %w", arch, libErrs.ErrNotFound))
+}
+
+// GetPayloads returns the payload of the given version for each architecture it exists on.
+func (c *ReleaseClient) GetPayloads(ver *semver.Version) (map[Architecture]string, error) {
+	payloads := map[Architecture]string{}
+	for _, gdata := range c.data {
+		if _, ok := payloads[gdata.arch]; ok {
+			continue
+		}
+		if idx, err := gdata.findNodeIndex(ver.String()); err == nil {
+			payloads[gdata.arch] = gdata.Nodes[idx].Payload
+		}
+	}
+	if len(payloads) == 0 {
+		return nil, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+	}
+	return payloads, nil
+}
+
+// GetCommonReleases returns the release versions that exist on every one of the `archs`
+// architectures. If no architecture is given, all the architectures in the client are used.
+func (c *ReleaseClient) GetCommonReleases(archs ...Architecture) ([]*semver.Version, error) {
+	if len(archs) == 0 {
+		archs = c.GetArchitectures()
+	}
+	var shared sets.Set[string]
+	for _, arch := range archs {
+		view, err := c.ForArch(arch)
+		if err != nil {
+			return nil, err
+		}
+		vers := sets.New(slices.Collect(maps.Keys(view.versions))...)
+		if shared == nil {
+			shared = vers
+		} else {
+			shared = shared.Intersection(vers)
+		}
+	}
+	return c.toVersions(shared.UnsortedList()), nil
+}
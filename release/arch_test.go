@@ -0,0 +1,109 @@
+package release
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestMultiArch(t *testing.T) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(t, err)
+
+	// NOTE: there is no arm64 test data, so use a different graph to tell architectures apart.
+	client, err := NewMultiArchReleaseClient(
+		ArchGraphData{Arch: AMD64, Data: data},
+		ArchGraphData{Arch: ARM64, Data: data2},
+	)
+	assert.NilError(t, err)
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("getting architectures", func(t *testing.T) {
+			assert.DeepEqual(t, client.GetArchitectures(), []Architecture{AMD64, ARM64})
+		})
+
+		t.Run("getting releases for an architecture", func(t *testing.T) {
+			arm, err := client.ForArch(ARM64)
+			assert.NilError(t, err)
+			rels, err := arm.GetReleases()
+			assert.NilError(t, err)
+			assert.Equal(t, len(rels), 49, "unexpected number of nodes")
+			payload, err := arm.GetPayload(semver.MustParse("4.20.0"))
+			assert.NilError(t, err)
+			assert.Assert(t, payload != "")
+		})
+
+		t.Run("getting payloads per architecture", func(t *testing.T) {
+			payloads, err := client.GetPayloads(semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.Equal(t, len(payloads), 2, "unexpected number of payloads")
+			payloads, err = client.GetPayloads(semver.MustParse("4.20.0"))
+			assert.NilError(t, err)
+			assert.Equal(t, len(payloads), 1, "unexpected number of payloads")
+			_, ok := payloads[ARM64]
+			assert.Assert(t, ok)
+		})
+
+		t.Run("getting common releases", func(t *testing.T) {
+			rels, err := client.GetCommonReleases(AMD64, ARM64)
+			assert.NilError(t, err)
+			assert.Equal(t, len(rels), 43, "unexpected number of releases")
+			rels, err = client.GetCommonReleases()
+			assert.NilError(t, err)
+			assert.Equal(t, len(rels), 43, "unexpected number of releases")
+			rels, err = client.GetCommonReleases(ARM64)
+			assert.NilError(t, err)
+			assert.Equal(t, len(rels), 49, "unexpected number of releases")
+		})
+
+		t.Run("getting mirror set for all architectures", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.19.0"), To: semver.MustParse("4.19.1")}}
+			payloads, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: FullRangeMode})
+			assert.NilError(t, err)
+			assert.Equal(t, len(payloads), 4, "unexpected number of payloads")
+			assert.Equal(t, payloads[0].Arch, AMD64)
+			assert.Equal(t, payloads[1].Arch, ARM64)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("architecture is not found", func(t *testing.T) {
+			_, err := client.ForArch(S390X)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			_, err = client.GetCommonReleases(AMD64, S390X)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("release is found in several architectures", func(t *testing.T) {
+			_, err := client.GetPayload(semver.MustParse("4.19.1"))
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+			_, err = client.GetMetadata(semver.MustParse("4.19.1"))
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+		})
+
+		t.Run("querying updates across architectures", func(t *testing.T) {
+			from, to := semver.MustParse("4.19.0"), semver.MustParse("4.19.1")
+			_, err := client.GetUpdatesFrom(from)
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+			_, err = client.GetUpdatePath(from, to)
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+			_, err = client.GetUpdatePaths(from, to, PathOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+			_, err = client.GetUpgradeMatrix()
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+			_, err = client.GetGraph(GraphOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrAmbiguousArch)
+
+			amd, err := client.ForArch(AMD64)
+			assert.NilError(t, err)
+			_, err = amd.GetUpdatePath(from, to)
+			assert.NilError(t, err)
+		})
+	})
+}
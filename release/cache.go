@@ -0,0 +1,174 @@
+package release
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// CacheOptions is used to configure the graph data cache.
+type CacheOptions struct {
+	// Dir is the directory where graph data is cached.
+	Dir string
+	// TTL is how long cached data is used without revalidating it. Zero means always revalidate.
+	TTL time.Duration
+	// Offline serves cached data without contacting the endpoint.
+	Offline bool
+}
+
+// CachedGraphData contains the graph data and its cache status.
+type CachedGraphData struct {
+	Data []byte
+	// FetchedAt is the last time the data was downloaded or revalidated.
+	FetchedAt time.Time
+	// Age is the time elapsed since `FetchedAt`.
+	Age time.Duration
+	// FromCache is set when the data was served from the cache without a download.
+	FromCache bool
+}
+
+// cacheEntry is the metadata stored next to the cached graph data.
+type cacheEntry struct {
+	Endpoint     string       `json:"endpoint"`
+	Channel      string       `json:"channel"`
+	Arch         Architecture `json:"arch"`
+	ETag         string       `json:"etag,omitempty"`
+	LastModified string       `json:"lastModified,omitempty"`
+	FetchedAt    time.Time    `json:"fetchedAt"`
+}
+
+// DownloadGraphDataCached gets the graph data from the specified Cincinnati endpoint, using an on-disk cache.
+// Cached data older than the TTL is revalidated with the endpoint using ETag and Last-Modified headers.
+// Downloaded data is validated before it is cached.
+func DownloadGraphDataCached(ctx context.Context, options DownloadOptions, cache CacheOptions) (*CachedGraphData, error) {
+	dataPath, entryPath := cachePaths(cache.Dir, options)
+	lg := logger.With(slog.String("channel", options.Channel), slog.String("arch", string(options.Arch)))
+
+	entry, data, err := readCacheEntry(dataPath, entryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry != nil {
+		age := time.Since(entry.FetchedAt)
+		if cache.Offline || (cache.TTL > 0 && age < cache.TTL) {
+			lg.Debug("serving cached graph data", slog.Duration("age", age))
+			return &CachedGraphData{Data: data, FetchedAt: entry.FetchedAt, Age: age, FromCache: true}, nil
+		}
+	} else if cache.Offline {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("cached graph data %w", libErrs.ErrNotFound))
+	}
+
+	req, err := newGraphDataRequest(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := httpClient(options).Do(req)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	now := time.Now()
+	switch status := resp.StatusCode; {
+	case status == http.StatusNotModified && entry != nil:
+		lg.Debug("cached graph data not modified")
+		entry.FetchedAt = now
+		if err := writeCacheEntry(entryPath, entry); err != nil {
+			return nil, err
+		}
+		return &CachedGraphData{Data: data, FetchedAt: now, FromCache: true}, nil
+	case status != http.StatusOK:
+		return nil, libErrs.NewReleaseErr(&libErrs.HTTPStatusError{StatusCode: status, URL: req.URL.Redacted()})
+	}
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	// Only cache graph data that can be used, so that offline mode never serves a broken download.
+	if err := ValidateGraphData(data); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cache.Dir, 0o755); err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	if err := common.WriteFileAtomic(dataPath, data); err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	entry = &cacheEntry{
+		Endpoint:     options.Endpoint,
+		Channel:      options.Channel,
+		Arch:         options.Arch,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    now,
+	}
+	if err := writeCacheEntry(entryPath, entry); err != nil {
+		return nil, err
+	}
+	return &CachedGraphData{Data: data, FetchedAt: now}, nil
+}
+
+// cachePaths returns the paths of the cached data and its metadata, keyed by endpoint, channel and arch.
+func cachePaths(dir string, options DownloadOptions) (string, string) {
+	sum := sha256.Sum256([]byte(options.Endpoint + "\x00" + options.Channel + "\x00" + string(options.Arch)))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(dir, key+".json"), filepath.Join(dir, key+".meta.json")
+}
+
+// readCacheEntry returns the cached metadata and data, or nil if nothing is cached.
+func readCacheEntry(dataPath, entryPath string) (*cacheEntry, []byte, error) {
+	raw, err := os.ReadFile(entryPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, libErrs.NewReleaseErr(err)
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		logger.Warn("ignoring invalid cache entry", slog.String("path", entryPath), slog.Any("error", err))
+		return nil, nil, nil
+	}
+	data, err := os.ReadFile(dataPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, libErrs.NewReleaseErr(err)
+	}
+	return &entry, data, nil
+}
+
+func writeCacheEntry(path string, entry *cacheEntry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	if err := common.WriteFileAtomic(path, raw); err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const testETag = `"graph-v1"`
+
+func newGraphServer(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == testETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", testETag)
+		_, _ = w.Write(data)
+	}))
+}
+
+func TestDownloadGraphDataCached(t *testing.T) {
+	var requests atomic.Int32
+	server := newGraphServer(t, &requests)
+	defer server.Close()
+
+	options := DownloadOptions{Endpoint: server.URL, Channel: "stable-4.19", Arch: AMD64}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("downloading and revalidating graph data", func(t *testing.T) {
+			requests.Store(0)
+			cache := CacheOptions{Dir: t.TempDir()}
+			res, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			assert.Assert(t, !res.FromCache)
+			_, err = NewReleaseClient(res.Data)
+			assert.NilError(t, err)
+
+			res2, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			assert.Assert(t, res2.FromCache)
+			assert.DeepEqual(t, res2.Data, res.Data)
+			assert.Equal(t, requests.Load(), int32(2), "expected a revalidation request")
+		})
+
+		t.Run("cached data is within the TTL", func(t *testing.T) {
+			requests.Store(0)
+			cache := CacheOptions{Dir: t.TempDir(), TTL: time.Hour}
+			_, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			res, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			assert.Assert(t, res.FromCache)
+			assert.Equal(t, requests.Load(), int32(1), "unexpected number of requests")
+		})
+
+		t.Run("serving cached data offline", func(t *testing.T) {
+			requests.Store(0)
+			cache := CacheOptions{Dir: t.TempDir()}
+			_, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			cache.Offline = true
+			res, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			assert.Assert(t, res.FromCache)
+			assert.Assert(t, res.Age > 0)
+			assert.Equal(t, requests.Load(), int32(1), "unexpected number of requests")
+		})
+
+		t.Run("caching channels separately", func(t *testing.T) {
+			requests.Store(0)
+			cache := CacheOptions{Dir: t.TempDir(), TTL: time.Hour}
+			_, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.NilError(t, err)
+			other := options
+			other.Channel = "stable-4.20"
+			res, err := DownloadGraphDataCached(context.Background(), other, cache)
+			assert.NilError(t, err)
+			assert.Assert(t, !res.FromCache)
+			assert.Equal(t, requests.Load(), int32(2), "unexpected number of requests")
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("offline without cached data", func(t *testing.T) {
+			cache := CacheOptions{Dir: t.TempDir(), Offline: true}
+			_, err := DownloadGraphDataCached(context.Background(), options, cache)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("the endpoint returns an error status", func(t *testing.T) {
+			failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer failing.Close()
+			failOptions := options
+			failOptions.Endpoint = failing.URL
+
+			_, err := DownloadGraphData(context.Background(), failOptions)
+			var statusErr *libErrs.HTTPStatusError
+			assert.Assert(t, errors.As(err, &statusErr))
+			assert.Equal(t, statusErr.StatusCode, http.StatusServiceUnavailable)
+			assert.Assert(t, strings.HasPrefix(statusErr.URL, failing.URL))
+			assert.Assert(t, statusErr.Temporary())
+
+			_, err = DownloadGraphDataCached(context.Background(), failOptions, CacheOptions{Dir: t.TempDir()})
+			assert.Assert(t, errors.As(err, &statusErr))
+			assert.Equal(t, statusErr.StatusCode, http.StatusServiceUnavailable)
+		})
+
+		t.Run("the endpoint returns invalid graph data", func(t *testing.T) {
+			truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"nodes": [{"version": "4.19.1"`))
+			}))
+			defer truncated.Close()
+			badOptions := options
+			badOptions.Endpoint = truncated.URL
+
+			cache := CacheOptions{Dir: t.TempDir()}
+			_, err := DownloadGraphDataCached(context.Background(), badOptions, cache)
+			assert.ErrorIs(t, err, libErrs.ErrParseGraphData)
+			cache.Offline = true
+			_, err = DownloadGraphDataCached(context.Background(), badOptions, cache)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+	})
+}
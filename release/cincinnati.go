@@ -2,7 +2,6 @@ package release
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -36,6 +35,28 @@ type DownloadOptions struct {
 
 // DownloadGraphData gets the graph data from the specified Cincinnati endpoint.
 func DownloadGraphData(ctx context.Context, options DownloadOptions) ([]byte, error) {
+	req, err := newGraphDataRequest(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient(options).Do(req)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if status := resp.StatusCode; status != http.StatusOK {
+		return nil, libErrs.NewReleaseErr(&libErrs.HTTPStatusError{StatusCode: status, URL: req.URL.Redacted()})
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	return data, nil
+}
+
+func newGraphDataRequest(ctx context.Context, options DownloadOptions) (*http.Request, error) {
 	parsed, err := url.Parse(options.Endpoint)
 	if err != nil {
 		return nil, libErrs.NewReleaseErr(err)
@@ -51,25 +72,13 @@ func DownloadGraphData(ctx context.Context, options DownloadOptions) ([]byte, er
 		return nil, libErrs.NewReleaseErr(err)
 	}
 	req.Header.Add("Accept", "application/json")
+	return req, nil
+}
 
-	client := options.Client
-	if client == nil {
+func httpClient(options DownloadOptions) *http.Client {
+	if options.Client == nil {
 		logger.Debug("initializing default http client")
-		client = &http.Client{}
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, libErrs.NewReleaseErr(err)
+		return &http.Client{}
 	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if status := resp.StatusCode; status != http.StatusOK {
-		return nil, libErrs.NewReleaseErr(fmt.Errorf("unexpected http status %d", status))
-	}
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, libErrs.NewReleaseErr(err)
-	}
-	return data, nil
+	return options.Client
 }
@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"slices"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/RyanCarrier/dijkstra/v2"
+	"github.com/opencontainers/go-digest"
 	"github.com/r4f4/oc-mirror-libs/common"
 	libErrs "github.com/r4f4/oc-mirror-libs/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -17,55 +19,231 @@ var logger = slog.Default().WithGroup("release")
 
 var _ ReleaseIntrospector = (*ReleaseClient)(nil)
 
+// ReleaseClient is safe for concurrent use: the graph datas, version indexes and update graphs
+// are built on creation and never modified afterwards.
 type ReleaseClient struct {
-	data []*graphData
+	data []*GraphData
+
+	versions map[string]*semver.Version
+	releases []*semver.Version
+	// graph and graphWithRisks are only built for single architecture clients.
+	graph          *dijkstra.MappedGraph[string]
+	graphWithRisks *dijkstra.MappedGraph[string]
+	// archViews holds a client per architecture, only set when there are several architectures.
+	archViews map[Architecture]*ReleaseClient
+}
+
+// ArchGraphData is the graph data for a single architecture.
+type ArchGraphData struct {
+	Arch Architecture
+	Data []byte
 }
 
+// NewReleaseClient creates a client from graph datas of the AMD64 architecture, which is the
+// Cincinnati default when no architecture is requested.
 func NewReleaseClient(datas ...[]byte) (*ReleaseClient, error) {
-	gdatas := make([]*graphData, len(datas))
+	return NewMultiArchReleaseClient(common.Map(datas, func(d []byte) ArchGraphData {
+		return ArchGraphData{Arch: AMD64, Data: d}
+	})...)
+}
+
+// NewMultiArchReleaseClient creates a client from graph datas of possibly different architectures.
+// Releases are only merged between graph datas of the same architecture, see `ForArch`.
+// Update graph queries of a client with several architectures fail with `ErrAmbiguousArch`,
+// since the updates of one architecture don't apply to another: query the client of each architecture instead.
+func NewMultiArchReleaseClient(datas ...ArchGraphData) (*ReleaseClient, error) {
+	gdatas := make([]*GraphData, len(datas))
 	for i, data := range datas {
-		gdata, err := parseGraphData(data)
+		gdata, err := parseGraphData(data.Data)
 		if err != nil {
 			return nil, err
 		}
+		gdata.arch = data.Arch
 		gdatas[i] = gdata
 	}
-	return &ReleaseClient{gdatas}, nil
+	return newReleaseClient(gdatas)
+}
+
+func newReleaseClient(gdatas []*GraphData) (*ReleaseClient, error) {
+	c := &ReleaseClient{data: gdatas, versions: map[string]*semver.Version{}}
+	archs := sets.New[Architecture]()
+	for _, gdata := range gdatas {
+		archs.Insert(gdata.arch)
+		for _, n := range gdata.Nodes {
+			if _, ok := c.versions[n.Version]; ok {
+				continue
+			}
+			ver, err := semver.StrictNewVersion(n.Version)
+			if err != nil {
+				return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %w", libErrs.ErrParseGraphData, err))
+			}
+			c.versions[n.Version] = ver
+		}
+	}
+	c.releases = slices.SortedFunc(maps.Values(c.versions), (*semver.Version).Compare)
+
+	var err error
+	if archs.Len() > 1 {
+		c.archViews = make(map[Architecture]*ReleaseClient, archs.Len())
+		for arch := range archs {
+			archData := slices.DeleteFunc(slices.Clone(gdatas), func(g *GraphData) bool { return g.arch != arch })
+			if c.archViews[arch], err = newReleaseClient(archData); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	}
+
+	if c.graph, err = c.buildGraph(); err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	if c.graphWithRisks, err = c.buildGraphWithRisks(); err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	return c, nil
+}
+
+// singleArch fails for clients with several architectures, whose update graphs must not be merged.
+func (c *ReleaseClient) singleArch() error {
+	if c.archViews == nil {
+		return nil
+	}
+	return libErrs.NewReleaseErr(fmt.Errorf("%w: client has architectures %v, use ForArch to query updates",
+		libErrs.ErrAmbiguousArch, c.GetArchitectures()))
+}
+
+// toVersions returns the sorted versions for the given version strings.
+func (c *ReleaseClient) toVersions(vers []string) []*semver.Version {
+	rels := common.Map(vers, func(v string) *semver.Version { return c.versions[v] })
+	slices.SortFunc(rels, (*semver.Version).Compare)
+	return rels
 }
 
 // GetReleases returns all the release versions in a channel.
 func (c *ReleaseClient) GetReleases() ([]*semver.Version, error) {
-	nodes := sets.New[string]()
+	return slices.Clone(c.releases), nil
+}
+
+// GetGraphData returns a copy of the graph datas in the client.
+func (c *ReleaseClient) GetGraphData() []*GraphData {
+	return common.Map(c.data, func(g *GraphData) *GraphData {
+		return g.Filter(func(Node) bool { return true })
+	})
+}
+
+// findNode returns the node for the given version.
+// It fails if the version is found in more than one architecture, since nodes would differ.
+func (c *ReleaseClient) findNode(ver *semver.Version) (*Node, error) {
+	var found *Node
+	var arch Architecture
 	for _, gdata := range c.data {
-		nodes.Insert(common.Map(gdata.Nodes, func(n node) string { return n.Version })...)
+		idx, err := gdata.findNodeIndex(ver.String())
+		if err != nil {
+			continue
+		}
+		if found != nil && gdata.arch != arch {
+			return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %q found in %s and %s, use ForArch or GetPayloads",
+				libErrs.ErrAmbiguousArch, ver.String(), arch, gdata.arch))
+		}
+		if found == nil {
+			found, arch = &gdata.Nodes[idx], gdata.arch
+		}
 	}
-	rels := common.Map(nodes.UnsortedList(), semver.MustParse)
-	slices.SortFunc(rels, (*semver.Version).Compare)
-	return rels, nil
+	if found == nil {
+		return nil, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+	}
+	return found, nil
 }
 
 // GetPayload returns the payload for the given version.
 func (c *ReleaseClient) GetPayload(ver *semver.Version) (string, error) {
-	for _, gdata := range c.data {
-		if idx, err := gdata.findNodeIndex(ver.String()); err == nil {
-			return gdata.Nodes[idx].Payload, nil
-		}
+	n, err := c.findNode(ver)
+	if err != nil {
+		return "", err
 	}
-	return "", libErrs.NewReleaseErr(fmt.Errorf("%q %w", ver.String(), libErrs.ErrNotFound))
+	return n.Payload, nil
 }
 
 // GetMetadata returns release metadata for the given version.
 func (c *ReleaseClient) GetMetadata(ver *semver.Version) (Metadata, error) {
+	n, err := c.findNode(ver)
+	if err != nil {
+		return nil, err
+	}
+	return n.Metadata, nil
+}
+
+// GetReleaseMetadata returns a typed view of the release metadata for the given version.
+// Channels are merged from all the graph datas the release is found in.
+func (c *ReleaseClient) GetReleaseMetadata(ver *semver.Version) (*ReleaseMetadata, error) {
+	md, err := c.GetMetadata(ver)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := c.GetChannels(ver)
+	if err != nil {
+		return nil, err
+	}
+	return &ReleaseMetadata{
+		URL:         md[URLMetadataKey],
+		ManifestRef: digest.Digest(md[ManifestRefMetadataKey]),
+		Channels:    channels,
+		Raw:         md,
+	}, nil
+}
+
+// GetChannels returns the channels the given version belongs to, sorted by name.
+func (c *ReleaseClient) GetChannels(ver *semver.Version) ([]string, error) {
+	channels := sets.New[string]()
+	found := false
 	for _, gdata := range c.data {
 		if idx, err := gdata.findNodeIndex(ver.String()); err == nil {
-			return gdata.Nodes[idx].Metadata, nil
+			found = true
+			channels.Insert(gdata.Nodes[idx].channels()...)
+		}
+	}
+	if !found {
+		return nil, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+	}
+	return sets.List(channels), nil
+}
+
+// GetReleasesInChannel returns all the release versions that belong to `channel`.
+func (c *ReleaseClient) GetReleasesInChannel(channel string) ([]*semver.Version, error) {
+	nodes := sets.New[string]()
+	for _, gdata := range c.data {
+		for _, n := range gdata.Nodes {
+			if slices.Contains(n.channels(), channel) {
+				nodes.Insert(n.Version)
+			}
 		}
 	}
-	return nil, libErrs.NewReleaseErr(fmt.Errorf("%q %w", ver.String(), libErrs.ErrNotFound))
+	if nodes.Len() == 0 {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("channel %q %w", channel, libErrs.ErrNotFound))
+	}
+	return c.toVersions(nodes.UnsortedList()), nil
+}
+
+// IsEUS returns whether the given version is part of the EUS channel of its own minor version.
+// Releases from other minors can show up in an EUS channel as update sources, but are not EUS releases.
+func (c *ReleaseClient) IsEUS(ver *semver.Version) (bool, error) {
+	channels, err := c.GetChannels(ver)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(channels, eusChannel(ver)), nil
+}
+
+// eusChannel returns the EUS channel name for the minor version of `ver`.
+func eusChannel(ver *semver.Version) string {
+	return fmt.Sprintf("%s%d.%d", EUSChannelPrefix, ver.Major(), ver.Minor())
 }
 
 // GetUpdatesFrom returns the direct updates `from` version.
 func (c *ReleaseClient) GetUpdatesFrom(from *semver.Version) ([]*semver.Version, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
 	nodes := sets.New[string]()
 	errs := make([]error, 0, len(c.data))
 	for _, gdata := range c.data {
@@ -80,13 +258,14 @@ func (c *ReleaseClient) GetUpdatesFrom(from *semver.Version) ([]*semver.Version,
 	if len(errs) == len(c.data) {
 		return nil, errs[0]
 	}
-	semvers := common.Map(nodes.UnsortedList(), semver.MustParse)
-	slices.SortFunc(semvers, (*semver.Version).Compare)
-	return semvers, nil
+	return c.toVersions(nodes.UnsortedList()), nil
 }
 
 // GetUpdatesTo returns the direct updates `to` version.
 func (c *ReleaseClient) GetUpdatesTo(to *semver.Version) ([]*semver.Version, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
 	nodes := sets.New[string]()
 	errs := make([]error, 0, len(c.data))
 	for _, gdata := range c.data {
@@ -101,12 +280,10 @@ func (c *ReleaseClient) GetUpdatesTo(to *semver.Version) ([]*semver.Version, err
 	if len(errs) == len(c.data) {
 		return nil, errs[0]
 	}
-	semvers := common.Map(nodes.UnsortedList(), semver.MustParse)
-	slices.SortFunc(semvers, (*semver.Version).Compare)
-	return semvers, nil
+	return c.toVersions(nodes.UnsortedList()), nil
 }
 
-func updateGraphFromData(graph *dijkstra.MappedGraph[string], data *graphData) error {
+func updateGraphFromData(graph *dijkstra.MappedGraph[string], data *GraphData) error {
 	for _, node := range data.Nodes {
 		if err := graph.AddEmptyVertex(node.Version); err != nil {
 			logger.Debug("node already in graph", slog.String("node", node.Version))
@@ -116,8 +293,9 @@ func updateGraphFromData(graph *dijkstra.MappedGraph[string], data *graphData) e
 		}
 	}
 	for _, edge := range data.Edges {
-		if err := graph.AddArc(data.Nodes[edge[0]].Version, data.Nodes[edge[1]].Version, 1); err != nil {
-			return err
+		from, to := data.Nodes[edge[0]].Version, data.Nodes[edge[1]].Version
+		if err := graph.AddArc(from, to, 1); err != nil {
+			return &libErrs.GraphInconsistencyError{From: from, To: to, Err: err}
 		}
 	}
 	return nil
@@ -135,18 +313,26 @@ func (c *ReleaseClient) buildGraph() (*dijkstra.MappedGraph[string], error) {
 
 // GetUpdatePath returns the update path between two releases in the same channel.
 func (c *ReleaseClient) GetUpdatePath(from *semver.Version, to *semver.Version) ([]*semver.Version, error) {
-	graph, err := c.buildGraph()
-	if err != nil {
-		return nil, libErrs.NewReleaseErr(err)
+	return c.shortestPath(c.graph, from, to)
+}
+
+func (c *ReleaseClient) shortestPath(graph *dijkstra.MappedGraph[string], from *semver.Version, to *semver.Version) ([]*semver.Version, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
+	for _, ver := range []*semver.Version{from, to} {
+		if _, ok := c.versions[ver.String()]; !ok {
+			return nil, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+		}
 	}
 	path, err := graph.Shortest(from.String(), to.String())
 	if err != nil {
 		if errors.Is(err, dijkstra.ErrNoPath) {
 			return nil, libErrs.NewReleaseErr(libErrs.ErrUpdateNotFound)
 		}
-		return nil, libErrs.NewReleaseErr(err)
+		return nil, libErrs.NewReleaseErr(&libErrs.GraphInconsistencyError{From: from.String(), To: to.String(), Err: err})
 	}
-	return common.Map(path.Path, semver.MustParse), nil
+	return common.Map(path.Path, func(v string) *semver.Version { return c.versions[v] }), nil
 }
 
 func (c *ReleaseClient) buildGraphWithRisks() (*dijkstra.MappedGraph[string], error) {
@@ -158,7 +344,7 @@ func (c *ReleaseClient) buildGraphWithRisks() (*dijkstra.MappedGraph[string], er
 		for _, ce := range gdata.CondEdges {
 			for _, e := range ce.Edges {
 				if err := graph.AddArc(e.From, e.To, 1); err != nil {
-					return nil, err
+					return nil, &libErrs.GraphInconsistencyError{From: e.From, To: e.To, Err: err}
 				}
 			}
 		}
@@ -169,22 +355,14 @@ func (c *ReleaseClient) buildGraphWithRisks() (*dijkstra.MappedGraph[string], er
 // GetUpdatePathWithRisks returns an update path between release while also using conditional edges.
 // Note that conditional edges are given higher wait, so that updates without risks are preferred.
 func (c *ReleaseClient) GetUpdatePathWithRisks(from *semver.Version, to *semver.Version) ([]*semver.Version, error) {
-	graph, err := c.buildGraphWithRisks()
-	if err != nil {
-		return nil, libErrs.NewReleaseErr(err)
-	}
-	path, err := graph.Shortest(from.String(), to.String())
-	if err != nil {
-		if errors.Is(err, dijkstra.ErrNoPath) {
-			return nil, libErrs.NewReleaseErr(libErrs.ErrUpdateNotFound)
-		}
-		return nil, libErrs.NewReleaseErr(err)
-	}
-	return common.Map(path.Path, semver.MustParse), nil
+	return c.shortestPath(c.graphWithRisks, from, to)
 }
 
 // GetRisks gets the risks of updating between `from` and `to` releases.
 func (c *ReleaseClient) GetRisks(from *semver.Version, to *semver.Version) ([]Risk, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
 	allRisks := []Risk{}
 	allErrs := make([]error, 0, len(c.data))
 	for _, gdata := range c.data {
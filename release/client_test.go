@@ -1,16 +1,21 @@
 package release
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/Masterminds/semver/v3"
-	"github.com/RyanCarrier/dijkstra/v2"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
 
+	"github.com/r4f4/oc-mirror-libs/common"
 	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/render"
 )
 
 const (
@@ -83,6 +88,85 @@ func TestReleaseClient(t *testing.T) {
 			assert.Assert(t, equalVersions(rels, []string{"4.19.13", "4.19.14", "4.19.15", "4.19.16"}))
 		})
 
+		t.Run("getting channels for a release", func(t *testing.T) {
+			chs, err := client.GetChannels(semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, chs, []string{"candidate-4.19", "candidate-4.20", "fast-4.19", "fast-4.20", "stable-4.19"})
+		})
+
+		t.Run("getting releases in a channel", func(t *testing.T) {
+			rels, err := client.GetReleasesInChannel("eus-4.18")
+			assert.NilError(t, err)
+			assert.Equal(t, len(rels), 26, "unexpected number of releases")
+			assert.Assert(t, rels[0].Equal(semver.MustParse("4.18.1")))
+			assert.Assert(t, rels[len(rels)-1].Equal(semver.MustParse("4.18.26")))
+		})
+
+		t.Run("checking if a release is EUS", func(t *testing.T) {
+			eus, err := client.IsEUS(semver.MustParse("4.18.7"))
+			assert.NilError(t, err)
+			assert.Assert(t, eus)
+			eus, err = client.IsEUS(semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.Assert(t, !eus)
+		})
+
+		t.Run("getting typed release metadata", func(t *testing.T) {
+			md, err := client.GetReleaseMetadata(semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.Equal(t, md.URL, "https://access.redhat.com/errata/RHSA-2025:9278")
+			assert.Equal(t, md.ManifestRef.String(), "sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196")
+			assert.Equal(t, len(md.Channels), 5, "unexpected number of channels")
+			assert.Equal(t, len(md.Raw), 4, "unexpected number of metadata entries")
+		})
+
+		t.Run("getting channels merged across graph datas", func(t *testing.T) {
+			data2, err := os.ReadFile(valid420GraphData)
+			assert.NilError(t, err)
+			client2, err := NewReleaseClient(data, data2)
+			assert.NilError(t, err)
+			chs, err := client2.GetChannels(semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.Contains(chs, "eus-4.20"))
+			assert.Assert(t, cmp.Contains(chs, "stable-4.20"))
+			eus, err := client2.IsEUS(semver.MustParse("4.20.0"))
+			assert.NilError(t, err)
+			assert.Assert(t, eus)
+		})
+
+		t.Run("getting the update graph between two versions", func(t *testing.T) {
+			graph, err := client.GetGraph(GraphOptions{From: semver.MustParse("4.19.14"), To: semver.MustParse("4.19.17")})
+			assert.NilError(t, err)
+			assert.Equal(t, graph.Name, "releases 4.19.14-4.19.17")
+			ids := common.Map(graph.Nodes, func(n render.Node) string { return n.ID })
+			assert.DeepEqual(t, ids, []string{"4.19.14", "4.19.16", "4.19.17"})
+			for _, e := range graph.Edges {
+				assert.Equal(t, e.Style, render.SolidEdge)
+			}
+			var b strings.Builder
+			assert.NilError(t, render.WriteDOT(&b, graph))
+			assert.Assert(t, cmp.Contains(b.String(), `"4.19.14" -> "4.19.17";`))
+		})
+
+		t.Run("getting the update graph with conditional edges", func(t *testing.T) {
+			graph, err := client.GetGraph(GraphOptions{WithRisks: true})
+			assert.NilError(t, err)
+			assert.Equal(t, len(graph.Nodes), 43, "unexpected number of nodes")
+			idx := slices.IndexFunc(graph.Nodes, func(n render.Node) bool { return n.ID == "4.19.7" })
+			assert.Equal(t, graph.Nodes[idx].Style, render.BlockedNode, "blocked release should be styled as blocked")
+			newest := slices.MaxFunc(graph.Nodes, func(a, b render.Node) int {
+				return semver.MustParse(a.ID).Compare(semver.MustParse(b.ID))
+			})
+			assert.Equal(t, newest.Style, render.HeadNode, "newest release should be styled as head")
+			assert.Assert(t, !slices.ContainsFunc(graph.Edges, func(e render.Edge) bool { return e.From == newest.ID }))
+			idx = slices.IndexFunc(graph.Edges, func(e render.Edge) bool { return e.From == "4.19.11" && e.To == "4.19.17" })
+			assert.Assert(t, idx != -1)
+			assert.Equal(t, graph.Edges[idx].Style, render.DashedEdge)
+			assert.Assert(t, graph.Edges[idx].Label != "")
+			var b strings.Builder
+			assert.NilError(t, render.WriteMermaid(&b, graph))
+		})
+
 		t.Run("getting update path between two consecutive versions", func(t *testing.T) {
 			rels, err := client.GetUpdatePath(semver.MustParse("4.19.0"), semver.MustParse("4.19.1"))
 			assert.NilError(t, err)
@@ -134,10 +218,35 @@ func TestReleaseClient(t *testing.T) {
 			assert.ErrorIs(t, err, libErrs.ErrNotFound)
 			_, err = client.GetMetadata(invalidVer)
 			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			_, err = client.GetReleaseMetadata(invalidVer)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			_, err = client.GetChannels(invalidVer)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			_, err = client.IsEUS(invalidVer)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			_, err = client.GetGraph(GraphOptions{From: invalidVer})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
 			_, err = client.GetUpdatePath(invalidVer, semver.MustParse("4.19.13"))
-			assert.ErrorIs(t, err, dijkstra.ErrVertexNotFound)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
 			_, err = client.GetUpdatePath(semver.MustParse("4.19.13"), invalidVer)
-			assert.ErrorIs(t, err, dijkstra.ErrVertexNotFound)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+
+			var notFound *libErrs.VersionNotFoundError
+			_, err = client.GetPayload(invalidVer)
+			assert.Assert(t, errors.As(err, &notFound))
+			assert.Equal(t, notFound.Version, invalidVer.String())
+			_, err = client.GetUpdatePathWithRisks(semver.MustParse("4.19.13"), invalidVer)
+			assert.Assert(t, errors.As(err, &notFound))
+			assert.Equal(t, notFound.Version, invalidVer.String())
+
+			var relErr *libErrs.Error
+			assert.Assert(t, errors.As(err, &relErr))
+			assert.Equal(t, relErr.Kind(), libErrs.ReleaseErrorKind)
+		})
+
+		t.Run("channel does not exist", func(t *testing.T) {
+			_, err := client.GetReleasesInChannel("stable-4.99")
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
 		})
 
 		t.Run("getting upgrades across channels and no path exists", func(t *testing.T) {
@@ -152,3 +261,91 @@ func TestReleaseClient(t *testing.T) {
 		})
 	})
 }
+
+func TestReleaseClientConcurrentReaders(t *testing.T) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(t, err)
+	client, err := NewReleaseClient(data, data2)
+	assert.NilError(t, err)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 20 {
+				path, err := client.GetUpdatePath(semver.MustParse("4.19.13"), semver.MustParse("4.20.2"))
+				assert.Check(t, err)
+				assert.Check(t, cmp.Len(path, 4))
+				_, err = client.GetUpdatesFrom(semver.MustParse("4.19.0"))
+				assert.Check(t, err)
+				_, err = client.GetPayload(semver.MustParse("4.20.2"))
+				assert.Check(t, err)
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func loadBenchmarkClient(b *testing.B) *ReleaseClient {
+	b.Helper()
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(b, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(b, err)
+	client, err := NewReleaseClient(data, data2)
+	assert.NilError(b, err)
+	return client
+}
+
+func BenchmarkNewReleaseClient(b *testing.B) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(b, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(b, err)
+	for b.Loop() {
+		if _, err := NewReleaseClient(data, data2); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetUpdatePath(b *testing.B) {
+	client := loadBenchmarkClient(b)
+	from, to := semver.MustParse("4.19.13"), semver.MustParse("4.20.2")
+	for b.Loop() {
+		if _, err := client.GetUpdatePath(from, to); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetUpdatePathWithRisks(b *testing.B) {
+	client := loadBenchmarkClient(b)
+	from, to := semver.MustParse("4.19.11"), semver.MustParse("4.20.2")
+	for b.Loop() {
+		if _, err := client.GetUpdatePathWithRisks(from, to); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetUpdatesFrom(b *testing.B) {
+	client := loadBenchmarkClient(b)
+	from := semver.MustParse("4.19.0")
+	for b.Loop() {
+		if _, err := client.GetUpdatesFrom(from); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetPayload(b *testing.B) {
+	client := loadBenchmarkClient(b)
+	ver := semver.MustParse("4.20.2")
+	for b.Loop() {
+		if _, err := client.GetPayload(ver); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package release
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/pkg/compression"
+	"go.podman.io/image/v5/types"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// ImageReferencesPath is the image stream in the release payload that lists its component images.
+const ImageReferencesPath string = "release-manifests/image-references"
+
+// ReleaseComponent is an image referenced by a release payload, such as `etcd` or `cli`.
+type ReleaseComponent struct {
+	Name   string
+	Image  string
+	Digest digest.Digest
+}
+
+// imageStream is the subset of the `image-references` image stream that lists the components.
+type imageStream struct {
+	Spec struct {
+		Tags []struct {
+			Name string `json:"name"`
+			From struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"from"`
+		} `json:"tags"`
+	} `json:"spec"`
+}
+
+// GetReleaseComponents reads the component images of a release payload, sorted by name.
+// `payload` is a registry reference, or an OCI layout reference prefixed with `oci:`.
+// For manifest lists, the instance matching the architecture of `sysCtx` is read;
+// all instances reference the same components.
+func GetReleaseComponents(ctx context.Context, payload string, sysCtx *types.SystemContext) ([]ReleaseComponent, error) {
+	ref, err := parsePayloadRef(payload)
+	if err != nil {
+		return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: %w: %w", libErrs.ErrReadPayload, libErrs.ErrInvalidRef, err))
+	}
+	if sysCtx == nil {
+		sysCtx = &types.SystemContext{OSChoice: "linux"}
+	}
+
+	src, err := ref.NewImageSource(ctx, sysCtx)
+	if err != nil {
+		return nil, newReadPayloadErr(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	img, err := image.FromUnparsedImage(ctx, sysCtx, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return nil, newReadPayloadErr(err)
+	}
+	// NOTE: the release manifests are in the last layers, so search them first.
+	layers := img.LayerInfos()
+	for _, info := range slices.Backward(layers) {
+		data, err := readLayerFile(ctx, src, info, ImageReferencesPath)
+		if err != nil {
+			return nil, newReadPayloadErr(err)
+		}
+		if data != nil {
+			logger.Debug("found image references", slog.String("payload", payload), slog.String("layer", info.Digest.String()))
+			return parseImageReferences(data)
+		}
+	}
+	return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %s %w", libErrs.ErrReadPayload, ImageReferencesPath, libErrs.ErrNotFound))
+}
+
+func parsePayloadRef(payload string) (types.ImageReference, error) {
+	if ociRef, ok := strings.CutPrefix(payload, "oci:"); ok {
+		return layout.ParseReference(ociRef)
+	}
+	return docker.ParseReference("//" + strings.TrimPrefix(payload, "docker://"))
+}
+
+// readLayerFile returns the content of `name` in the layer, or nil if the layer doesn't contain it.
+func readLayerFile(ctx context.Context, src types.ImageSource, info types.BlobInfo, name string) ([]byte, error) {
+	reader, _, err := src.GetBlob(ctx, info, none.NoCache)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	decompressed, _, err := compression.AutoDecompress(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = decompressed.Close() }()
+
+	tarReader := tar.NewReader(decompressed)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read layer %s: %w", info.Digest, err)
+		}
+		if header.Typeflag == tar.TypeReg && path.Clean(strings.TrimPrefix(header.Name, "./")) == name {
+			return io.ReadAll(tarReader)
+		}
+	}
+}
+
+func parseImageReferences(data []byte) ([]ReleaseComponent, error) {
+	var stream imageStream
+	if err := json.Unmarshal(data, &stream); err != nil {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %w", libErrs.ErrReadPayload, err))
+	}
+	components := make([]ReleaseComponent, 0, len(stream.Spec.Tags))
+	for _, tag := range stream.Spec.Tags {
+		if tag.From.Kind != "DockerImage" {
+			continue
+		}
+		named, err := reference.ParseNormalizedNamed(tag.From.Name)
+		if err != nil {
+			return nil, libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: component %q: %w", libErrs.ErrReadPayload, tag.Name, err))
+		}
+		component := ReleaseComponent{Name: tag.Name, Image: tag.From.Name}
+		if canonical, ok := named.(reference.Canonical); ok {
+			component.Digest = canonical.Digest()
+		}
+		components = append(components, component)
+	}
+	slices.SortFunc(components, func(a, b ReleaseComponent) int { return strings.Compare(a.Name, b.Name) })
+	return components, nil
+}
+
+func newReadPayloadErr(err error) *libErrs.Error {
+	return common.WrapImageError(libErrs.ReleaseErrorKind, libErrs.ErrReadPayload, err)
+}
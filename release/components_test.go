@@ -0,0 +1,121 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/oci/layout"
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const testImageReferences = `{
+  "kind": "ImageStream",
+  "apiVersion": "image.openshift.io/v1",
+  "metadata": {"name": "4.19.1"},
+  "spec": {
+    "tags": [
+      {"name": "etcd", "from": {"kind": "DockerImage", "name": "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111"}},
+      {"name": "cli", "from": {"kind": "DockerImage", "name": "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:2222222222222222222222222222222222222222222222222222222222222222"}},
+      {"name": "ignored", "from": {"kind": "ImageStreamTag", "name": "cli:latest"}}
+    ]
+  }
+}`
+
+// newPayloadLayout writes an image to an OCI layout with one layer for each of `layers`.
+func newPayloadLayout(t *testing.T, layers ...map[string]string) string {
+	ctx := context.Background()
+	dir := t.TempDir()
+	destRef, err := layout.NewReference(dir, "latest")
+	assert.NilError(t, err)
+	dest, err := destRef.NewImageDestination(ctx, nil)
+	assert.NilError(t, err)
+	defer func() { _ = dest.Close() }()
+
+	config := imgspecv1.Image{Platform: imgspecv1.Platform{OS: "linux", Architecture: "amd64"}}
+	config.RootFS.Type = "layers"
+	descs := []imgspecv1.Descriptor{}
+	for _, files := range layers {
+		var buf bytes.Buffer
+		gzWriter := gzip.NewWriter(&buf)
+		digester := digest.Canonical.Digester()
+		tarWriter := tar.NewWriter(io.MultiWriter(gzWriter, digester.Hash()))
+		for name, content := range files {
+			assert.NilError(t, tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(content))}))
+			_, err := tarWriter.Write([]byte(content))
+			assert.NilError(t, err)
+		}
+		assert.NilError(t, tarWriter.Close())
+		assert.NilError(t, gzWriter.Close())
+		desc, err := common.PutBlob(ctx, dest, imgspecv1.MediaTypeImageLayerGzip, buf.Bytes(), false)
+		assert.NilError(t, err)
+		descs = append(descs, desc)
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, digester.Digest())
+	}
+
+	rawConfig, err := json.Marshal(config)
+	assert.NilError(t, err)
+	configDesc, err := common.PutBlob(ctx, dest, imgspecv1.MediaTypeImageConfig, rawConfig, true)
+	assert.NilError(t, err)
+	rawManifest, err := json.Marshal(imgspecv1.Manifest{
+		Versioned: imgspecs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    descs,
+	})
+	assert.NilError(t, err)
+	assert.NilError(t, dest.PutManifest(ctx, rawManifest, nil))
+	assert.NilError(t, dest.Commit(ctx, nil))
+	return "oci:" + dir + ":latest"
+}
+
+func TestGetReleaseComponents(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("reading the payload image references", func(t *testing.T) {
+			payload := newPayloadLayout(t,
+				map[string]string{"usr/bin/cluster-version-operator": "binary"},
+				map[string]string{"release-manifests/image-references": testImageReferences, "release-manifests/release-metadata": "{}"},
+			)
+			components, err := GetReleaseComponents(ctx, payload, nil)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, components, []ReleaseComponent{
+				{
+					Name:   "cli",
+					Image:  "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:2222222222222222222222222222222222222222222222222222222222222222",
+					Digest: "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+				},
+				{
+					Name:   "etcd",
+					Image:  "quay.io/openshift-release-dev/ocp-v4.0-art-dev@sha256:1111111111111111111111111111111111111111111111111111111111111111",
+					Digest: "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				},
+			})
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("the payload has no image references", func(t *testing.T) {
+			payload := newPayloadLayout(t, map[string]string{"usr/bin/cluster-version-operator": "binary"})
+			_, err := GetReleaseComponents(ctx, payload, nil)
+			assert.ErrorIs(t, err, libErrs.ErrReadPayload)
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("the payload reference is invalid", func(t *testing.T) {
+			_, err := GetReleaseComponents(ctx, "quay.io/Invalid/Release:4.19.1", nil)
+			assert.ErrorIs(t, err, libErrs.ErrInvalidRef)
+		})
+	})
+}
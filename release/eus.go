@@ -0,0 +1,195 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/RyanCarrier/dijkstra/v2"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// Extra weights given to updates so that paths within the EUS channel are always preferred, followed
+// by paths without conditional updates.
+const (
+	nonEUSPenalty      uint64 = 100
+	conditionalPenalty uint64 = 10
+)
+
+// UpdateHop is a single update in an update plan.
+type UpdateHop struct {
+	From *semver.Version
+	To   *semver.Version
+	// ControlPlaneOnly is set when `To` is only visited by the control plane.
+	ControlPlaneOnly bool
+	// PauseWorkers is set when worker MachineConfigPools must be paused during the update.
+	PauseWorkers bool
+	// InEUSChannel is set when `To` belongs to the target EUS channel.
+	InEUSChannel bool
+	// Risks are the known risks of a conditional update.
+	Risks []Risk
+}
+
+// EUSUpdatePlan describes an EUS-to-EUS update.
+type EUSUpdatePlan struct {
+	From    *semver.Version
+	To      *semver.Version
+	Channel string
+	Hops    []UpdateHop
+}
+
+// Path returns all the versions visited by the control plane, including `From` and `To`.
+func (p *EUSUpdatePlan) Path() []*semver.Version {
+	path := []*semver.Version{p.From}
+	for _, hop := range p.Hops {
+		path = append(path, hop.To)
+	}
+	return path
+}
+
+// GetEUSUpdatePlan returns the update plan between two EUS releases two minor versions apart.
+// The intermediate minor release is only visited by the control plane, so worker pools must be
+// paused from the moment the cluster leaves the `from` minor until it reaches the `to` minor.
+// Releases in the target EUS channel are preferred over shorter paths.
+func (c *ReleaseClient) GetEUSUpdatePlan(from *semver.Version, to *semver.Version) (*EUSUpdatePlan, error) {
+	return c.getEUSUpdatePlan(from, to, false)
+}
+
+// GetEUSUpdatePlanWithRisks returns an EUS update plan while also using conditional edges.
+// Conditional edges are given higher weight, so that updates without risks are preferred.
+func (c *ReleaseClient) GetEUSUpdatePlanWithRisks(from *semver.Version, to *semver.Version) (*EUSUpdatePlan, error) {
+	return c.getEUSUpdatePlan(from, to, true)
+}
+
+func (c *ReleaseClient) getEUSUpdatePlan(from *semver.Version, to *semver.Version, withRisks bool) (*EUSUpdatePlan, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
+	if from.Major() != to.Major() || from.Minor()+2 != to.Minor() {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %s and %s are not two minor versions apart", libErrs.ErrInvalidEUS, from, to))
+	}
+	for _, ver := range []*semver.Version{from, to} {
+		eus, err := c.IsEUS(ver)
+		if err != nil {
+			return nil, err
+		}
+		if !eus {
+			return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %s is not an EUS release", libErrs.ErrInvalidEUS, ver))
+		}
+	}
+
+	channel := eusChannel(to)
+	inChannel, err := c.GetReleasesInChannel(channel)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[string]bool, len(inChannel))
+	for _, ver := range inChannel {
+		members[ver.String()] = true
+	}
+
+	graph, err := c.buildEUSGraph(from, to, members, withRisks)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	path, err := graph.Shortest(from.String(), to.String())
+	if err != nil {
+		if errors.Is(err, dijkstra.ErrNoPath) {
+			return nil, libErrs.NewReleaseErr(libErrs.ErrUpdateNotFound)
+		}
+		return nil, libErrs.NewReleaseErr(&libErrs.GraphInconsistencyError{From: from.String(), To: to.String(), Err: err})
+	}
+	logger.Debug("eus update path", slog.Any("path", path.Path), slog.Uint64("weight", path.Distance))
+
+	versions := common.Map(path.Path, func(v string) *semver.Version { return c.versions[v] })
+	hops := make([]UpdateHop, 0, len(versions)-1)
+	for i := 1; i < len(versions); i++ {
+		src, dst := versions[i-1], versions[i]
+		var risks []Risk
+		if withRisks && !c.hasEdge(src.String(), dst.String()) {
+			if risks, err = c.GetRisks(src, dst); err != nil {
+				return nil, err
+			}
+		}
+		hops = append(hops, UpdateHop{
+			From:             src,
+			To:               dst,
+			ControlPlaneOnly: dst.Minor() == from.Minor()+1,
+			// Workers stay paused while leaving the source minor and until reaching the target one.
+			PauseWorkers: src.Minor() < to.Minor() && dst.Minor() > from.Minor(),
+			InEUSChannel: members[dst.String()],
+			Risks:        risks,
+		})
+	}
+	return &EUSUpdatePlan{From: from, To: to, Channel: channel, Hops: hops}, nil
+}
+
+// buildEUSGraph builds an update graph restricted to the minor versions between `from` and `to`,
+// where updates to releases outside the EUS channel and conditional updates are penalized.
+func (c *ReleaseClient) buildEUSGraph(from, to *semver.Version, members map[string]bool, withRisks bool) (*dijkstra.MappedGraph[string], error) {
+	inRange := func(v string) bool {
+		ver := c.versions[v]
+		return ver != nil && ver.Major() == from.Major() && ver.Minor() >= from.Minor() && ver.Minor() <= to.Minor()
+	}
+	graph := dijkstra.NewMappedGraph[string]()
+	for _, gdata := range c.data {
+		for _, node := range gdata.Nodes {
+			if !inRange(node.Version) {
+				continue
+			}
+			if err := graph.AddEmptyVertex(node.Version); err != nil && !errors.Is(err, dijkstra.ErrVertexAlreadyExists) {
+				return nil, err
+			}
+		}
+	}
+	addArc := func(src, dst string, weight uint64) error {
+		if !inRange(src) || !inRange(dst) {
+			return nil
+		}
+		if !members[dst] {
+			weight += nonEUSPenalty
+		}
+		// Keep the lowest weight when the same update is found in several graph datas.
+		if cur, err := graph.GetArc(src, dst); err == nil && cur <= weight {
+			return nil
+		}
+		return graph.AddArc(src, dst, weight)
+	}
+	for _, gdata := range c.data {
+		for _, edge := range gdata.Edges {
+			if err := addArc(gdata.Nodes[edge[0]].Version, gdata.Nodes[edge[1]].Version, 1); err != nil {
+				return nil, err
+			}
+		}
+		if !withRisks {
+			continue
+		}
+		for _, ce := range gdata.CondEdges {
+			for _, e := range ce.Edges {
+				if err := addArc(e.From, e.To, 1+conditionalPenalty); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return &graph, nil
+}
+
+// hasEdge returns whether there is an unconditional update between `from` and `to` in any graph data.
+func (c *ReleaseClient) hasEdge(from string, to string) bool {
+	for _, gdata := range c.data {
+		idx, err := gdata.findNodeIndex(from)
+		if err != nil {
+			continue
+		}
+		for _, n := range gdata.nodesFrom(idx) {
+			if gdata.Nodes[n].Version == to {
+				return true
+			}
+		}
+	}
+	return false
+}
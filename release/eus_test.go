@@ -0,0 +1,89 @@
+package release
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestEUSUpdatePlan(t *testing.T) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(t, err)
+
+	client, err := NewReleaseClient(data, data2)
+	assert.NilError(t, err)
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("planning an EUS update with conditional edges", func(t *testing.T) {
+			from := semver.MustParse("4.18.27")
+			to := semver.MustParse("4.20.3")
+			plan, err := client.GetEUSUpdatePlanWithRisks(from, to)
+			assert.NilError(t, err)
+			assert.Equal(t, plan.Channel, "eus-4.20")
+			assert.Equal(t, len(plan.Hops), 2, "unexpected number of hops")
+
+			hop := plan.Hops[0]
+			assert.Assert(t, hop.From.Equal(from))
+			assert.Equal(t, hop.To.Minor(), uint64(19))
+			assert.Assert(t, hop.ControlPlaneOnly)
+			assert.Assert(t, hop.PauseWorkers)
+			assert.Assert(t, hop.InEUSChannel)
+			assert.Assert(t, len(hop.Risks) > 0, "expected risks for conditional update")
+
+			hop = plan.Hops[1]
+			assert.Assert(t, hop.To.Equal(to))
+			assert.Assert(t, !hop.ControlPlaneOnly)
+			assert.Assert(t, hop.PauseWorkers)
+
+			path := plan.Path()
+			assert.Equal(t, len(path), 3, "unexpected path length")
+			assert.Assert(t, path[0].Equal(from))
+			assert.Assert(t, path[2].Equal(to))
+		})
+
+		t.Run("planning an EUS update with z-stream updates", func(t *testing.T) {
+			plan, err := client.GetEUSUpdatePlanWithRisks(semver.MustParse("4.18.1"), semver.MustParse("4.20.3"))
+			assert.NilError(t, err)
+			first, last := plan.Hops[0], plan.Hops[len(plan.Hops)-1]
+			assert.Equal(t, first.To.Minor(), uint64(18))
+			assert.Assert(t, !first.PauseWorkers, "workers should not be paused for z-stream updates")
+			assert.Equal(t, last.From.Minor(), uint64(20))
+			assert.Assert(t, !last.PauseWorkers, "workers should not be paused after reaching the target minor")
+			for _, hop := range plan.Hops {
+				assert.Equal(t, hop.ControlPlaneOnly, hop.To.Minor() == 19)
+			}
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("versions are not two minors apart", func(t *testing.T) {
+			_, err := client.GetEUSUpdatePlan(semver.MustParse("4.18.1"), semver.MustParse("4.19.1"))
+			assert.ErrorIs(t, err, libErrs.ErrInvalidEUS)
+		})
+
+		t.Run("version is not an EUS release", func(t *testing.T) {
+			_, err := client.GetEUSUpdatePlan(semver.MustParse("4.19.1"), semver.MustParse("4.21.0"))
+			assert.ErrorIs(t, err, libErrs.ErrInvalidEUS)
+		})
+
+		t.Run("version is not found", func(t *testing.T) {
+			client, err := NewReleaseClient(data)
+			assert.NilError(t, err)
+			_, err = client.GetEUSUpdatePlan(semver.MustParse("4.17.1"), semver.MustParse("4.19.1"))
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+			_, err = client.GetEUSUpdatePlan(semver.MustParse("4.18.1"), semver.MustParse("4.20.0"))
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("only conditional updates cross minor versions", func(t *testing.T) {
+			_, err := client.GetEUSUpdatePlan(semver.MustParse("4.18.27"), semver.MustParse("4.20.3"))
+			assert.ErrorIs(t, err, libErrs.ErrUpdateNotFound)
+		})
+	})
+}
@@ -3,74 +3,188 @@ package release
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"slices"
+	"strings"
 
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/r4f4/oc-mirror-libs/common"
 	libErrs "github.com/r4f4/oc-mirror-libs/errors"
 )
 
-type graphData struct {
-	Nodes     []node     `json:"nodes"`
-	Edges     [][]int    `json:"edges"`
-	CondEdges []condEdge `json:"conditionalEdges"`
+// GraphData is the Cincinnati graph data model.
+type GraphData struct {
+	arch Architecture
+	// Version is the graph data format version.
+	Version   int               `json:"version"`
+	Nodes     []Node            `json:"nodes"`
+	Edges     [][]int           `json:"edges"`
+	CondEdges []ConditionalEdge `json:"conditionalEdges"`
+
+	// Indexes built after parsing, read-only afterwards.
+	nodeIndex map[string]int
+	edgesFrom [][]int
+	edgesTo   [][]int
 }
 
-type node struct {
+// Node is a release in the graph data.
+type Node struct {
 	Version  string            `json:"version"`
 	Payload  string            `json:"payload"`
 	Metadata map[string]string `json:"metadata"`
 }
 
-type condEdge struct {
-	Edges []edge `json:"edges"`
-	Risks []Risk `json:"risks"`
+// ConditionalEdge is a set of updates that share the same risks.
+type ConditionalEdge struct {
+	Edges []VersionEdge `json:"edges"`
+	Risks []Risk        `json:"risks"`
 }
 
-type edge struct {
+// VersionEdge is an update between two release versions.
+type VersionEdge struct {
 	From string `json:"from"`
 	To   string `json:"to"`
 }
 
-func parseGraphData(data []byte) (*graphData, error) {
-	var gdata graphData
+// channels returns the channels listed in the node metadata.
+func (n Node) channels() []string {
+	chs := []string{}
+	for _, ch := range strings.Split(n.Metadata[ChannelsMetadataKey], ",") {
+		if ch = strings.TrimSpace(ch); ch != "" {
+			chs = append(chs, ch)
+		}
+	}
+	return chs
+}
+
+// ParseGraphData parses and validates Cincinnati graph data.
+func ParseGraphData(data []byte) (*GraphData, error) {
+	return parseGraphData(data)
+}
+
+func parseGraphData(data []byte) (*GraphData, error) {
+	var gdata GraphData
 	if err := json.Unmarshal(data, &gdata); err != nil {
 		return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %w", libErrs.ErrParseGraphData, err))
 	}
+	if err := gdata.validate(); err != nil {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %w", libErrs.ErrParseGraphData, err))
+	}
+	gdata.buildIndexes()
 	return &gdata, nil
 }
 
-func (o *graphData) findNodeIndex(ver string) (int, error) {
-	idx := slices.IndexFunc(o.Nodes, func(r node) bool { return ver == r.Version })
-	if idx == -1 {
-		return -1, libErrs.NewReleaseErr(fmt.Errorf("%q %w", ver, libErrs.ErrNotFound))
+// Filter returns a copy of the graph data with only the nodes for which `keep` returns true.
+// Edges and conditional edges from or to removed nodes are dropped.
+func (o *GraphData) Filter(keep func(Node) bool) *GraphData {
+	res := &GraphData{
+		arch:      o.arch,
+		Version:   o.Version,
+		Nodes:     []Node{},
+		Edges:     [][]int{},
+		CondEdges: []ConditionalEdge{},
 	}
-	return idx, nil
+	remap := make([]int, len(o.Nodes))
+	kept := sets.New[string]()
+	for i, n := range o.Nodes {
+		remap[i] = -1
+		if keep(n) {
+			remap[i] = len(res.Nodes)
+			kept.Insert(n.Version)
+			res.Nodes = append(res.Nodes, Node{Version: n.Version, Payload: n.Payload, Metadata: maps.Clone(n.Metadata)})
+		}
+	}
+	for _, e := range o.Edges {
+		if from, to := remap[e[0]], remap[e[1]]; from != -1 && to != -1 {
+			res.Edges = append(res.Edges, []int{from, to})
+		}
+	}
+	for _, ce := range o.CondEdges {
+		edges := slices.DeleteFunc(slices.Clone(ce.Edges), func(e VersionEdge) bool {
+			return !kept.Has(e.From) || !kept.Has(e.To)
+		})
+		if len(edges) > 0 {
+			res.CondEdges = append(res.CondEdges, ConditionalEdge{Edges: edges, Risks: slices.Clone(ce.Risks)})
+		}
+	}
+	res.buildIndexes()
+	return res
 }
 
-func (o *graphData) nodesFrom(node int) []int {
-	nodes := make([]int, 0, len(o.Edges))
-	for _, edge := range o.Edges {
-		if edge[0] == node {
-			nodes = append(nodes, edge[1])
+// FilterRange returns a copy of the graph data with only the releases between `from` and `to`, inclusive.
+// A nil bound leaves that side of the range open.
+func (o *GraphData) FilterRange(from *semver.Version, to *semver.Version) *GraphData {
+	return o.Filter(func(n Node) bool {
+		ver, err := semver.NewVersion(n.Version)
+		if err != nil {
+			return false
 		}
+		return (from == nil || !ver.LessThan(from)) && (to == nil || !ver.GreaterThan(to))
+	})
+}
+
+// FilterChannels returns a copy of the graph data with only the releases in any of `channels`.
+func (o *GraphData) FilterChannels(channels ...string) *GraphData {
+	return o.Filter(func(n Node) bool {
+		return slices.ContainsFunc(n.channels(), func(ch string) bool { return slices.Contains(channels, ch) })
+	})
+}
+
+// FilterVersions returns a copy of the graph data with only the given releases.
+func (o *GraphData) FilterVersions(vers ...*semver.Version) *GraphData {
+	keep := sets.New(common.Map(vers, (*semver.Version).String)...)
+	return o.Filter(func(n Node) bool { return keep.Has(n.Version) })
+}
+
+// WriteJSON writes the graph data in Cincinnati JSON format.
+func (o *GraphData) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	// Risk matching rules contain PromQL expressions, keep them readable.
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(o); err != nil {
+		return libErrs.NewReleaseErr(err)
 	}
-	return nodes
+	return nil
 }
 
-func (o *graphData) nodesTo(node int) []int {
-	nodes := make([]int, 0, len(o.Edges))
+// buildIndexes builds the version index and the adjacency lists of a validated graph.
+func (o *GraphData) buildIndexes() {
+	o.nodeIndex = make(map[string]int, len(o.Nodes))
+	for i, n := range o.Nodes {
+		o.nodeIndex[n.Version] = i
+	}
+	o.edgesFrom = make([][]int, len(o.Nodes))
+	o.edgesTo = make([][]int, len(o.Nodes))
 	for _, edge := range o.Edges {
-		if edge[1] == node {
-			nodes = append(nodes, edge[0])
-		}
+		o.edgesFrom[edge[0]] = append(o.edgesFrom[edge[0]], edge[1])
+		o.edgesTo[edge[1]] = append(o.edgesTo[edge[1]], edge[0])
 	}
-	return nodes
 }
 
-func (o *graphData) conditionalEdgesFrom(node int) []condEdge {
+func (o *GraphData) findNodeIndex(ver string) (int, error) {
+	idx, ok := o.nodeIndex[ver]
+	if !ok {
+		return -1, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver})
+	}
+	return idx, nil
+}
+
+func (o *GraphData) nodesFrom(node int) []int {
+	return o.edgesFrom[node]
+}
+
+func (o *GraphData) nodesTo(node int) []int {
+	return o.edgesTo[node]
+}
+
+func (o *GraphData) conditionalEdgesFrom(node int) []ConditionalEdge {
 	version := o.Nodes[node].Version
-	condEdges := []condEdge{}
+	condEdges := []ConditionalEdge{}
 	for _, ce := range o.CondEdges {
-		edges := []edge{}
+		edges := []VersionEdge{}
 		for _, e := range ce.Edges {
 			if e.From != version {
 				continue
@@ -78,17 +192,17 @@ func (o *graphData) conditionalEdgesFrom(node int) []condEdge {
 			edges = append(edges, e)
 		}
 		if len(edges) > 0 {
-			condEdges = append(condEdges, condEdge{Edges: edges, Risks: ce.Risks})
+			condEdges = append(condEdges, ConditionalEdge{Edges: edges, Risks: ce.Risks})
 		}
 	}
 	return condEdges
 }
 
-func (o *graphData) conditionalEdgesTo(node int) []condEdge {
+func (o *GraphData) conditionalEdgesTo(node int) []ConditionalEdge {
 	version := o.Nodes[node].Version
-	condEdges := []condEdge{}
+	condEdges := []ConditionalEdge{}
 	for _, ce := range o.CondEdges {
-		edges := []edge{}
+		edges := []VersionEdge{}
 		for _, e := range ce.Edges {
 			if e.To != version {
 				continue
@@ -96,13 +210,13 @@ func (o *graphData) conditionalEdgesTo(node int) []condEdge {
 			edges = append(edges, e)
 		}
 		if len(edges) > 0 {
-			condEdges = append(condEdges, condEdge{Edges: edges, Risks: ce.Risks})
+			condEdges = append(condEdges, ConditionalEdge{Edges: edges, Risks: ce.Risks})
 		}
 	}
 	return condEdges
 }
 
-func (o *graphData) getRisks(from string, to string) ([]Risk, error) {
+func (o *GraphData) getRisks(from string, to string) ([]Risk, error) {
 	risks := []Risk{}
 	for _, ce := range o.CondEdges {
 		for _, e := range ce.Edges {
@@ -0,0 +1,76 @@
+package release
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestGraphDataFilter(t *testing.T) {
+	data, err := os.ReadFile(valid420GraphData)
+	assert.NilError(t, err)
+	gdata, err := ParseGraphData(data)
+	assert.NilError(t, err)
+
+	t.Run("should filter by version range", func(t *testing.T) {
+		filtered := gdata.FilterRange(semver.MustParse("4.19.11"), semver.MustParse("4.20.2"))
+		client, err := NewReleaseClient(mustMarshal(t, filtered))
+		assert.NilError(t, err)
+		rels, err := client.GetReleases()
+		assert.NilError(t, err)
+		assert.Assert(t, rels[0].Equal(semver.MustParse("4.19.11")))
+		assert.Assert(t, rels[len(rels)-1].Equal(semver.MustParse("4.20.2")))
+
+		path, err := client.GetUpdatePathWithRisks(semver.MustParse("4.19.11"), semver.MustParse("4.20.2"))
+		assert.NilError(t, err)
+		assert.Equal(t, len(path), 3, "unexpected number of updates")
+		risks, err := client.GetRisks(path[0], path[1])
+		assert.NilError(t, err)
+		assert.Assert(t, len(risks) > 0)
+	})
+
+	t.Run("should filter by channel", func(t *testing.T) {
+		filtered := gdata.FilterChannels("eus-4.20")
+		assert.Equal(t, len(filtered.Nodes), 49, "unexpected number of nodes")
+		filtered = gdata.FilterChannels("candidate-4.21", "stable-4.21")
+		assert.Equal(t, len(filtered.Nodes), 4, "unexpected number of nodes")
+	})
+
+	t.Run("should filter by versions", func(t *testing.T) {
+		filtered := gdata.FilterVersions(semver.MustParse("4.20.0"), semver.MustParse("4.20.2"), semver.MustParse("4.21.0"))
+		assert.Equal(t, len(filtered.Nodes), 2, "unexpected number of nodes")
+		assert.Equal(t, len(filtered.Edges), 1, "unexpected number of edges")
+		assert.DeepEqual(t, filtered.Edges[0], []int{0, 1})
+		assert.Equal(t, len(filtered.CondEdges), 0, "unexpected number of conditional edges")
+	})
+
+	t.Run("should serialize back to Cincinnati JSON", func(t *testing.T) {
+		out := mustMarshal(t, gdata)
+		assert.Assert(t, cmp.Contains(string(out), `"matchingRules"`))
+		assert.Assert(t, cmp.Contains(string(out), `"version":1`))
+		reparsed, err := ParseGraphData(out)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, reparsed.Nodes, gdata.Nodes)
+		assert.DeepEqual(t, reparsed.Edges, gdata.Edges)
+		assert.Equal(t, string(mustMarshal(t, reparsed)), string(out))
+	})
+
+	t.Run("should not modify the original graph data", func(t *testing.T) {
+		filtered := gdata.FilterVersions(semver.MustParse("4.20.0"))
+		filtered.Nodes[0].Metadata["url"] = "modified"
+		idx, err := gdata.findNodeIndex("4.20.0")
+		assert.NilError(t, err)
+		assert.Assert(t, gdata.Nodes[idx].Metadata["url"] != "modified")
+	})
+}
+
+func mustMarshal(t *testing.T, gdata *GraphData) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	assert.NilError(t, gdata.WriteJSON(&buf))
+	return buf.Bytes()
+}
@@ -0,0 +1,417 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+	imgspecs "github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/manifest"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"go.podman.io/image/v5/types"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const (
+	// GraphDataEndpoint serves the cincinnati-graph-data tarball.
+	GraphDataEndpoint string = "https://api.openshift.com/api/upgrades_info/graph-data"
+	// GraphDataDir is where the graph data is stored inside the graph-data image.
+	GraphDataDir string = "/var/lib/cincinnati-graph-data"
+)
+
+// graphDataContent lists the tarball entries needed by the update service.
+// The `version` file is checked by the secondary metadata parser.
+var graphDataContent = []string{"channels/", "blocked-edges/", "raw/", "version"}
+
+// graphDataCmd copies the graph data to the volume shared with the update service.
+var graphDataCmd = []string{"/bin/bash", "-c", "exec cp -rp " + GraphDataDir + "/* /var/lib/cincinnati/graph-data"}
+
+// GraphImageOptions is used to configure the graph-data image build.
+type GraphImageOptions struct {
+	// DestDir is the OCI layout directory where the image is written.
+	DestDir string
+	// Tag is the image tag in the OCI layout. Defaults to "latest".
+	Tag string
+	// Tarball is the gzipped cincinnati-graph-data tarball.
+	Tarball io.Reader
+	// Graphs, when set, are used to generate the channel files,
+	// replacing the ones in the tarball.
+	// Use it to restrict the channels to the mirrored releases.
+	Graphs []*GraphData
+	// Channels are the channels selected for mirroring, whose files are generated from Graphs.
+	// Other channels of the graph nodes only list some of their releases and are left out.
+	// Defaults to all the channels of the graph nodes.
+	Channels []string
+	// BaseImage is an optional OCI layout reference (`path[:tag]`) of the base image.
+	// The base image must provide `/bin/bash` for the update service to copy the graph data.
+	// Without a base image, the image is data-only: it has no command and can't be run as the
+	// graph-data init container of the update service.
+	BaseImage string
+	// SystemCtx is used to select the base image instance from a multi-arch image.
+	SystemCtx *types.SystemContext
+	// Labels are added to the image configuration.
+	Labels map[string]string
+	// Created is the image creation time. Layer content always uses the epoch for reproducible builds.
+	Created time.Time
+}
+
+// GraphImageResult contains the graph-data image build output result.
+type GraphImageResult struct {
+	Path   string
+	Digest digest.Digest
+}
+
+// DownloadGraphDataTarball gets the cincinnati-graph-data tarball.
+// Only the client and endpoint options are used; the endpoint defaults to GraphDataEndpoint.
+func DownloadGraphDataTarball(ctx context.Context, options DownloadOptions) ([]byte, error) {
+	endpoint := options.Endpoint
+	if endpoint == "" {
+		endpoint = GraphDataEndpoint
+	}
+
+	logger.Debug("download graph data tarball", slog.String("GET", endpoint))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	resp, err := httpClient(options).Do(req)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if status := resp.StatusCode; status != http.StatusOK {
+		return nil, libErrs.NewReleaseErr(&libErrs.HTTPStatusError{StatusCode: status, URL: req.URL.Redacted()})
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, libErrs.NewReleaseErr(err)
+	}
+	return data, nil
+}
+
+// BuildGraphImage writes the OpenShift Update Service graph-data image to an OCI layout.
+// The graph data is added as a single layer on top of the base image, if any.
+// The resulting layout can be copied to a registry next to the release payloads.
+func BuildGraphImage(ctx context.Context, opts GraphImageOptions) (*GraphImageResult, error) {
+	if opts.Tarball == nil && len(opts.Graphs) == 0 {
+		return nil, newBuildImageErr(fmt.Errorf("no graph data tarball or graphs provided"))
+	}
+	if opts.Tag == "" {
+		opts.Tag = "latest"
+	}
+
+	files := map[string][]byte{}
+	if opts.Tarball != nil {
+		var err error
+		if files, err = readGraphDataTarball(opts.Tarball, len(opts.Graphs) == 0); err != nil {
+			return nil, newBuildImageErr(err)
+		}
+	}
+	maps.Copy(files, channelFiles(opts.Graphs, opts.Channels))
+
+	layer, diffID, err := graphDataLayer(files)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+
+	destRef, err := layout.NewReference(opts.DestDir, opts.Tag)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	dest, err := destRef.NewImageDestination(ctx, opts.SystemCtx)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	defer func() { _ = dest.Close() }()
+
+	config, layers, err := copyBaseImage(ctx, opts, dest)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+
+	layerDesc, err := common.PutBlob(ctx, dest, imgspecv1.MediaTypeImageLayerGzip, layer, false)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	layers = append(layers, layerDesc)
+
+	config.RootFS.Type = "layers"
+	config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+	config.Config.Cmd = nil
+	if opts.BaseImage != "" {
+		config.Config.Cmd = graphDataCmd
+	}
+	config.Config.Entrypoint = nil
+	if config.Config.Labels == nil {
+		config.Config.Labels = map[string]string{}
+	}
+	config.Config.Labels[imgspecv1.AnnotationTitle] = "cincinnati-graph-data"
+	maps.Copy(config.Config.Labels, opts.Labels)
+	history := imgspecv1.History{CreatedBy: "oc-mirror-libs: graph data", Comment: "cincinnati-graph-data"}
+	if !opts.Created.IsZero() {
+		created := opts.Created.UTC()
+		config.Created = &created
+		history.Created = &created
+	}
+	config.History = append(config.History, history)
+
+	rawConfig, err := json.Marshal(config)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	configDesc, err := common.PutBlob(ctx, dest, imgspecv1.MediaTypeImageConfig, rawConfig, true)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+
+	rawManifest, err := json.Marshal(imgspecv1.Manifest{
+		Versioned:   imgspecs.Versioned{SchemaVersion: 2},
+		MediaType:   imgspecv1.MediaTypeImageManifest,
+		Config:      configDesc,
+		Layers:      layers,
+		Annotations: map[string]string{imgspecv1.AnnotationTitle: "cincinnati-graph-data"},
+	})
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	if err := dest.PutManifest(ctx, rawManifest, nil); err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	if err := dest.Commit(ctx, nil); err != nil {
+		return nil, newBuildImageErr(err)
+	}
+
+	manifestDigest, err := manifest.Digest(rawManifest)
+	if err != nil {
+		return nil, newBuildImageErr(err)
+	}
+	logger.Info("built graph data image", slog.String("path", opts.DestDir), slog.String("digest", manifestDigest.String()))
+	return &GraphImageResult{Path: opts.DestDir, Digest: manifestDigest}, nil
+}
+
+// readGraphDataTarball returns the regular files of the tarball needed by the update service.
+func readGraphDataTarball(tarball io.Reader, withChannels bool) (map[string][]byte, error) {
+	gzReader, err := gzip.NewReader(tarball)
+	if err != nil {
+		return nil, fmt.Errorf("decompress graph data tarball: %w", err)
+	}
+	defer func() { _ = gzReader.Close() }()
+
+	files := map[string][]byte{}
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read graph data tarball: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return nil, fmt.Errorf("invalid graph data tarball entry %q", header.Name)
+		}
+		if !withChannels && strings.HasPrefix(name, "channels/") {
+			continue
+		}
+		if !slices.ContainsFunc(graphDataContent, func(prefix string) bool {
+			return name == prefix || (strings.HasSuffix(prefix, "/") && strings.HasPrefix(name, prefix))
+		}) {
+			logger.Debug("skipping graph data content", slog.String("name", name))
+			continue
+		}
+
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("read graph data tarball entry %s: %w", name, err)
+		}
+		files[name] = data
+	}
+	return files, nil
+}
+
+// channelFiles returns the cincinnati-graph-data channel files for the channels found in the graphs,
+// restricted to `selected` if set.
+func channelFiles(graphs []*GraphData, selected []string) map[string][]byte {
+	channels := map[string]map[string]*semver.Version{}
+	for _, gdata := range graphs {
+		for _, node := range gdata.Nodes {
+			version, err := semver.NewVersion(node.Version)
+			if err != nil {
+				// NOTE: versions are checked when parsing the graph data.
+				continue
+			}
+			for _, ch := range node.channels() {
+				if len(selected) > 0 && !slices.Contains(selected, ch) {
+					continue
+				}
+				if channels[ch] == nil {
+					channels[ch] = map[string]*semver.Version{}
+				}
+				channels[ch][node.Version] = version
+			}
+		}
+	}
+
+	files := make(map[string][]byte, len(channels))
+	for ch, versions := range channels {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "name: %s\nversions:\n", ch)
+		for _, v := range slices.SortedFunc(maps.Values(versions), (*semver.Version).Compare) {
+			fmt.Fprintf(&buf, "- %s\n", v)
+		}
+		files[path.Join("channels", ch+".yaml")] = buf.Bytes()
+	}
+	return files
+}
+
+// graphDataLayer returns the gzipped layer with the graph data files under GraphDataDir and its diff ID.
+// Entries are sorted and have fixed ownership and times so that the same content yields the same layer.
+func graphDataLayer(files map[string][]byte) ([]byte, digest.Digest, error) {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	digester := digest.Canonical.Digester()
+	tarWriter := tar.NewWriter(io.MultiWriter(gzWriter, digester.Hash()))
+
+	epoch := time.Unix(0, 0)
+	root := strings.TrimPrefix(GraphDataDir, "/")
+	dirs := map[string]bool{}
+	addDirs := func(dir string) error {
+		var parents []string
+		for ; dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			dirs[dir] = true
+			parents = append(parents, dir)
+		}
+		slices.Reverse(parents)
+		for _, dir := range parents {
+			if err := tarWriter.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     dir + "/",
+				Mode:     0o755,
+				ModTime:  epoch,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		fullName := path.Join(root, name)
+		if err := addDirs(path.Dir(fullName)); err != nil {
+			return nil, "", err
+		}
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     fullName,
+			Mode:     0o644,
+			Size:     int64(len(files[name])),
+			ModTime:  epoch,
+		}); err != nil {
+			return nil, "", err
+		}
+		if _, err := tarWriter.Write(files[name]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, "", err
+	}
+	if err := gzWriter.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), digester.Digest(), nil
+}
+
+// copyBaseImage copies the base image layers to `dest` and returns its configuration and layers.
+// Without a base image, an empty linux configuration is returned for the chosen architecture.
+func copyBaseImage(ctx context.Context, opts GraphImageOptions, dest types.ImageDestination) (*imgspecv1.Image, []imgspecv1.Descriptor, error) {
+	if opts.BaseImage == "" {
+		arch := string(AMD64)
+		if opts.SystemCtx != nil && opts.SystemCtx.ArchitectureChoice != "" {
+			arch = opts.SystemCtx.ArchitectureChoice
+		}
+		return &imgspecv1.Image{Platform: imgspecv1.Platform{OS: "linux", Architecture: arch}}, nil, nil
+	}
+
+	srcRef, err := layout.ParseReference(opts.BaseImage)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := srcRef.NewImageSource(ctx, opts.SystemCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = src.Close() }()
+
+	img, err := image.FromUnparsedImage(ctx, opts.SystemCtx, image.UnparsedInstance(src, nil))
+	if err != nil {
+		return nil, nil, err
+	}
+	config, err := img.OCIConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	layers := []imgspecv1.Descriptor{}
+	for _, info := range img.LayerInfos() {
+		reader, size, err := src.GetBlob(ctx, info, none.NoCache)
+		if err != nil {
+			return nil, nil, err
+		}
+		blob, err := dest.PutBlob(ctx, reader, types.BlobInfo{Digest: info.Digest, Size: size}, none.NoCache, false)
+		_ = reader.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, imgspecv1.Descriptor{
+			MediaType: ociLayerMediaType(info.MediaType),
+			Digest:    blob.Digest,
+			Size:      blob.Size,
+		})
+	}
+	return config, layers, nil
+}
+
+// ociLayerMediaType converts docker layer media types to their OCI equivalent.
+func ociLayerMediaType(mediaType string) string {
+	switch mediaType {
+	case manifest.DockerV2Schema2LayerMediaType:
+		return imgspecv1.MediaTypeImageLayerGzip
+	case manifest.DockerV2SchemaLayerMediaTypeUncompressed:
+		return imgspecv1.MediaTypeImageLayer
+	case "":
+		return imgspecv1.MediaTypeImageLayerGzip
+	default:
+		return mediaType
+	}
+}
+
+func newBuildImageErr(err error) *libErrs.Error {
+	return libErrs.NewReleaseErr(fmt.Errorf("%w: %w", libErrs.ErrBuildImage, err))
+}
@@ -0,0 +1,228 @@
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/image"
+	"go.podman.io/image/v5/oci/layout"
+	"go.podman.io/image/v5/pkg/blobinfocache/none"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func newGraphDataTarball(t *testing.T) []byte {
+	files := map[string]string{
+		"./channels/stable-4.19.yaml":         "name: stable-4.19\nversions:\n- 4.19.1\n",
+		"./blocked-edges/4.19.1-example.yaml": "to: 4.19.1\nfrom: .*\n",
+		"./raw/metadata.json":                 "{}",
+		"./version":                           "1.2.0\n",
+		"./signatures/sha256/abc/signature-1": "sig",
+		"./README.md":                         "readme",
+	}
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzWriter)
+	for name, content := range files {
+		assert.NilError(t, tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o600,
+			Size:     int64(len(content)),
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tarWriter.Close())
+	assert.NilError(t, gzWriter.Close())
+	return buf.Bytes()
+}
+
+// readGraphImage returns the configuration of the image in the OCI layout and the files of its last layer.
+func readGraphImage(t *testing.T, ref string) (*imgspecv1.Image, int, map[string]string) {
+	ctx := context.Background()
+	srcRef, err := layout.ParseReference(ref)
+	assert.NilError(t, err)
+	src, err := srcRef.NewImageSource(ctx, nil)
+	assert.NilError(t, err)
+	defer func() { _ = src.Close() }()
+
+	img, err := image.FromUnparsedImage(ctx, nil, image.UnparsedInstance(src, nil))
+	assert.NilError(t, err)
+	config, err := img.OCIConfig(ctx)
+	assert.NilError(t, err)
+
+	layers := img.LayerInfos()
+	reader, _, err := src.GetBlob(ctx, layers[len(layers)-1], none.NoCache)
+	assert.NilError(t, err)
+	defer func() { _ = reader.Close() }()
+	gzReader, err := gzip.NewReader(reader)
+	assert.NilError(t, err)
+
+	files := map[string]string{}
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tarReader)
+		assert.NilError(t, err)
+		files[header.Name] = string(data)
+	}
+	return config, len(layers), files
+}
+
+func TestBuildGraphImage(t *testing.T) {
+	tarball := newGraphDataTarball(t)
+	graphDir := strings.TrimPrefix(GraphDataDir, "/")
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("building from the graph data tarball", func(t *testing.T) {
+			dest := t.TempDir()
+			res, err := BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir: dest,
+				Tarball: bytes.NewReader(tarball),
+				Labels:  map[string]string{"io.openshift.release": "4.19"},
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, res.Path, dest)
+			assert.Equal(t, res.Digest.Validate(), nil)
+
+			config, nlayers, files := readGraphImage(t, dest+":latest")
+			assert.Equal(t, nlayers, 1)
+			assert.Assert(t, config.Config.Cmd == nil, "data-only image should have no command")
+			assert.Equal(t, config.Config.Labels["io.openshift.release"], "4.19")
+			assert.Equal(t, config.Config.Labels[imgspecv1.AnnotationTitle], "cincinnati-graph-data")
+			assert.DeepEqual(t, files, map[string]string{
+				path.Join(graphDir, "channels/stable-4.19.yaml"):         "name: stable-4.19\nversions:\n- 4.19.1\n",
+				path.Join(graphDir, "blocked-edges/4.19.1-example.yaml"): "to: 4.19.1\nfrom: .*\n",
+				path.Join(graphDir, "raw/metadata.json"):                 "{}",
+				path.Join(graphDir, "version"):                           "1.2.0\n",
+			})
+		})
+
+		t.Run("generating the channels from graph data", func(t *testing.T) {
+			data, err := os.ReadFile(valid419GraphData)
+			assert.NilError(t, err)
+			gdata, err := ParseGraphData(data)
+			assert.NilError(t, err)
+			gdata = gdata.FilterRange(semver.MustParse("4.19.9"), semver.MustParse("4.19.11"))
+
+			dest := t.TempDir()
+			_, err = BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir: dest,
+				Tag:     "4.19",
+				Tarball: bytes.NewReader(tarball),
+				Graphs:  []*GraphData{gdata},
+			})
+			assert.NilError(t, err)
+
+			_, _, files := readGraphImage(t, dest+":4.19")
+			channel, ok := files[path.Join(graphDir, "channels/stable-4.19.yaml")]
+			assert.Assert(t, ok, "expected generated channel file")
+			assert.Equal(t, channel, "name: stable-4.19\nversions:\n- 4.19.9\n- 4.19.10\n- 4.19.11\n")
+			assert.Assert(t, cmp.Contains(files, path.Join(graphDir, "blocked-edges/4.19.1-example.yaml")))
+			// other channels of the releases are generated too, without their other releases
+			assert.Assert(t, cmp.Contains(files, path.Join(graphDir, "channels/fast-4.19.yaml")))
+
+			dest = t.TempDir()
+			_, err = BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir:  dest,
+				Tag:      "4.19",
+				Tarball:  bytes.NewReader(tarball),
+				Graphs:   []*GraphData{gdata},
+				Channels: []string{"stable-4.19"},
+			})
+			assert.NilError(t, err)
+			_, _, files = readGraphImage(t, dest+":4.19")
+			channels := []string{}
+			for name := range files {
+				if strings.HasPrefix(name, path.Join(graphDir, "channels")+"/") {
+					channels = append(channels, path.Base(name))
+				}
+			}
+			assert.DeepEqual(t, channels, []string{"stable-4.19.yaml"})
+		})
+
+		t.Run("building on top of a base image", func(t *testing.T) {
+			base := t.TempDir()
+			_, err := BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir: base,
+				Tarball: bytes.NewReader(tarball),
+				Labels:  map[string]string{"base": "true"},
+			})
+			assert.NilError(t, err)
+
+			dest := t.TempDir()
+			_, err = BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir:   dest,
+				Tarball:   bytes.NewReader(tarball),
+				BaseImage: base + ":latest",
+			})
+			assert.NilError(t, err)
+
+			config, nlayers, _ := readGraphImage(t, dest+":latest")
+			assert.Equal(t, nlayers, 2)
+			assert.Equal(t, len(config.RootFS.DiffIDs), 2)
+			assert.Equal(t, len(config.History), 2)
+			assert.Equal(t, config.Config.Labels["base"], "true")
+			assert.DeepEqual(t, config.Config.Cmd, graphDataCmd)
+		})
+
+		t.Run("building the same content twice", func(t *testing.T) {
+			res1, err := BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir: t.TempDir(),
+				Tarball: bytes.NewReader(tarball),
+			})
+			assert.NilError(t, err)
+			res2, err := BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir: t.TempDir(),
+				Tarball: bytes.NewReader(tarball),
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, res1.Digest, res2.Digest)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("no graph data is provided", func(t *testing.T) {
+			_, err := BuildGraphImage(context.Background(), GraphImageOptions{DestDir: t.TempDir()})
+			assert.Assert(t, errors.Is(err, libErrs.ErrBuildImage))
+		})
+
+		t.Run("the tarball is not gzipped", func(t *testing.T) {
+			_, err := BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir: t.TempDir(),
+				Tarball: strings.NewReader("not a tarball"),
+			})
+			assert.Assert(t, errors.Is(err, libErrs.ErrBuildImage))
+		})
+
+		t.Run("the base image does not exist", func(t *testing.T) {
+			_, err := BuildGraphImage(context.Background(), GraphImageOptions{
+				DestDir:   t.TempDir(),
+				Tarball:   bytes.NewReader(tarball),
+				BaseImage: filepath.Join(t.TempDir(), "missing"),
+			})
+			assert.Assert(t, errors.Is(err, libErrs.ErrBuildImage))
+		})
+	})
+}
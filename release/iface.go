@@ -2,21 +2,52 @@
 package release
 
 import (
+	"encoding/json"
+
 	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+)
+
+// Well-known release metadata keys.
+const (
+	ChannelsMetadataKey    string = "io.openshift.upgrades.graph.release.channels"
+	ManifestRefMetadataKey string = "io.openshift.upgrades.graph.release.manifestref"
+	URLMetadataKey         string = "url"
 )
 
+// EUSChannelPrefix is the prefix of Extended Update Support channel names.
+const EUSChannelPrefix string = "eus-"
+
 type Metadata map[string]string
 
+// ReleaseMetadata is a typed view of the release metadata.
+type ReleaseMetadata struct {
+	// URL is the errata URL for the release.
+	URL string
+	// ManifestRef is the digest of the release payload manifest.
+	ManifestRef digest.Digest
+	// Channels are the channels the release belongs to, sorted by name.
+	Channels []string
+	// Raw contains all the metadata entries, including the ones above.
+	Raw Metadata
+}
+
 type Risk struct {
 	Url     string `json:"url"`
 	Name    string `json:"name"`
 	Message string `json:"message"`
+	// MatchingRules are kept as-is, so that graph data can be serialized back without loss.
+	MatchingRules json.RawMessage `json:"matchingRules,omitempty"`
 }
 
 type ReleaseIntrospector interface {
 	GetReleases() ([]*semver.Version, error)
 	GetPayload(*semver.Version) (string, error)
 	GetMetadata(*semver.Version) (Metadata, error)
+	GetReleaseMetadata(*semver.Version) (*ReleaseMetadata, error)
+	GetChannels(*semver.Version) ([]string, error)
+	GetReleasesInChannel(channel string) ([]*semver.Version, error)
+	IsEUS(*semver.Version) (bool, error)
 	GetUpdatesFrom(*semver.Version) ([]*semver.Version, error)
 	GetUpdatesTo(*semver.Version) ([]*semver.Version, error)
 	GetUpdatePath(from *semver.Version, to *semver.Version) ([]*semver.Version, error)
@@ -0,0 +1,173 @@
+package release
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/Masterminds/semver/v3"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// Unreachable is the hop count between releases without an update path.
+const Unreachable = -1
+
+// UpgradeMatrix contains the number of hops needed to update between every pair of releases.
+type UpgradeMatrix struct {
+	// Versions are the row and column labels of the matrix, sorted.
+	Versions []*semver.Version `json:"versions"`
+	// Hops[i][j] is the number of updates from Versions[i] to Versions[j], or `Unreachable`.
+	Hops [][]int `json:"hops"`
+	// HopsWithRisks is the same as `Hops`, but also using conditional edges.
+	HopsWithRisks [][]int `json:"hopsWithRisks"`
+}
+
+// GetUpgradeMatrix computes the reachability between all pairs of releases.
+func (c *ReleaseClient) GetUpgradeMatrix() (*UpgradeMatrix, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(c.releases))
+	for i, v := range c.releases {
+		index[v.String()] = i
+	}
+	adjacency, adjacencyWithRisks := c.adjacency(index)
+	return &UpgradeMatrix{
+		Versions:      slices.Clone(c.releases),
+		Hops:          allPairsHops(adjacency),
+		HopsWithRisks: allPairsHops(adjacencyWithRisks),
+	}, nil
+}
+
+// adjacency returns the merged adjacency lists of the update graphs, without and with conditional edges.
+func (c *ReleaseClient) adjacency(index map[string]int) ([][]int, [][]int) {
+	edges := make([]map[int]bool, len(index))
+	condEdges := make([]map[int]bool, len(index))
+	for _, i := range index {
+		edges[i] = map[int]bool{}
+		condEdges[i] = map[int]bool{}
+	}
+	for _, gdata := range c.data {
+		for _, e := range gdata.Edges {
+			edges[index[gdata.Nodes[e[0]].Version]][index[gdata.Nodes[e[1]].Version]] = true
+		}
+		for _, ce := range gdata.CondEdges {
+			for _, e := range ce.Edges {
+				from, okFrom := index[e.From]
+				to, okTo := index[e.To]
+				if okFrom && okTo {
+					condEdges[from][to] = true
+				}
+			}
+		}
+	}
+	adjacency := make([][]int, len(index))
+	adjacencyWithRisks := make([][]int, len(index))
+	for i := range edges {
+		for j := range edges[i] {
+			adjacency[i] = append(adjacency[i], j)
+			adjacencyWithRisks[i] = append(adjacencyWithRisks[i], j)
+		}
+		for j := range condEdges[i] {
+			if !edges[i][j] {
+				adjacencyWithRisks[i] = append(adjacencyWithRisks[i], j)
+			}
+		}
+	}
+	return adjacency, adjacencyWithRisks
+}
+
+// allPairsHops runs a breadth-first search from every node.
+func allPairsHops(adjacency [][]int) [][]int {
+	hops := make([][]int, len(adjacency))
+	for src := range adjacency {
+		dist := make([]int, len(adjacency))
+		for i := range dist {
+			dist[i] = Unreachable
+		}
+		dist[src] = 0
+		queue := []int{src}
+		for len(queue) > 0 {
+			cur := queue[0]
+			queue = queue[1:]
+			for _, next := range adjacency[cur] {
+				if dist[next] == Unreachable {
+					dist[next] = dist[cur] + 1
+					queue = append(queue, next)
+				}
+			}
+		}
+		hops[src] = dist
+	}
+	return hops
+}
+
+// GetHops returns the number of hops from `from` to `to`, without and with conditional edges.
+func (m *UpgradeMatrix) GetHops(from *semver.Version, to *semver.Version) (int, int, error) {
+	i, err := m.indexOf(from)
+	if err != nil {
+		return Unreachable, Unreachable, err
+	}
+	j, err := m.indexOf(to)
+	if err != nil {
+		return Unreachable, Unreachable, err
+	}
+	return m.Hops[i][j], m.HopsWithRisks[i][j], nil
+}
+
+func (m *UpgradeMatrix) indexOf(ver *semver.Version) (int, error) {
+	for i, v := range m.Versions {
+		if v.Equal(ver) {
+			return i, nil
+		}
+	}
+	return -1, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+}
+
+// WriteCSV writes the matrix in CSV format, with a header row and column of versions.
+// Rows are the source releases and columns the target releases. Unreachable cells are left empty.
+func (m *UpgradeMatrix) WriteCSV(w io.Writer, withRisks bool) error {
+	hops := m.Hops
+	if withRisks {
+		hops = m.HopsWithRisks
+	}
+	cw := csv.NewWriter(w)
+	header := make([]string, 0, len(m.Versions)+1)
+	header = append(header, "from/to")
+	for _, v := range m.Versions {
+		header = append(header, v.String())
+	}
+	if err := cw.Write(header); err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	for i, v := range m.Versions {
+		row := make([]string, 0, len(m.Versions)+1)
+		row = append(row, v.String())
+		for _, h := range hops[i] {
+			cell := ""
+			if h != Unreachable {
+				cell = strconv.Itoa(h)
+			}
+			row = append(row, cell)
+		}
+		if err := cw.Write(row); err != nil {
+			return libErrs.NewReleaseErr(err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	return nil
+}
+
+// WriteJSON writes the matrix in JSON format.
+func (m *UpgradeMatrix) WriteJSON(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(m); err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package release
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestUpgradeMatrix(t *testing.T) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(t, err)
+	client, err := NewReleaseClient(data, data2)
+	assert.NilError(t, err)
+
+	matrix, err := client.GetUpgradeMatrix()
+	assert.NilError(t, err)
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("getting hops between releases", func(t *testing.T) {
+			hops, hopsWithRisks, err := matrix.GetHops(semver.MustParse("4.19.0"), semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.Equal(t, hops, 1)
+			assert.Equal(t, hopsWithRisks, 1)
+
+			hops, _, err = matrix.GetHops(semver.MustParse("4.19.13"), semver.MustParse("4.20.2"))
+			assert.NilError(t, err)
+			path, err := client.GetUpdatePath(semver.MustParse("4.19.13"), semver.MustParse("4.20.2"))
+			assert.NilError(t, err)
+			assert.Equal(t, hops, len(path)-1)
+		})
+
+		t.Run("getting hops that need conditional edges", func(t *testing.T) {
+			hops, hopsWithRisks, err := matrix.GetHops(semver.MustParse("4.19.11"), semver.MustParse("4.20.2"))
+			assert.NilError(t, err)
+			assert.Equal(t, hops, Unreachable)
+			assert.Equal(t, hopsWithRisks, 2)
+		})
+
+		t.Run("getting hops from a blocked release", func(t *testing.T) {
+			hops, _, err := matrix.GetHops(semver.MustParse("4.19.7"), semver.MustParse("4.19.9"))
+			assert.NilError(t, err)
+			assert.Equal(t, hops, Unreachable)
+			hops, _, err = matrix.GetHops(semver.MustParse("4.19.7"), semver.MustParse("4.19.7"))
+			assert.NilError(t, err)
+			assert.Equal(t, hops, 0)
+		})
+
+		t.Run("modifying the matrix versions", func(t *testing.T) {
+			changed, err := client.GetUpgradeMatrix()
+			assert.NilError(t, err)
+			changed.Versions[0] = semver.MustParse("1.0.0")
+			rels, err := client.GetReleases()
+			assert.NilError(t, err)
+			assert.Equal(t, rels[0].String(), matrix.Versions[0].String())
+		})
+
+		t.Run("exporting to CSV", func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NilError(t, matrix.WriteCSV(&buf, false))
+			records, err := csv.NewReader(&buf).ReadAll()
+			assert.NilError(t, err)
+			assert.Equal(t, len(records), len(matrix.Versions)+1, "unexpected number of rows")
+			assert.Equal(t, len(records[0]), len(matrix.Versions)+1, "unexpected number of columns")
+			assert.Equal(t, records[0][0], "from/to")
+			assert.Equal(t, records[1][0], matrix.Versions[0].String())
+			assert.Equal(t, records[1][1], "0")
+		})
+
+		t.Run("exporting to JSON", func(t *testing.T) {
+			var buf bytes.Buffer
+			assert.NilError(t, matrix.WriteJSON(&buf))
+			var decoded UpgradeMatrix
+			assert.NilError(t, json.Unmarshal(buf.Bytes(), &decoded))
+			assert.Equal(t, len(decoded.Versions), len(matrix.Versions))
+			assert.DeepEqual(t, decoded.Hops, matrix.Hops)
+			assert.DeepEqual(t, decoded.HopsWithRisks, matrix.HopsWithRisks)
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("release version is not valid", func(t *testing.T) {
+			_, _, err := matrix.GetHops(semver.MustParse("4.21.1"), semver.MustParse("4.19.1"))
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+	})
+}
@@ -0,0 +1,184 @@
+package release
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// MirrorMode selects which releases of a version range are mirrored.
+type MirrorMode int
+
+const (
+	// ShortestPathMode selects the releases in the shortest update path of the range.
+	ShortestPathMode MirrorMode = iota
+	// FullRangeMode selects all the releases in the range.
+	FullRangeMode
+	// HeadsOnlyMode selects the latest z-stream release of each minor in the range.
+	HeadsOnlyMode
+)
+
+// MirrorRange is a version range to mirror.
+// If `Channel` is set, only releases in that channel are considered for the range, and
+// a missing `From` or `To` defaults to the oldest or newest release in the channel.
+type MirrorRange struct {
+	From    *semver.Version
+	To      *semver.Version
+	Channel string
+}
+
+// MirrorSetOptions is used to configure the mirror set computation.
+type MirrorSetOptions struct {
+	Mode MirrorMode
+	// IncludeHeads adds the latest z-stream release of each minor in the range.
+	IncludeHeads bool
+	// WithRisks allows conditional edges in ShortestPathMode.
+	WithRisks bool
+}
+
+// ReleasePayload is a release version and its payload image.
+type ReleasePayload struct {
+	Version *semver.Version
+	Arch    Architecture
+	Payload string
+	Digest  digest.Digest
+}
+
+// GetMirrorSet returns the release payloads that must be mirrored for the given ranges,
+// deduplicated and sorted by version and architecture.
+// Ranges are computed independently for each architecture in the client.
+func (c *ReleaseClient) GetMirrorSet(ranges []MirrorRange, opts MirrorSetOptions) ([]ReleasePayload, error) {
+	payloads := []ReleasePayload{}
+	for _, arch := range c.GetArchitectures() {
+		view, err := c.ForArch(arch)
+		if err != nil {
+			return nil, err
+		}
+		archPayloads, err := view.getMirrorSet(ranges, opts)
+		if err != nil {
+			return nil, err
+		}
+		for i := range archPayloads {
+			archPayloads[i].Arch = arch
+		}
+		payloads = append(payloads, archPayloads...)
+	}
+	slices.SortFunc(payloads, func(a, b ReleasePayload) int {
+		if c := a.Version.Compare(b.Version); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.Arch), string(b.Arch))
+	})
+	return payloads, nil
+}
+
+func (c *ReleaseClient) getMirrorSet(ranges []MirrorRange, opts MirrorSetOptions) ([]ReleasePayload, error) {
+	selected := map[string]*semver.Version{}
+	for _, r := range ranges {
+		vers, err := c.selectRange(r, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vers {
+			selected[v.String()] = v
+		}
+	}
+
+	payloads := make([]ReleasePayload, 0, len(selected))
+	for _, v := range selected {
+		payload, err := c.GetPayload(v)
+		if err != nil {
+			return nil, err
+		}
+		dgst, err := payloadDigest(payload)
+		if err != nil {
+			return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: release %s: %w", libErrs.ErrInvalidGraphData, v, err))
+		}
+		payloads = append(payloads, ReleasePayload{Version: v, Payload: payload, Digest: dgst})
+	}
+	return payloads, nil
+}
+
+// payloadDigest returns the digest of a payload pull spec, which must be referenced by digest.
+func payloadDigest(payload string) (digest.Digest, error) {
+	named, err := reference.ParseNormalizedNamed(payload)
+	if err != nil {
+		return "", err
+	}
+	canonical, ok := named.(reference.Canonical)
+	if !ok {
+		return "", fmt.Errorf("payload %s is not referenced by digest", payload)
+	}
+	return canonical.Digest(), nil
+}
+
+func (c *ReleaseClient) selectRange(r MirrorRange, opts MirrorSetOptions) ([]*semver.Version, error) {
+	candidates, err := c.rangeCandidates(r)
+	if err != nil {
+		return nil, err
+	}
+	from, to := r.From, r.To
+	if from == nil {
+		from = candidates[0]
+	}
+	if to == nil {
+		to = candidates[len(candidates)-1]
+	}
+	if from.GreaterThan(to) {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: %s > %s", libErrs.ErrInvalidRange, from, to))
+	}
+	candidates = slices.DeleteFunc(candidates, func(v *semver.Version) bool {
+		return v.LessThan(from) || v.GreaterThan(to)
+	})
+
+	var vers []*semver.Version
+	switch opts.Mode {
+	case ShortestPathMode:
+		if opts.WithRisks {
+			vers, err = c.GetUpdatePathWithRisks(from, to)
+		} else {
+			vers, err = c.GetUpdatePath(from, to)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case FullRangeMode:
+		vers = candidates
+	case HeadsOnlyMode:
+		vers = []*semver.Version{to}
+	default:
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("unknown mirror mode %d", opts.Mode))
+	}
+	if opts.IncludeHeads || opts.Mode == HeadsOnlyMode {
+		vers = append(vers, minorHeads(candidates)...)
+	}
+	return vers, nil
+}
+
+// rangeCandidates returns the sorted releases that can be selected for `r`.
+func (c *ReleaseClient) rangeCandidates(r MirrorRange) ([]*semver.Version, error) {
+	if r.Channel != "" {
+		return c.GetReleasesInChannel(r.Channel)
+	}
+	if r.From == nil || r.To == nil {
+		return nil, libErrs.NewReleaseErr(fmt.Errorf("%w: missing bounds without channel", libErrs.ErrInvalidRange))
+	}
+	return c.GetReleases()
+}
+
+// minorHeads returns the latest release of each minor version in the sorted slice `vers`.
+func minorHeads(vers []*semver.Version) []*semver.Version {
+	heads := []*semver.Version{}
+	for i, v := range vers {
+		if i == len(vers)-1 || vers[i+1].Major() != v.Major() || vers[i+1].Minor() != v.Minor() {
+			heads = append(heads, v)
+		}
+	}
+	return heads
+}
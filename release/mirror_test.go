@@ -0,0 +1,108 @@
+package release
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func payloadVersions(p []ReleasePayload) []*semver.Version {
+	return common.Map(p, func(r ReleasePayload) *semver.Version { return r.Version })
+}
+
+func TestMirrorSet(t *testing.T) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+
+	client, err := NewReleaseClient(data)
+	assert.NilError(t, err)
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("selecting the shortest path", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.19.0"), To: semver.MustParse("4.19.1")}}
+			payloads, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: ShortestPathMode})
+			assert.NilError(t, err)
+			assert.Assert(t, equalVersions(payloadVersions(payloads), []string{"4.19.0", "4.19.1"}))
+			assert.Equal(t, payloads[1].Payload, "quay.io/openshift-release-dev/ocp-release@sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196")
+			assert.Equal(t, payloads[1].Digest.String(), "sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196")
+		})
+
+		t.Run("selecting a full range in a channel", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.19.0"), To: semver.MustParse("4.19.5"), Channel: "stable-4.19"}}
+			payloads, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: FullRangeMode})
+			assert.NilError(t, err)
+			assert.Assert(t, equalVersions(payloadVersions(payloads), []string{"4.19.0", "4.19.1", "4.19.2", "4.19.3", "4.19.4", "4.19.5"}))
+		})
+
+		t.Run("selecting heads only in a channel", func(t *testing.T) {
+			ranges := []MirrorRange{{Channel: "stable-4.19"}}
+			payloads, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: HeadsOnlyMode})
+			assert.NilError(t, err)
+			assert.Assert(t, equalVersions(payloadVersions(payloads), []string{"4.18.26", "4.19.17"}))
+		})
+
+		t.Run("selecting the shortest path with heads", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.18.25"), To: semver.MustParse("4.18.26")}}
+			payloads, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: ShortestPathMode, IncludeHeads: true})
+			assert.NilError(t, err)
+			assert.Assert(t, equalVersions(payloadVersions(payloads), []string{"4.18.25", "4.18.26"}))
+		})
+
+		t.Run("deduplicating overlapping ranges", func(t *testing.T) {
+			ranges := []MirrorRange{
+				{From: semver.MustParse("4.19.0"), To: semver.MustParse("4.19.2")},
+				{From: semver.MustParse("4.19.1"), To: semver.MustParse("4.19.3")},
+			}
+			payloads, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: FullRangeMode})
+			assert.NilError(t, err)
+			assert.Assert(t, equalVersions(payloadVersions(payloads), []string{"4.19.0", "4.19.1", "4.19.2", "4.19.3"}))
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("range bounds are reversed", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.19.2"), To: semver.MustParse("4.19.1")}}
+			_, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: FullRangeMode})
+			assert.ErrorIs(t, err, libErrs.ErrInvalidRange)
+		})
+
+		t.Run("range bounds are missing without a channel", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.19.2")}}
+			_, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: FullRangeMode})
+			assert.ErrorIs(t, err, libErrs.ErrInvalidRange)
+		})
+
+		t.Run("channel does not exist", func(t *testing.T) {
+			ranges := []MirrorRange{{Channel: "stable-4.99"}}
+			_, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: HeadsOnlyMode})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("no update path exists", func(t *testing.T) {
+			ranges := []MirrorRange{{From: semver.MustParse("4.18.26"), To: semver.MustParse("4.19.17")}}
+			_, err := client.GetMirrorSet(ranges, MirrorSetOptions{Mode: ShortestPathMode})
+			assert.ErrorIs(t, err, libErrs.ErrUpdateNotFound)
+		})
+
+		t.Run("a payload is not referenced by digest", func(t *testing.T) {
+			gdata, err := parseGraphData(data)
+			assert.NilError(t, err)
+			gdata.arch = AMD64
+			for i, n := range gdata.Nodes {
+				if n.Version == "4.19.1" {
+					gdata.Nodes[i].Payload = "quay.io/openshift-release-dev/ocp-release:4.19.1-x86_64"
+				}
+			}
+			tagged, err := newReleaseClient([]*GraphData{gdata})
+			assert.NilError(t, err)
+			ranges := []MirrorRange{{From: semver.MustParse("4.19.1"), To: semver.MustParse("4.19.1")}}
+			_, err = tagged.GetMirrorSet(ranges, MirrorSetOptions{Mode: FullRangeMode})
+			assert.ErrorIs(t, err, libErrs.ErrInvalidGraphData)
+		})
+	})
+}
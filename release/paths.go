@@ -0,0 +1,280 @@
+package release
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/Masterminds/semver/v3"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// Update is a direct update between two releases.
+type Update struct {
+	From *semver.Version
+	To   *semver.Version
+}
+
+// PathOptions is used to configure the search for alternative update paths.
+type PathOptions struct {
+	// K is the maximum number of paths to return. Defaults to 1.
+	K int
+	// WithRisks allows conditional edges.
+	WithRisks bool
+	// RiskWeight is the extra weight of each risk in a conditional edge. Defaults to 10.
+	RiskWeight uint64
+	// ExcludeVersions are releases that paths must not go through.
+	ExcludeVersions []*semver.Version
+	// ExcludeUpdates are direct updates that paths must not use.
+	ExcludeUpdates []Update
+	// ExcludeRisks are risk names; conditional edges with any of these risks are not used.
+	ExcludeRisks []string
+}
+
+// UpdatePath is an update path and its cost.
+type UpdatePath struct {
+	Versions []*semver.Version
+	// Weight is the number of hops plus the weight of the risks in conditional edges.
+	Weight uint64
+	// Risks are the risks of all the conditional edges in the path.
+	Risks []Risk
+}
+
+// Hops returns the number of updates in the path.
+func (p UpdatePath) Hops() int {
+	return len(p.Versions) - 1
+}
+
+type weightedEdge struct {
+	to          int
+	weight      uint64
+	conditional bool
+	risks       []Risk
+}
+
+// pathGraph is an index-based weighted update graph used for k-shortest path searches.
+type pathGraph struct {
+	versions []*semver.Version
+	edges    [][]weightedEdge
+}
+
+// GetUpdatePaths returns up to `opts.K` distinct update paths between two releases, ranked by weight
+// and then by number of hops. Paths are loopless and computed with Yen's algorithm.
+func (c *ReleaseClient) GetUpdatePaths(from *semver.Version, to *semver.Version, opts PathOptions) ([]UpdatePath, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
+	for _, ver := range []*semver.Version{from, to} {
+		if _, ok := c.versions[ver.String()]; !ok {
+			return nil, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+		}
+	}
+	if opts.K <= 0 {
+		opts.K = 1
+	}
+	if opts.RiskWeight == 0 {
+		opts.RiskWeight = conditionalPenalty
+	}
+
+	g := c.buildPathGraph(opts)
+	src := slices.IndexFunc(g.versions, from.Equal)
+	dst := slices.IndexFunc(g.versions, to.Equal)
+	removedNodes := make([]bool, len(g.versions))
+	for _, ver := range opts.ExcludeVersions {
+		if idx := slices.IndexFunc(g.versions, ver.Equal); idx != -1 {
+			removedNodes[idx] = true
+		}
+	}
+	if removedNodes[src] || removedNodes[dst] {
+		return nil, libErrs.NewReleaseErr(libErrs.ErrUpdateNotFound)
+	}
+
+	first, ok := g.shortest(src, dst, removedNodes, nil)
+	if !ok {
+		return nil, libErrs.NewReleaseErr(libErrs.ErrUpdateNotFound)
+	}
+	found := [][]int{first}
+	candidates := [][]int{}
+	for len(found) < opts.K {
+		last := found[len(found)-1]
+		for i := 0; i < len(last)-1; i++ {
+			spur, root := last[i], last[:i+1]
+			removedEdges := map[[2]int]bool{}
+			for _, p := range found {
+				if len(p) > i && slices.Equal(p[:i+1], root) {
+					removedEdges[[2]int{p[i], p[i+1]}] = true
+				}
+			}
+			removed := slices.Clone(removedNodes)
+			for _, n := range root[:i] {
+				removed[n] = true
+			}
+			spurPath, ok := g.shortest(spur, dst, removed, removedEdges)
+			if !ok {
+				continue
+			}
+			path := append(slices.Clone(root[:i]), spurPath...)
+			if !slices.ContainsFunc(candidates, func(p []int) bool { return slices.Equal(p, path) }) &&
+				!slices.ContainsFunc(found, func(p []int) bool { return slices.Equal(p, path) }) {
+				candidates = append(candidates, path)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		slices.SortStableFunc(candidates, g.comparePaths)
+		found = append(found, candidates[0])
+		candidates = candidates[1:]
+	}
+
+	return toUpdatePaths(g, found), nil
+}
+
+// toUpdatePaths converts index-based paths into update paths.
+func toUpdatePaths(g *pathGraph, paths [][]int) []UpdatePath {
+	res := make([]UpdatePath, 0, len(paths))
+	for _, p := range paths {
+		up := UpdatePath{Versions: make([]*semver.Version, 0, len(p)), Risks: []Risk{}}
+		for i, n := range p {
+			up.Versions = append(up.Versions, g.versions[n])
+			if i == 0 {
+				continue
+			}
+			e := g.edge(p[i-1], n)
+			up.Weight += e.weight
+			up.Risks = append(up.Risks, e.risks...)
+		}
+		res = append(res, up)
+	}
+	return res
+}
+
+// buildPathGraph builds the weighted update graph, without the excluded updates and risks.
+func (c *ReleaseClient) buildPathGraph(opts PathOptions) *pathGraph {
+	index := make(map[string]int, len(c.releases))
+	for i, v := range c.releases {
+		index[v.String()] = i
+	}
+	excluded := map[[2]int]bool{}
+	for _, u := range opts.ExcludeUpdates {
+		from, okFrom := index[u.From.String()]
+		to, okTo := index[u.To.String()]
+		if okFrom && okTo {
+			excluded[[2]int{from, to}] = true
+		}
+	}
+
+	type pair = [2]int
+	edges := map[pair]*weightedEdge{}
+	for _, gdata := range c.data {
+		for _, e := range gdata.Edges {
+			p := pair{index[gdata.Nodes[e[0]].Version], index[gdata.Nodes[e[1]].Version]}
+			edges[p] = &weightedEdge{to: p[1], weight: 1}
+		}
+	}
+	if opts.WithRisks {
+		condRisks := map[pair][]Risk{}
+		for _, gdata := range c.data {
+			for _, ce := range gdata.CondEdges {
+				for _, e := range ce.Edges {
+					from, okFrom := index[e.From]
+					to, okTo := index[e.To]
+					if !okFrom || !okTo {
+						continue
+					}
+					p := pair{from, to}
+					for _, r := range ce.Risks {
+						if !slices.ContainsFunc(condRisks[p], func(o Risk) bool { return o.Name == r.Name }) {
+							condRisks[p] = append(condRisks[p], r)
+						}
+					}
+				}
+			}
+		}
+		for p, risks := range condRisks {
+			if _, ok := edges[p]; ok {
+				continue
+			}
+			if slices.ContainsFunc(risks, func(r Risk) bool { return slices.Contains(opts.ExcludeRisks, r.Name) }) {
+				continue
+			}
+			edges[p] = &weightedEdge{to: p[1], weight: 1 + opts.RiskWeight*uint64(len(risks)), conditional: true, risks: risks}
+		}
+	}
+
+	g := &pathGraph{versions: c.releases, edges: make([][]weightedEdge, len(c.releases))}
+	for p, e := range edges {
+		if !excluded[p] {
+			g.edges[p[0]] = append(g.edges[p[0]], *e)
+		}
+	}
+	// Sort edges so that ties are always broken the same way.
+	for i := range g.edges {
+		slices.SortFunc(g.edges[i], func(a, b weightedEdge) int { return cmp.Compare(a.to, b.to) })
+	}
+	return g
+}
+
+func (g *pathGraph) edge(from, to int) weightedEdge {
+	idx := slices.IndexFunc(g.edges[from], func(e weightedEdge) bool { return e.to == to })
+	return g.edges[from][idx]
+}
+
+func (g *pathGraph) weight(path []int) uint64 {
+	var w uint64
+	for i := 1; i < len(path); i++ {
+		w += g.edge(path[i-1], path[i]).weight
+	}
+	return w
+}
+
+// comparePaths orders paths by weight, number of hops and versions.
+func (g *pathGraph) comparePaths(a, b []int) int {
+	return cmp.Or(
+		cmp.Compare(g.weight(a), g.weight(b)),
+		cmp.Compare(len(a), len(b)),
+		slices.Compare(a, b),
+	)
+}
+
+// shortest runs Dijkstra's algorithm from `src` to `dst`, skipping removed nodes and edges.
+func (g *pathGraph) shortest(src, dst int, removedNodes []bool, removedEdges map[[2]int]bool) ([]int, bool) {
+	const infinity = ^uint64(0)
+	n := len(g.versions)
+	dist := make([]uint64, n)
+	prev := make([]int, n)
+	done := make([]bool, n)
+	for i := range dist {
+		dist[i], prev[i] = infinity, -1
+	}
+	dist[src] = 0
+	for {
+		cur := -1
+		for i := range n {
+			if !done[i] && dist[i] != infinity && (cur == -1 || dist[i] < dist[cur]) {
+				cur = i
+			}
+		}
+		if cur == -1 || cur == dst {
+			break
+		}
+		done[cur] = true
+		for _, e := range g.edges[cur] {
+			if removedNodes[e.to] || removedEdges[[2]int{cur, e.to}] {
+				continue
+			}
+			if d := dist[cur] + e.weight; d < dist[e.to] {
+				dist[e.to], prev[e.to] = d, cur
+			}
+		}
+	}
+	if dist[dst] == infinity {
+		return nil, false
+	}
+	path := []int{}
+	for cur := dst; cur != -1; cur = prev[cur] {
+		path = append(path, cur)
+	}
+	slices.Reverse(path)
+	return path, true
+}
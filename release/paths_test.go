@@ -0,0 +1,115 @@
+package release
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func TestUpdatePaths(t *testing.T) {
+	data, err := os.ReadFile(valid419GraphData)
+	assert.NilError(t, err)
+	data2, err := os.ReadFile(valid420GraphData)
+	assert.NilError(t, err)
+	client, err := NewReleaseClient(data, data2)
+	assert.NilError(t, err)
+
+	srcVer := semver.MustParse("4.19.13")
+	tgtVer := semver.MustParse("4.20.2")
+
+	t.Run("should succeed when", func(t *testing.T) {
+		t.Run("getting the k shortest paths", func(t *testing.T) {
+			paths, err := client.GetUpdatePaths(srcVer, tgtVer, PathOptions{K: 3})
+			assert.NilError(t, err)
+			assert.Equal(t, len(paths), 3, "unexpected number of paths")
+			assert.Assert(t, equalVersions(paths[0].Versions, []string{"4.19.13", "4.19.17", "4.20.0", "4.20.2"}))
+			assert.Equal(t, paths[0].Hops(), 3)
+			for i := 1; i < len(paths); i++ {
+				assert.Assert(t, paths[i-1].Weight <= paths[i].Weight, "paths not sorted by weight")
+				assert.Assert(t, !slices.EqualFunc(paths[i-1].Versions, paths[i].Versions, (*semver.Version).Equal))
+			}
+		})
+
+		t.Run("getting paths with conditional edges", func(t *testing.T) {
+			src := semver.MustParse("4.19.11")
+			paths, err := client.GetUpdatePaths(src, tgtVer, PathOptions{K: 5, WithRisks: true})
+			assert.NilError(t, err)
+			assert.Equal(t, len(paths), 5, "unexpected number of paths")
+			assert.Assert(t, equalVersions(paths[0].Versions, []string{"4.19.11", "4.19.17", "4.20.0", "4.20.2"}))
+			assert.Equal(t, len(paths[0].Risks), 1, "unexpected number of risks")
+			assert.Equal(t, paths[0].Weight, uint64(3+conditionalPenalty))
+		})
+
+		t.Run("excluding a version", func(t *testing.T) {
+			excluded := semver.MustParse("4.20.0")
+			paths, err := client.GetUpdatePaths(semver.MustParse("4.19.11"), tgtVer, PathOptions{
+				K:               3,
+				WithRisks:       true,
+				ExcludeVersions: []*semver.Version{excluded},
+			})
+			assert.NilError(t, err)
+			for _, p := range paths {
+				assert.Assert(t, !slices.ContainsFunc(p.Versions, excluded.Equal), "excluded version in path")
+			}
+		})
+
+		t.Run("excluding an update", func(t *testing.T) {
+			paths, err := client.GetUpdatePaths(srcVer, tgtVer, PathOptions{
+				ExcludeUpdates: []Update{{From: semver.MustParse("4.20.0"), To: tgtVer}},
+			})
+			assert.NilError(t, err)
+			assert.Equal(t, len(paths), 1, "unexpected number of paths")
+			assert.Assert(t, equalVersions(paths[0].Versions, []string{"4.19.13", "4.19.17", "4.20.0", "4.20.1", "4.20.2"}))
+		})
+
+		t.Run("excluding a risk", func(t *testing.T) {
+			src := semver.MustParse("4.19.11")
+			risks, err := client.GetRisks(semver.MustParse("4.19.16"), tgtVer)
+			assert.NilError(t, err)
+			paths, err := client.GetUpdatePaths(src, tgtVer, PathOptions{
+				K:            5,
+				WithRisks:    true,
+				ExcludeRisks: []string{risks[0].Name},
+			})
+			assert.NilError(t, err)
+			for _, p := range paths {
+				assert.Assert(t, !slices.ContainsFunc(p.Risks, func(r Risk) bool { return r.Name == risks[0].Name }))
+			}
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		t.Run("release version is not valid", func(t *testing.T) {
+			_, err := client.GetUpdatePaths(semver.MustParse("4.21.1"), tgtVer, PathOptions{})
+			assert.ErrorIs(t, err, libErrs.ErrNotFound)
+		})
+
+		t.Run("no path exists without conditional edges", func(t *testing.T) {
+			_, err := client.GetUpdatePaths(semver.MustParse("4.19.11"), tgtVer, PathOptions{K: 2})
+			assert.ErrorIs(t, err, libErrs.ErrUpdateNotFound)
+		})
+
+		t.Run("all conditional edges have an excluded risk", func(t *testing.T) {
+			src := semver.MustParse("4.19.11")
+			risks, err := client.GetRisks(src, semver.MustParse("4.19.17"))
+			assert.NilError(t, err)
+			_, err = client.GetUpdatePaths(src, tgtVer, PathOptions{
+				WithRisks:    true,
+				ExcludeRisks: []string{risks[0].Name},
+			})
+			assert.ErrorIs(t, err, libErrs.ErrUpdateNotFound)
+		})
+
+		t.Run("all paths go through an excluded version", func(t *testing.T) {
+			_, err := client.GetUpdatePaths(srcVer, tgtVer, PathOptions{
+				ExcludeVersions: []*semver.Version{semver.MustParse("4.19.17")},
+			})
+			assert.ErrorIs(t, err, libErrs.ErrUpdateNotFound)
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package release
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+	"github.com/r4f4/oc-mirror-libs/render"
+)
+
+// GraphOptions is used to select the update graph to render.
+type GraphOptions struct {
+	// From restricts the graph to releases reachable from this version.
+	From *semver.Version
+	// To restricts the graph to releases that can reach this version.
+	To *semver.Version
+	// WithRisks includes conditional edges, labeled with their risk names.
+	WithRisks bool
+}
+
+// GetGraph returns the merged update graph, or the subgraph between two releases, for rendering.
+// Releases without updates from them are styled as channel heads, and releases with only conditional
+// updates from them are styled as blocked.
+func (c *ReleaseClient) GetGraph(opts GraphOptions) (*render.Graph, error) {
+	if err := c.singleArch(); err != nil {
+		return nil, err
+	}
+	g := c.buildPathGraph(PathOptions{WithRisks: opts.WithRisks, RiskWeight: 1})
+	// NOTE: styles don't depend on the rendered edges, so blocked releases are the same with or without risks.
+	full := g
+	if !opts.WithRisks {
+		full = c.buildPathGraph(PathOptions{WithRisks: true, RiskWeight: 1})
+	}
+	keep := make([]bool, len(g.versions))
+	for i := range keep {
+		keep[i] = true
+	}
+	if opts.From != nil {
+		reachable, err := g.reachable(opts.From, false)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keep {
+			keep[i] = keep[i] && reachable[i]
+		}
+	}
+	if opts.To != nil {
+		reachable, err := g.reachable(opts.To, true)
+		if err != nil {
+			return nil, err
+		}
+		for i := range keep {
+			keep[i] = keep[i] && reachable[i]
+		}
+	}
+
+	name := "releases"
+	if opts.From != nil || opts.To != nil {
+		name = fmt.Sprintf("releases %s-%s", optionalVersion(opts.From), optionalVersion(opts.To))
+	}
+	graph := &render.Graph{Name: name}
+	for i, ver := range g.versions {
+		if !keep[i] {
+			continue
+		}
+		node := render.Node{ID: ver.String()}
+		switch {
+		case len(full.edges[i]) == 0:
+			node.Style = render.HeadNode
+		case !slices.ContainsFunc(full.edges[i], func(e weightedEdge) bool { return !e.conditional }):
+			node.Style = render.BlockedNode
+		}
+		graph.Nodes = append(graph.Nodes, node)
+		for _, e := range g.edges[i] {
+			if !keep[e.to] {
+				continue
+			}
+			edge := render.Edge{From: ver.String(), To: g.versions[e.to].String()}
+			if e.conditional {
+				edge.Style = render.DashedEdge
+				edge.Label = strings.Join(common.Map(e.risks, func(r Risk) string { return r.Name }), ", ")
+			}
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	return graph, nil
+}
+
+func optionalVersion(ver *semver.Version) string {
+	if ver == nil {
+		return "*"
+	}
+	return ver.String()
+}
+
+// reachable returns the nodes reachable from `ver`, or the nodes that can reach `ver` if `reverse` is set.
+func (g *pathGraph) reachable(ver *semver.Version, reverse bool) ([]bool, error) {
+	start := -1
+	for i, v := range g.versions {
+		if v.Equal(ver) {
+			start = i
+		}
+	}
+	if start == -1 {
+		return nil, libErrs.NewReleaseErr(&libErrs.VersionNotFoundError{Version: ver.String()})
+	}
+	adjacency := make([][]int, len(g.versions))
+	for from, edges := range g.edges {
+		for _, e := range edges {
+			if reverse {
+				adjacency[e.to] = append(adjacency[e.to], from)
+			} else {
+				adjacency[from] = append(adjacency[from], e.to)
+			}
+		}
+	}
+	seen := make([]bool, len(g.versions))
+	seen[start] = true
+	queue := []int{start}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacency[cur] {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen, nil
+}
@@ -0,0 +1,273 @@
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/signature"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const (
+	// OCPSignatureStoreURL is the signature store for OCP release payloads.
+	OCPSignatureStoreURL string = "https://mirror.openshift.com/pub/openshift-v4/signatures/openshift/release"
+	// SignatureConfigMapNamespace is the namespace where CVO looks for release signatures.
+	SignatureConfigMapNamespace string = "openshift-config-managed"
+	// SignatureConfigMapLabel marks config maps containing release signatures.
+	SignatureConfigMapLabel string = "release.openshift.io/verification-signatures"
+)
+
+// maxSignatures bounds the number of signatures looked up for a single digest.
+const maxSignatures = 100
+
+// SignatureStore gives access to the signatures of release payloads.
+// Stores use the layout `sha256=<hex>/signature-<n>`, with `n` starting at 1.
+type SignatureStore interface {
+	// GetSignatures returns the signatures for the given payload digest.
+	// An empty list is returned if there are no signatures.
+	GetSignatures(ctx context.Context, dgst digest.Digest) ([][]byte, error)
+}
+
+// HTTPSignatureStore is a signature store served over HTTP(S).
+type HTTPSignatureStore struct {
+	Client *http.Client
+	// URL is the base URL of the store. Defaults to OCPSignatureStoreURL.
+	URL string
+}
+
+var _ SignatureStore = (*HTTPSignatureStore)(nil)
+
+// GetSignatures implements SignatureStore.
+func (s *HTTPSignatureStore) GetSignatures(ctx context.Context, dgst digest.Digest) ([][]byte, error) {
+	baseURL := s.URL
+	if baseURL == "" {
+		baseURL = OCPSignatureStoreURL
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	sigs := [][]byte{}
+	for i := 1; i <= maxSignatures; i++ {
+		sigURL := fmt.Sprintf("%s/%s/signature-%d", strings.TrimSuffix(baseURL, "/"), signatureDir(dgst), i)
+		logger.Debug("download release signature", slog.String("GET", sigURL))
+		data, found, err := getSignature(ctx, client, sigURL)
+		if err != nil {
+			return nil, libErrs.NewReleaseErr(err)
+		}
+		if !found {
+			break
+		}
+		sigs = append(sigs, data)
+	}
+	return sigs, nil
+}
+
+func getSignature(ctx context.Context, client *http.Client, sigURL string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sigURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch status := resp.StatusCode; status {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, &libErrs.HTTPStatusError{StatusCode: status, URL: req.URL.Redacted()}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// DirSignatureStore is a signature store in a local directory.
+// It can be used for offline access to signatures previously saved with PutSignatures.
+type DirSignatureStore struct {
+	Dir string
+}
+
+var _ SignatureStore = (*DirSignatureStore)(nil)
+
+// GetSignatures implements SignatureStore.
+func (s *DirSignatureStore) GetSignatures(_ context.Context, dgst digest.Digest) ([][]byte, error) {
+	sigs := [][]byte{}
+	for i := 1; i <= maxSignatures; i++ {
+		data, err := os.ReadFile(filepath.Join(s.Dir, signatureDir(dgst), fmt.Sprintf("signature-%d", i)))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, libErrs.NewReleaseErr(err)
+		}
+		sigs = append(sigs, data)
+	}
+	return sigs, nil
+}
+
+// PutSignatures saves the signatures for the given payload digest, replacing existing ones.
+func (s *DirSignatureStore) PutSignatures(dgst digest.Digest, sigs [][]byte) error {
+	dir := filepath.Join(s.Dir, signatureDir(dgst))
+	if err := os.RemoveAll(dir); err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return libErrs.NewReleaseErr(err)
+	}
+	for i, sig := range sigs {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("signature-%d", i+1)), sig, 0o644); err != nil {
+			return libErrs.NewReleaseErr(err)
+		}
+	}
+	return nil
+}
+
+func signatureDir(dgst digest.Digest) string {
+	return fmt.Sprintf("%s=%s", dgst.Algorithm(), dgst.Encoded())
+}
+
+// SignatureOptions is used to configure release signature retrieval.
+type SignatureOptions struct {
+	Store SignatureStore
+	// Keyring is the GPG public keyring, armored or binary, used to verify the signatures.
+	Keyring []byte
+}
+
+// ReleaseSignatures contains the verified signatures of a release payload.
+type ReleaseSignatures struct {
+	Version    *semver.Version
+	Payload    string
+	Digest     digest.Digest
+	Signatures [][]byte
+}
+
+// signatureContents is the part of an "atomic container signature" needed to match the payload.
+type signatureContents struct {
+	Critical struct {
+		Type  string `json:"type"`
+		Image struct {
+			DockerManifestDigest digest.Digest `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// GetReleaseSignatures returns the verified signatures of the payloads for the given versions.
+// Signatures that can't be verified with the keyring or that sign a different digest are dropped.
+// An error is returned if a payload has no valid signature.
+func (c *ReleaseClient) GetReleaseSignatures(ctx context.Context, opts SignatureOptions, versions ...*semver.Version) ([]ReleaseSignatures, error) {
+	if opts.Store == nil {
+		return nil, newSignatureErr(errors.New("no signature store provided"))
+	}
+	mech, keyIDs, err := signature.NewEphemeralGPGSigningMechanism(opts.Keyring)
+	if err != nil {
+		return nil, newSignatureErr(fmt.Errorf("load keyring: %w", err))
+	}
+	defer func() { _ = mech.Close() }()
+	if len(keyIDs) == 0 {
+		return nil, newSignatureErr(errors.New("no keys found in keyring"))
+	}
+
+	res := make([]ReleaseSignatures, 0, len(versions))
+	for _, ver := range versions {
+		payload, err := c.GetPayload(ver)
+		if err != nil {
+			return nil, err
+		}
+		_, dgstStr, _ := strings.Cut(payload, "@")
+		dgst, err := digest.Parse(dgstStr)
+		if err != nil {
+			return nil, newSignatureErr(fmt.Errorf("release %s payload %s: %w", ver, payload, err))
+		}
+
+		unverified, err := opts.Store.GetSignatures(ctx, dgst)
+		if err != nil {
+			return nil, err
+		}
+		sigs := [][]byte{}
+		for i, sig := range unverified {
+			if err := verifySignature(mech, keyIDs, sig, dgst); err != nil {
+				logger.Warn("dropping release signature",
+					slog.String("version", ver.String()), slog.Int("index", i+1), slog.Any("error", err))
+				continue
+			}
+			sigs = append(sigs, sig)
+		}
+		if len(sigs) == 0 {
+			return nil, newSignatureErr(fmt.Errorf("release %s (%s) valid signatures %w", ver, dgst, libErrs.ErrNotFound))
+		}
+		res = append(res, ReleaseSignatures{Version: ver, Payload: payload, Digest: dgst, Signatures: sigs})
+	}
+	return res, nil
+}
+
+// verifySignature checks that `sig` is signed by one of `keyIDs` and that it signs `dgst`.
+func verifySignature(mech signature.SigningMechanism, keyIDs []string, sig []byte, dgst digest.Digest) error {
+	contents, keyID, err := mech.Verify(sig)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(keyIDs, keyID) {
+		return fmt.Errorf("signature by unexpected key %s", keyID)
+	}
+
+	var sc signatureContents
+	if err := json.Unmarshal(contents, &sc); err != nil {
+		return fmt.Errorf("parse signature: %w", err)
+	}
+	if sc.Critical.Type != "atomic container signature" {
+		return fmt.Errorf("unexpected signature type %q", sc.Critical.Type)
+	}
+	if sc.Critical.Image.DockerManifestDigest != dgst {
+		return fmt.Errorf("signature for digest %s", sc.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+// SignatureConfigMaps returns the config maps CVO uses to verify the release payloads in disconnected clusters.
+func SignatureConfigMaps(sigs []ReleaseSignatures) []corev1.ConfigMap {
+	cms := make([]corev1.ConfigMap, 0, len(sigs))
+	for _, rs := range sigs {
+		name := fmt.Sprintf("%s-%s", rs.Digest.Algorithm(), rs.Digest.Encoded())
+		cm := corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: SignatureConfigMapNamespace,
+				Labels:    map[string]string{SignatureConfigMapLabel: ""},
+			},
+			BinaryData: make(map[string][]byte, len(rs.Signatures)),
+		}
+		for i, sig := range rs.Signatures {
+			cm.BinaryData[fmt.Sprintf("%s-%d", name, i+1)] = sig
+		}
+		cms = append(cms, cm)
+	}
+	return cms
+}
+
+func newSignatureErr(err error) *libErrs.Error {
+	return libErrs.NewReleaseErr(fmt.Errorf("%w: %w", libErrs.ErrVerifySignature, err))
+}
@@ -0,0 +1,137 @@
+package release
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+
+	"github.com/r4f4/oc-mirror-libs/common"
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const (
+	signatureKeyring = "../testdata/signatures/public-key.gpg"
+	signatureStore   = "../testdata/signatures/store"
+	// signedDigest has a valid signature in the test store.
+	signedDigest = "sha256:20bf21ed457b390829cdbeec8795a7bea1626991fda603e0d01b4e7f60427e55"
+)
+
+func newSignatureClient(t *testing.T) *ReleaseClient {
+	payloads := map[string]string{
+		// valid signature, and one with an unknown key
+		"4.19.1": signedDigest,
+		// valid signature for another digest
+		"4.19.2": "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+		// signature with an unknown key
+		"4.19.3": "sha256:2222222222222222222222222222222222222222222222222222222222222222",
+		// no signatures
+		"4.19.4": "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+	}
+	var nodes []string
+	for _, v := range []string{"4.19.1", "4.19.2", "4.19.3", "4.19.4"} {
+		nodes = append(nodes, fmt.Sprintf(`{"version":%q,"payload":"quay.io/openshift-release-dev/ocp-release@%s","metadata":{}}`, v, payloads[v]))
+	}
+	data := fmt.Sprintf(`{"version":1,"nodes":[%s,%s,%s,%s],"edges":[[0,1],[1,2],[2,3]],"conditionalEdges":[]}`,
+		nodes[0], nodes[1], nodes[2], nodes[3])
+	client, err := NewReleaseClient([]byte(data))
+	assert.NilError(t, err)
+	return client
+}
+
+func TestGetReleaseSignatures(t *testing.T) {
+	client := newSignatureClient(t)
+	keyring, err := os.ReadFile(signatureKeyring)
+	assert.NilError(t, err)
+	server := httptest.NewServer(http.FileServer(http.Dir(signatureStore)))
+	defer server.Close()
+
+	stores := map[string]SignatureStore{
+		"dir":  &DirSignatureStore{Dir: signatureStore},
+		"http": &HTTPSignatureStore{URL: server.URL},
+	}
+
+	t.Run("should succeed when", func(t *testing.T) {
+		for name, store := range stores {
+			t.Run("loading signatures from a "+name+" store", func(t *testing.T) {
+				sigs, err := client.GetReleaseSignatures(context.Background(),
+					SignatureOptions{Store: store, Keyring: keyring}, semver.MustParse("4.19.1"))
+				assert.NilError(t, err)
+				assert.Equal(t, len(sigs), 1)
+				assert.Equal(t, sigs[0].Digest, digest.Digest(signedDigest))
+				assert.Equal(t, len(sigs[0].Signatures), 1, "expected the unknown key signature to be dropped")
+			})
+		}
+
+		t.Run("saving signatures for offline use", func(t *testing.T) {
+			sigs, err := client.GetReleaseSignatures(context.Background(),
+				SignatureOptions{Store: stores["http"], Keyring: keyring}, semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+
+			offline := &DirSignatureStore{Dir: t.TempDir()}
+			assert.NilError(t, offline.PutSignatures(sigs[0].Digest, sigs[0].Signatures))
+			offlineSigs, err := client.GetReleaseSignatures(context.Background(),
+				SignatureOptions{Store: offline, Keyring: keyring}, semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+			assert.DeepEqual(t, offlineSigs, sigs)
+		})
+
+		t.Run("generating the config maps", func(t *testing.T) {
+			sigs, err := client.GetReleaseSignatures(context.Background(),
+				SignatureOptions{Store: stores["dir"], Keyring: keyring}, semver.MustParse("4.19.1"))
+			assert.NilError(t, err)
+
+			cms := SignatureConfigMaps(sigs)
+			assert.Equal(t, len(cms), 1)
+			name := "sha256-20bf21ed457b390829cdbeec8795a7bea1626991fda603e0d01b4e7f60427e55"
+			assert.Equal(t, cms[0].Name, name)
+			assert.Equal(t, cms[0].Namespace, SignatureConfigMapNamespace)
+			assert.Assert(t, cmp.Contains(cms[0].Labels, SignatureConfigMapLabel))
+			assert.DeepEqual(t, cms[0].BinaryData[name+"-1"], sigs[0].Signatures[0])
+
+			var buf bytes.Buffer
+			assert.NilError(t, common.WriteManifests(&buf, cms...))
+			assert.Assert(t, cmp.Contains(buf.String(), "kind: ConfigMap"))
+			assert.Assert(t, cmp.Contains(buf.String(), SignatureConfigMapLabel+`: ""`))
+		})
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			version string
+		}{
+			{name: "the signature is for another digest", version: "4.19.2"},
+			{name: "the signature is from an unknown key", version: "4.19.3"},
+			{name: "there are no signatures", version: "4.19.4"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := client.GetReleaseSignatures(context.Background(),
+					SignatureOptions{Store: stores["dir"], Keyring: keyring}, semver.MustParse(tc.version))
+				assert.Assert(t, errors.Is(err, libErrs.ErrVerifySignature))
+				assert.Assert(t, errors.Is(err, libErrs.ErrNotFound))
+			})
+		}
+
+		t.Run("the keyring is empty", func(t *testing.T) {
+			_, err := client.GetReleaseSignatures(context.Background(),
+				SignatureOptions{Store: stores["dir"]}, semver.MustParse("4.19.1"))
+			assert.Assert(t, errors.Is(err, libErrs.ErrVerifySignature))
+		})
+
+		t.Run("the release is unknown", func(t *testing.T) {
+			_, err := client.GetReleaseSignatures(context.Background(),
+				SignatureOptions{Store: stores["dir"], Keyring: keyring}, semver.MustParse("4.19.5"))
+			assert.Assert(t, errors.Is(err, libErrs.ErrNotFound))
+		})
+	})
+}
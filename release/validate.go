@@ -0,0 +1,119 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/opencontainers/go-digest"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// GraphIssue is the kind of problem found in graph data.
+type GraphIssue string
+
+const (
+	InvalidEdgeIssue    GraphIssue = "invalid edge"
+	InvalidVersionIssue GraphIssue = "invalid version"
+	DuplicateNodeIssue  GraphIssue = "duplicate node"
+	UnknownVersionIssue GraphIssue = "unknown version"
+	InvalidPayloadIssue GraphIssue = "invalid payload"
+)
+
+// GraphDataError is a problem found in graph data.
+type GraphDataError struct {
+	Issue GraphIssue
+	// Path is the location of the problem in the graph data, e.g. `nodes[3]`.
+	Path string
+	// Value is the offending value.
+	Value string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *GraphDataError) Error() string {
+	msg := fmt.Sprintf("%s: %s %q", e.Path, e.Issue, e.Value)
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *GraphDataError) Is(other error) bool {
+	return other == libErrs.ErrInvalidGraphData
+}
+
+func (e *GraphDataError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateGraphData parses and validates Cincinnati graph data.
+// All the problems found are returned joined, and can be inspected with `errors.As` as `*GraphDataError`.
+func ValidateGraphData(data []byte) error {
+	_, err := parseGraphData(data)
+	return err
+}
+
+// validate checks the graph data for problems that would break graph queries.
+func (o *GraphData) validate() error {
+	issues := []error{}
+	seen := make(map[string]int, len(o.Nodes))
+	for i, n := range o.Nodes {
+		path := fmt.Sprintf("nodes[%d]", i)
+		// Releases are looked up by their canonical version, so anything else could never be found.
+		if ver, err := semver.StrictNewVersion(n.Version); err != nil {
+			issues = append(issues, &GraphDataError{Issue: InvalidVersionIssue, Path: path, Value: n.Version, Err: err})
+		} else if ver.String() != n.Version {
+			issues = append(issues, &GraphDataError{Issue: InvalidVersionIssue, Path: path, Value: n.Version, Err: fmt.Errorf("expected %s", ver)})
+		}
+		if first, ok := seen[n.Version]; ok {
+			issues = append(issues, &GraphDataError{
+				Issue: DuplicateNodeIssue,
+				Path:  path,
+				Value: n.Version,
+				Err:   fmt.Errorf("also in nodes[%d]", first),
+			})
+		} else {
+			seen[n.Version] = i
+		}
+		if err := validatePayload(n.Payload); err != nil {
+			issues = append(issues, &GraphDataError{Issue: InvalidPayloadIssue, Path: path, Value: n.Payload, Err: err})
+		}
+	}
+	for i, edge := range o.Edges {
+		if len(edge) != 2 || !o.validIndex(edge[0]) || !o.validIndex(edge[1]) {
+			issues = append(issues, &GraphDataError{
+				Issue: InvalidEdgeIssue,
+				Path:  fmt.Sprintf("edges[%d]", i),
+				Value: fmt.Sprint(edge),
+				Err:   fmt.Errorf("graph has %d nodes", len(o.Nodes)),
+			})
+		}
+	}
+	for i, ce := range o.CondEdges {
+		for j, e := range ce.Edges {
+			path := fmt.Sprintf("conditionalEdges[%d].edges[%d]", i, j)
+			for _, ver := range []string{e.From, e.To} {
+				if _, ok := seen[ver]; !ok {
+					issues = append(issues, &GraphDataError{Issue: UnknownVersionIssue, Path: path, Value: ver})
+				}
+			}
+		}
+	}
+	return errors.Join(issues...)
+}
+
+func (o *GraphData) validIndex(idx int) bool {
+	return idx >= 0 && idx < len(o.Nodes)
+}
+
+// validatePayload checks that the payload is a pull spec by digest.
+func validatePayload(payload string) error {
+	name, dgst, found := strings.Cut(payload, "@")
+	if !found || name == "" {
+		return errors.New("payload is not referenced by digest")
+	}
+	return digest.Digest(dgst).Validate()
+}
@@ -0,0 +1,127 @@
+package release
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+const validPayload = "quay.io/openshift-release-dev/ocp-release@sha256:4d7f10e383deb0c5402f871bf66ebdcad6bb670cb3cf1668bfec5166c56f3196"
+
+func graphIssues(err error) []*GraphDataError {
+	issues := []*GraphDataError{}
+	var visit func(error)
+	visit = func(err error) {
+		switch e := err.(type) {
+		case *GraphDataError:
+			issues = append(issues, e)
+		case interface{ Unwrap() []error }:
+			for _, inner := range e.Unwrap() {
+				visit(inner)
+			}
+		case interface{ Unwrap() error }:
+			visit(e.Unwrap())
+		}
+	}
+	visit(err)
+	return issues
+}
+
+func TestValidateGraphData(t *testing.T) {
+	t.Run("should succeed with valid data", func(t *testing.T) {
+		for _, path := range []string{valid419GraphData, valid420GraphData} {
+			data, err := os.ReadFile(path)
+			assert.NilError(t, err)
+			assert.NilError(t, ValidateGraphData(data))
+		}
+	})
+
+	t.Run("should fail when", func(t *testing.T) {
+		cases := []struct {
+			name  string
+			data  string
+			issue GraphIssue
+			path  string
+		}{
+			{
+				name:  "edge index is out of range",
+				data:  `{"nodes":[{"version":"4.19.0","payload":"` + validPayload + `"}],"edges":[[0,1]]}`,
+				issue: InvalidEdgeIssue,
+				path:  "edges[0]",
+			},
+			{
+				name:  "edge is malformed",
+				data:  `{"nodes":[{"version":"4.19.0","payload":"` + validPayload + `"}],"edges":[[0]]}`,
+				issue: InvalidEdgeIssue,
+				path:  "edges[0]",
+			},
+			{
+				name:  "version is malformed",
+				data:  `{"nodes":[{"version":"4.19.x","payload":"` + validPayload + `"}]}`,
+				issue: InvalidVersionIssue,
+				path:  "nodes[0]",
+			},
+			{
+				name:  "version has a prefix",
+				data:  `{"nodes":[{"version":"v4.19.1","payload":"` + validPayload + `"}]}`,
+				issue: InvalidVersionIssue,
+				path:  "nodes[0]",
+			},
+			{
+				name:  "version has no patch",
+				data:  `{"nodes":[{"version":"4.19","payload":"` + validPayload + `"}]}`,
+				issue: InvalidVersionIssue,
+				path:  "nodes[0]",
+			},
+			{
+				name:  "node is duplicated",
+				data:  `{"nodes":[{"version":"4.19.0","payload":"` + validPayload + `"},{"version":"4.19.0","payload":"` + validPayload + `"}]}`,
+				issue: DuplicateNodeIssue,
+				path:  "nodes[1]",
+			},
+			{
+				name: "conditional edge points at unknown version",
+				data: `{"nodes":[{"version":"4.19.0","payload":"` + validPayload + `"}],` +
+					`"conditionalEdges":[{"edges":[{"from":"4.19.0","to":"4.19.1"}],"risks":[]}]}`,
+				issue: UnknownVersionIssue,
+				path:  "conditionalEdges[0].edges[0]",
+			},
+			{
+				name:  "payload is not referenced by digest",
+				data:  `{"nodes":[{"version":"4.19.0","payload":"quay.io/openshift-release-dev/ocp-release:4.19.0"}]}`,
+				issue: InvalidPayloadIssue,
+				path:  "nodes[0]",
+			},
+			{
+				name:  "payload digest is malformed",
+				data:  `{"nodes":[{"version":"4.19.0","payload":"quay.io/openshift-release-dev/ocp-release@sha256:abc"}]}`,
+				issue: InvalidPayloadIssue,
+				path:  "nodes[0]",
+			},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				err := ValidateGraphData([]byte(tc.data))
+				assert.ErrorIs(t, err, libErrs.ErrInvalidGraphData)
+				assert.ErrorIs(t, err, libErrs.ErrParseGraphData)
+				var gerr *GraphDataError
+				assert.Assert(t, errors.As(err, &gerr))
+				assert.Equal(t, gerr.Issue, tc.issue)
+				assert.Equal(t, gerr.Path, tc.path)
+
+				_, err = NewReleaseClient([]byte(tc.data))
+				assert.ErrorIs(t, err, libErrs.ErrInvalidGraphData)
+			})
+		}
+	})
+
+	t.Run("should report all issues", func(t *testing.T) {
+		data := `{"nodes":[{"version":"4.19.x","payload":"invalid"}],"edges":[[0,1],[2,0]]}`
+		err := ValidateGraphData([]byte(data))
+		assert.Equal(t, len(graphIssues(err)), 4, "unexpected number of issues")
+	})
+}
@@ -0,0 +1,148 @@
+// Package render contains renderers of update graphs to text diagram formats.
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+// EdgeStyle is the rendering style of an edge.
+type EdgeStyle int
+
+const (
+	// SolidEdge is used for regular edges.
+	SolidEdge EdgeStyle = iota
+	// DashedEdge is used for edges that are conditional or otherwise special.
+	DashedEdge
+)
+
+// NodeStyle is the rendering style of a node.
+type NodeStyle int
+
+const (
+	// PlainNode is used for regular nodes.
+	PlainNode NodeStyle = iota
+	// BlockedNode is used for nodes without updates from them, filled in red.
+	BlockedNode
+	// HeadNode is used for the head of a channel, filled in green.
+	HeadNode
+)
+
+// nodeStyles are the fill colors and Mermaid class names of the styled nodes.
+var nodeStyles = map[NodeStyle]struct{ fill, class string }{
+	BlockedNode: {fill: "#ff9999", class: "blocked"},
+	HeadNode:    {fill: "#99dd99", class: "head"},
+}
+
+// Node is a graph vertex.
+type Node struct {
+	ID    string
+	Label string
+	Style NodeStyle
+}
+
+// Edge is a directed graph edge between two node IDs.
+type Edge struct {
+	From  string
+	To    string
+	Style EdgeStyle
+	Label string
+}
+
+// Graph is a directed graph to render. Nodes and edges are rendered in order.
+type Graph struct {
+	Name  string
+	Nodes []Node
+	Edges []Edge
+}
+
+// WriteDOT writes the graph in Graphviz DOT format.
+func WriteDOT(w io.Writer, g *Graph) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(g.Name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{"label=" + dotQuote(nodeLabel(n))}
+		if style, ok := nodeStyles[n.Style]; ok {
+			attrs = append(attrs, "style=filled", "fillcolor="+dotQuote(style.fill))
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		attrs := []string{}
+		if e.Style == DashedEdge {
+			attrs = append(attrs, "style=dashed", "color=orange")
+		}
+		if e.Label != "" {
+			attrs = append(attrs, "label="+dotQuote(e.Label))
+		}
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(e.From), dotQuote(e.To))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+func WriteMermaid(w io.Writer, g *Graph) error {
+	ids := make(map[string]string, len(g.Nodes))
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	styled := map[NodeStyle][]string{}
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+		fmt.Fprintf(&b, "  %s[%s]\n", id, mermaidQuote(nodeLabel(n)))
+		if _, ok := nodeStyles[n.Style]; ok {
+			styled[n.Style] = append(styled[n.Style], id)
+		}
+	}
+	for _, e := range g.Edges {
+		from, okFrom := ids[e.From]
+		to, okTo := ids[e.To]
+		if !okFrom || !okTo {
+			return libErrs.NewErr(libErrs.ValidationErrorKind, fmt.Errorf("%w: edge %q -> %q references unknown node", libErrs.ErrRenderGraph, e.From, e.To))
+		}
+		switch {
+		case e.Style == DashedEdge && e.Label != "":
+			fmt.Fprintf(&b, "  %s -. %s .-> %s\n", from, mermaidQuote(e.Label), to)
+		case e.Style == DashedEdge:
+			fmt.Fprintf(&b, "  %s -.-> %s\n", from, to)
+		case e.Label != "":
+			fmt.Fprintf(&b, "  %s -- %s --> %s\n", from, mermaidQuote(e.Label), to)
+		default:
+			fmt.Fprintf(&b, "  %s --> %s\n", from, to)
+		}
+	}
+	for _, style := range []NodeStyle{BlockedNode, HeadNode} {
+		if nodes := styled[style]; len(nodes) > 0 {
+			fmt.Fprintf(&b, "  classDef %s fill:%s\n", nodeStyles[style].class, nodeStyles[style].fill)
+			fmt.Fprintf(&b, "  class %s %s\n", strings.Join(nodes, ","), nodeStyles[style].class)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func nodeLabel(n Node) string {
+	if n.Label != "" {
+		return n.Label
+	}
+	return n.ID
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", "<br/>").Replace(s) + `"`
+}
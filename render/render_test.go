@@ -0,0 +1,75 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+
+	libErrs "github.com/r4f4/oc-mirror-libs/errors"
+)
+
+func testGraph() *Graph {
+	return &Graph{
+		Name: "test",
+		Nodes: []Node{
+			{ID: "a"},
+			{ID: "b", Label: `b "quoted"`},
+			{ID: "c", Style: BlockedNode},
+			{ID: "d", Style: HeadNode},
+		},
+		Edges: []Edge{
+			{From: "a", To: "b"},
+			{From: "a", To: "c", Style: DashedEdge, Label: "RiskA"},
+			{From: "b", To: "d"},
+		},
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var b strings.Builder
+	assert.NilError(t, WriteDOT(&b, testGraph()))
+	expected := `digraph "test" {
+  rankdir=LR;
+  node [shape=box];
+  "a" [label="a"];
+  "b" [label="b \"quoted\""];
+  "c" [label="c", style=filled, fillcolor="#ff9999"];
+  "d" [label="d", style=filled, fillcolor="#99dd99"];
+  "a" -> "b";
+  "a" -> "c" [style=dashed, color=orange, label="RiskA"];
+  "b" -> "d";
+}
+`
+	assert.Equal(t, b.String(), expected)
+}
+
+func TestWriteMermaid(t *testing.T) {
+	t.Run("should render graph", func(t *testing.T) {
+		var b strings.Builder
+		assert.NilError(t, WriteMermaid(&b, testGraph()))
+		expected := `flowchart LR
+  n0["a"]
+  n1["b #quot;quoted#quot;"]
+  n2["c"]
+  n3["d"]
+  n0 --> n1
+  n0 -. "RiskA" .-> n2
+  n1 --> n3
+  classDef blocked fill:#ff9999
+  class n2 blocked
+  classDef head fill:#99dd99
+  class n3 head
+`
+		assert.Equal(t, b.String(), expected)
+	})
+
+	t.Run("should fail with unknown nodes", func(t *testing.T) {
+		g := testGraph()
+		g.Edges = append(g.Edges, Edge{From: "a", To: "e"})
+		var b strings.Builder
+		err := WriteMermaid(&b, g)
+		assert.ErrorContains(t, err, "unknown node")
+		assert.ErrorIs(t, err, libErrs.ErrRenderGraph)
+	})
+}
@@ -0,0 +1,3 @@
+{{- define "app.image" -}}
+{{ .registry }}/{{ .repository }}:{{ .tag }}
+{{- end }}